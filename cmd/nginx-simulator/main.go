@@ -0,0 +1,91 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// nginx-simulator runs a fleet of fake nginx pods serving the rate limit
+// admin protocol, to develop the control plane without a cluster:
+//
+//	go run ./cmd/nginx-simulator -pods 3 -pattern abuser -zones one:10:20,two:5:0
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+	"github.com/tsuru/rate-limit-control-plane/internal/simulator"
+)
+
+func main() {
+	var settings simulator.Settings
+	var zones, key string
+	flag.IntVar(&settings.Pods, "pods", 3, "Number of nginx pods.")
+	flag.StringVar(&settings.Address, "address", "127.0.0.1", "Address the pods listen on.")
+	flag.IntVar(&settings.BasePort, "base-port", 8800, "Port of the first pod, the next ones use the following ports. 0 picks random ports.")
+	flag.StringVar(&zones, "zones", "one:10:20", "Comma separated zones as name:rate:burst, rate in requests per second.")
+	flag.StringVar(&key, "key", ratelimit.RemoteAddress, "Key of the zones, $remote_addr or $binary_remote_addr.")
+	flag.StringVar(&settings.Traffic.Pattern, "pattern", simulator.PatternSteady, "Traffic pattern: "+strings.Join(simulator.Patterns, ", ")+".")
+	flag.IntVar(&settings.Traffic.Clients, "clients", 50, "Number of clients making requests in the background.")
+	flag.Float64Var(&settings.Traffic.ClientRate, "client-rate", 2, "Requests per second of each client.")
+	flag.Float64Var(&settings.Traffic.BurstFactor, "burst-factor", 10, "Rate multiplier of bursts and of the abuser.")
+	flag.DurationVar(&settings.Traffic.BurstPeriod, "burst-period", time.Minute, "Period of the burst pattern.")
+	flag.Float64Var(&settings.Traffic.SprayRate, "spray-rate", 200, "New keys per second of the spray pattern.")
+	flag.DurationVar(&settings.Faults.Latency, "latency", 0, "Latency added to admin requests.")
+	flag.Float64Var(&settings.Faults.ErrorRate, "error-rate", 0, "Fraction of admin requests failing with 500.")
+	flag.DurationVar(&settings.Faults.RestartInterval, "restart-interval", 0, "Mean time between pod restarts, 0 disables them.")
+	flag.DurationVar(&settings.Faults.ClockSkew, "clock-skew", 0, "Offset of the pods wall clock.")
+	flag.Int64Var(&settings.Seed, "seed", time.Now().UnixNano(), "Seed of the traffic and faults.")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	var err error
+	settings.Zones, err = parseZones(zones, key)
+	if err != nil {
+		logger.Error("Invalid zones", "error", err)
+		os.Exit(2)
+	}
+
+	fleet, err := simulator.NewFleet(settings, logger)
+	if err != nil {
+		logger.Error("Error creating fleet", "error", err)
+		os.Exit(1)
+	}
+	for _, pod := range fleet.Pods {
+		logger.Info("Pod listening", "pod", pod.Name, "url", pod.URL)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := fleet.Run(ctx); err != nil {
+		logger.Error("Error running fleet", "error", err)
+		os.Exit(1)
+	}
+}
+
+func parseZones(value, key string) ([]simulator.ZoneSettings, error) {
+	var zones []simulator.ZoneSettings
+	for _, zone := range strings.Split(value, ",") {
+		parts := strings.Split(zone, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("zone %q is not name:rate:burst", zone)
+		}
+		rate, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate of zone %q: %w", parts[0], err)
+		}
+		burst, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid burst of zone %q: %w", parts[0], err)
+		}
+		zones = append(zones, simulator.ZoneSettings{Name: parts[0], Key: key, Rate: rate, Burst: burst})
+	}
+	return zones, nil
+}
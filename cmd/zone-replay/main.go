@@ -0,0 +1,148 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// zone-replay aggregates the pod reads kept by the zone recorder offline and
+// compares the result with the recorded aggregation, or with the one of
+// another aggregator:
+//
+//	go run ./cmd/zone-replay -aggregator prefix -compare recorded zones.rec
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/aggregator"
+	"github.com/tsuru/rate-limit-control-plane/internal/manager"
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+	"github.com/tsuru/rate-limit-control-plane/internal/recorder"
+)
+
+const compareRecorded = "recorded"
+
+func main() {
+	var aggregatorName, compare, instance, zone string
+	var ipv4Prefix, ipv6Prefix int
+	var verbose bool
+	flag.StringVar(&aggregatorName, "aggregator", "complete", "Aggregator replaying the reads: complete or prefix.")
+	flag.StringVar(&compare, "compare", compareRecorded, "What the replay is compared with: recorded, complete, prefix or none.")
+	flag.IntVar(&ipv4Prefix, "ipv4-prefix", 24, "IPv4 prefix length of the prefix aggregator.")
+	flag.IntVar(&ipv6Prefix, "ipv6-prefix", 64, "IPv6 prefix length of the prefix aggregator.")
+	flag.StringVar(&instance, "instance", "", "Only replay this instance.")
+	flag.StringVar(&zone, "zone", "", "Only replay this zone.")
+	flag.BoolVar(&verbose, "v", false, "Print every entry that differs.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] recording...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	replayAggregator, err := newAggregator(aggregatorName, ipv4Prefix, ipv6Prefix)
+	if err != nil {
+		fail(2, err)
+	}
+	var compareAggregator manager.ZoneAggregator
+	if compare != compareRecorded && compare != "none" {
+		if compareAggregator, err = newAggregator(compare, ipv4Prefix, ipv6Prefix); err != nil {
+			fail(2, err)
+		}
+	}
+
+	var paths []string
+	for _, path := range flag.Args() {
+		files := recorder.Files(path)
+		if len(files) == 0 {
+			fail(1, fmt.Errorf("no recording found at %s", path))
+		}
+		paths = append(paths, files...)
+	}
+	records, err := recorder.ReadFiles(paths...)
+	if err != nil {
+		fail(1, err)
+	}
+	records = filterRecords(records, instance, zone)
+
+	rounds, err := manager.Replay(records, replayAggregator)
+	if err != nil {
+		fail(1, err)
+	}
+	var compared []manager.ReplayRound
+	if compareAggregator != nil {
+		if compared, err = manager.Replay(records, compareAggregator); err != nil {
+			fail(1, err)
+		}
+	}
+
+	differences := 0
+	for i, round := range rounds {
+		line := fmt.Sprintf("%s %s round %d: %d pods, %d skipped, %d entries", round.Instance, round.Zone, round.Round, len(round.Pods), len(round.Skipped), len(round.Aggregated.RateLimitEntries))
+		var diffs []manager.EntryDiff
+		switch {
+		case compare == "none":
+		case compareAggregator != nil:
+			diffs = manager.DiffZones(round.Aggregated, compared[i].Aggregated)
+			line += fmt.Sprintf(", %d differences", len(diffs))
+		case round.Recorded == nil:
+			line += ", not recorded"
+		default:
+			diffs = manager.DiffZones(round.Aggregated, *round.Recorded)
+			line += fmt.Sprintf(", %d differences", len(diffs))
+		}
+		fmt.Println(line)
+		differences += len(diffs)
+		if verbose {
+			for _, diff := range diffs {
+				fmt.Printf("  %s: %s != %s\n", diff.Key, formatEntry(diff.Left), formatEntry(diff.Right))
+			}
+		}
+	}
+	fmt.Printf("%d rounds replayed, %d differences\n", len(rounds), differences)
+	if differences > 0 {
+		os.Exit(1)
+	}
+}
+
+func newAggregator(name string, ipv4Prefix, ipv6Prefix int) (manager.ZoneAggregator, error) {
+	switch name {
+	case "complete":
+		return new(aggregator.CompleteAggregator), nil
+	case "prefix":
+		return &aggregator.PrefixAggregator{
+			Aggregator:       new(aggregator.CompleteAggregator),
+			IPv4PrefixLength: ipv4Prefix,
+			IPv6PrefixLength: ipv6Prefix,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown aggregator %q", name)
+}
+
+func filterRecords(records []recorder.Record, instance, zone string) []recorder.Record {
+	if instance == "" && zone == "" {
+		return records
+	}
+	var filtered []recorder.Record
+	for _, record := range records {
+		if (instance == "" || record.Instance == instance) && (zone == "" || record.Zone == zone) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+func formatEntry(entry *ratelimit.RateLimitEntry) string {
+	if entry == nil {
+		return "missing"
+	}
+	return fmt.Sprintf("last=%d excess=%d", entry.Last, entry.Excess)
+}
+
+func fail(code int, err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(code)
+}
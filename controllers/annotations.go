@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	rpaasOperatorv1alpha1 "github.com/tsuru/rpaas-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/manager"
+)
+
+const (
+	annotationPrefix                = rpaasOperatorv1alpha1.DefaultLabelKeyPrefix + "/rate-limit-"
+	allowlistAnnotation             = annotationPrefix + "allowlist"
+	intervalAnnotation              = annotationPrefix + "interval"
+	persistAggregatedDataAnnotation = annotationPrefix + "persist-aggregated-data"
+	topOffendersAnnotation          = annotationPrefix + "top-offenders"
+	aggregatorAnnotation            = annotationPrefix + "aggregator"
+	includedZonesAnnotation         = annotationPrefix + "included-zones"
+	excludedZonesAnnotation         = annotationPrefix + "excluded-zones"
+
+	invalidAnnotationReason = "InvalidRateLimitAnnotation"
+	minInterval             = 100 * time.Millisecond
+)
+
+// settingsAnnotations are the annotations parsed into the instance settings.
+var settingsAnnotations = []string{
+	intervalAnnotation,
+	persistAggregatedDataAnnotation,
+	topOffendersAnnotation,
+	aggregatorAnnotation,
+	includedZonesAnnotation,
+	excludedZonesAnnotation,
+}
+
+// applyRpaasInstanceAnnotations updates the allowlist and returns the
+// settings of the instance. Invalid values are reported as Events once per
+// value and the global configuration is used for them.
+func (r *RateLimitControllerReconcile) applyRpaasInstanceAnnotations(rpaasInstance *rpaasOperatorv1alpha1.RpaasInstance) manager.InstanceSettings {
+	if r.Allowlists != nil {
+		entries := parseListAnnotation(rpaasInstance.Annotations[allowlistAnnotation])
+		err := r.Allowlists.SetAnnotationEntries(rpaasInstance.Name, entries)
+		if r.invalidAnnotationChanged(rpaasInstance, allowlistAnnotation, err != nil) {
+			r.Log.Error(err, "Invalid allowlist annotation - keeping previous allowlist", "instanceName", rpaasInstance.Name, "annotation", allowlistAnnotation)
+			r.recordInvalidAnnotation(rpaasInstance, allowlistAnnotation, err)
+		}
+	}
+
+	settings, errs := parseInstanceSettings(rpaasInstance.Annotations)
+	for _, annotation := range settingsAnnotations {
+		err, invalid := errs[annotation]
+		if r.invalidAnnotationChanged(rpaasInstance, annotation, invalid) {
+			r.Log.Error(err, "Invalid annotation - using global configuration", "instanceName", rpaasInstance.Name, "annotation", annotation)
+			r.recordInvalidAnnotation(rpaasInstance, annotation, err)
+		}
+	}
+	return settings
+}
+
+// invalidAnnotationChanged keeps the invalid value of each annotation and
+// tells whether it wasn't reported yet, as every pod reconcile parses them again.
+func (r *RateLimitControllerReconcile) invalidAnnotationChanged(rpaasInstance *rpaasOperatorv1alpha1.RpaasInstance, annotation string, invalid bool) bool {
+	r.annotationsMu.Lock()
+	defer r.annotationsMu.Unlock()
+	key := rpaasInstance.Namespace + "/" + rpaasInstance.Name + "/" + annotation
+	if !invalid {
+		delete(r.invalidAnnotations, key)
+		return false
+	}
+	value := rpaasInstance.Annotations[annotation]
+	if reported, ok := r.invalidAnnotations[key]; ok && reported == value {
+		return false
+	}
+	if r.invalidAnnotations == nil {
+		r.invalidAnnotations = make(map[string]string)
+	}
+	r.invalidAnnotations[key] = value
+	return true
+}
+
+func (r *RateLimitControllerReconcile) recordInvalidAnnotation(rpaasInstance *rpaasOperatorv1alpha1.RpaasInstance, annotation string, err error) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(rpaasInstance, corev1.EventTypeWarning, invalidAnnotationReason, "Invalid value of annotation %s: %v", annotation, err)
+}
+
+// parseInstanceSettings returns the settings of the valid annotations and the errors of the others, by annotation.
+func parseInstanceSettings(annotations map[string]string) (manager.InstanceSettings, map[string]error) {
+	var settings manager.InstanceSettings
+	errs := make(map[string]error)
+
+	if value, ok := annotations[intervalAnnotation]; ok {
+		interval, err := time.ParseDuration(value)
+		if err == nil && interval < minInterval {
+			err = fmt.Errorf("must be at least %s", minInterval)
+		}
+		if err != nil {
+			errs[intervalAnnotation] = err
+		} else {
+			settings.Interval = interval
+		}
+	}
+
+	if value, ok := annotations[persistAggregatedDataAnnotation]; ok {
+		persist, err := strconv.ParseBool(value)
+		if err != nil {
+			errs[persistAggregatedDataAnnotation] = err
+		} else {
+			settings.PersistAggregatedData = &persist
+		}
+	}
+
+	if value, ok := annotations[topOffendersAnnotation]; ok {
+		topOffenders, err := strconv.Atoi(value)
+		if err == nil && topOffenders <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err != nil {
+			errs[topOffendersAnnotation] = err
+		} else {
+			settings.MaxTopOffenders = topOffenders
+		}
+	}
+
+	if value, ok := annotations[aggregatorAnnotation]; ok {
+		switch value {
+		case aggregatorComplete, aggregatorPrefix:
+			settings.Aggregator = value
+		default:
+			errs[aggregatorAnnotation] = fmt.Errorf("unknown aggregator %q, must be %s or %s", value, aggregatorComplete, aggregatorPrefix)
+		}
+	}
+
+	for annotation, patterns := range map[string]*[]string{
+		includedZonesAnnotation: &settings.IncludedZones,
+		excludedZonesAnnotation: &settings.ExcludedZones,
+	} {
+		value := parseListAnnotation(annotations[annotation])
+		if _, err := manager.NewZoneFilter(value, nil); err != nil {
+			errs[annotation] = err
+			continue
+		}
+		*patterns = value
+	}
+
+	return settings, errs
+}
+
+// parseListAnnotation splits annotation values separated by commas, spaces or new lines.
+func parseListAnnotation(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t'
+	})
+}
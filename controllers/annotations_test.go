@@ -0,0 +1,94 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rpaasOperatorv1alpha1 "github.com/tsuru/rpaas-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestApplyRpaasInstanceAnnotationsReportsOnce(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &RateLimitControllerReconcile{Log: logr.Discard(), Recorder: recorder}
+	instance := &rpaasOperatorv1alpha1.RpaasInstance{ObjectMeta: metav1.ObjectMeta{
+		Name:        "my-instance",
+		Namespace:   "rpaasv2",
+		Annotations: map[string]string{intervalAnnotation: "1ms", topOffendersAnnotation: "10"},
+	}}
+	events := func() int {
+		count := 0
+		for {
+			select {
+			case <-recorder.Events:
+				count++
+			default:
+				return count
+			}
+		}
+	}
+
+	// Every pod reconcile parses the annotations again
+	for i := 0; i < 3; i++ {
+		settings := r.applyRpaasInstanceAnnotations(instance)
+		assert.Equal(t, time.Duration(0), settings.Interval)
+		assert.Equal(t, 10, settings.MaxTopOffenders)
+	}
+	assert.Equal(t, 1, events())
+
+	instance.Annotations[intervalAnnotation] = "bogus"
+	r.applyRpaasInstanceAnnotations(instance)
+	r.applyRpaasInstanceAnnotations(instance)
+	assert.Equal(t, 1, events())
+
+	instance.Annotations[intervalAnnotation] = "5s"
+	assert.Equal(t, 5*time.Second, r.applyRpaasInstanceAnnotations(instance).Interval)
+	assert.Equal(t, 0, events())
+
+	// A value fixed and broken again is reported again
+	instance.Annotations[intervalAnnotation] = "bogus"
+	r.applyRpaasInstanceAnnotations(instance)
+	assert.Equal(t, 1, events())
+}
+
+func TestRpaasInstancePods(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, rpaasOperatorv1alpha1.AddToScheme(scheme))
+	pod := func(namespace, name, instance string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{instanceNameLabel: instance},
+		}}
+	}
+	r := &RateLimitControllerReconcile{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			pod("rpaasv2", "my-instance-nginx-aaaaa", "my-instance"),
+			pod("rpaasv2", "my-instance-nginx-bbbbb", "my-instance"),
+			pod("rpaasv2", "other-nginx-ccccc", "other"),
+			pod("elsewhere", "my-instance-nginx-ddddd", "my-instance"),
+		).Build(),
+		Log: logr.Discard(),
+	}
+
+	requests := r.rpaasInstancePods(&rpaasOperatorv1alpha1.RpaasInstance{ObjectMeta: metav1.ObjectMeta{Name: "my-instance", Namespace: "rpaasv2"}})
+	assert.ElementsMatch(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: "rpaasv2", Name: "my-instance-nginx-aaaaa"}},
+		{NamespacedName: types.NamespacedName{Namespace: "rpaasv2", Name: "my-instance-nginx-bbbbb"}},
+	}, requests)
+}
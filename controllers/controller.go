@@ -7,9 +7,11 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -18,12 +20,18 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/tsuru/rate-limit-control-plane/internal/aggregator"
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
 	"github.com/tsuru/rate-limit-control-plane/internal/logger"
 	"github.com/tsuru/rate-limit-control-plane/internal/manager"
 	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
@@ -32,6 +40,10 @@ import (
 const (
 	flavor             = "global-ratelimit"
 	administrativePort = 8800
+	instanceNameLabel  = "rpaas.extensions.tsuru.io/instance-name"
+
+	aggregatorComplete = "complete"
+	aggregatorPrefix   = "prefix"
 )
 
 type RateLimitControllerReconcile struct {
@@ -40,12 +52,18 @@ type RateLimitControllerReconcile struct {
 	ManagerGoroutine *manager.GoroutineManager
 	Namespace        string
 	Notify           chan ratelimit.RpaasZoneData
+	Overrides        *manager.OverrideStore
+	Allowlists       *manager.AllowlistStore
+	Recorder         record.EventRecorder
+	ZoneFilter       manager.ZoneFilter
+
+	annotationsMu      sync.Mutex
+	invalidAnnotations map[string]string
 }
 
 func (r *RateLimitControllerReconcile) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Pod{}).
-		WithEventFilter(predicate.Funcs{
+		For(&corev1.Pod{}, builder.WithPredicates(predicate.Funcs{
 			CreateFunc: func(e event.CreateEvent) bool {
 				pod, ok := e.Object.(*corev1.Pod)
 				return ok && pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != ""
@@ -59,10 +77,30 @@ func (r *RateLimitControllerReconcile) SetupWithManager(mgr ctrl.Manager) error
 				return (pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "") || (pod.Status.Phase == corev1.PodSucceeded)
 			},
 			GenericFunc: func(e event.GenericEvent) bool { return false },
-		}).
+		})).
+		// The settings of an instance come from its annotations, which pods don't see changing
+		Watches(
+			&source.Kind{Type: &rpaasOperatorv1alpha1.RpaasInstance{}},
+			handler.EnqueueRequestsFromMapFunc(r.rpaasInstancePods),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{}),
+		).
 		Complete(r)
 }
 
+// rpaasInstancePods returns the reconcile requests of the pods of an RpaasInstance.
+func (r *RateLimitControllerReconcile) rpaasInstancePods(obj client.Object) []reconcile.Request {
+	var pods corev1.PodList
+	if err := r.List(context.Background(), &pods, client.InNamespace(obj.GetNamespace()), client.MatchingLabels{instanceNameLabel: obj.GetName()}); err != nil {
+		r.Log.Error(err, "Failed to list pods of RpaasInstance", "namespace", obj.GetNamespace(), "instanceName", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}})
+	}
+	return requests
+}
+
 func (r *RateLimitControllerReconcile) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	var pod corev1.Pod
 	if err := r.Get(ctx, req.NamespacedName, &pod); err != nil {
@@ -73,7 +111,7 @@ func (r *RateLimitControllerReconcile) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, err
 	}
 
-	rpaasInstanceName, exists := pod.Labels["rpaas.extensions.tsuru.io/instance-name"]
+	rpaasInstanceName, exists := pod.Labels[instanceNameLabel]
 	if !exists {
 		r.Log.Info("pod does not have rpaas instance label - removing from queue", "namespace", req.Namespace, "name", req.Name)
 		return ctrl.Result{}, nil
@@ -94,27 +132,30 @@ func (r *RateLimitControllerReconcile) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
 	}
 
-	if err := r.validateRpaasInstanceFlavor(req, rpaasInstanceName); err != nil {
+	rpaasInstance, err := r.validateRpaasInstanceFlavor(req, rpaasInstanceName)
+	if err != nil {
 		r.Log.Error(err, "RpaasInstance does not have expected flavor - removing from queue", "request", req)
 		return ctrl.Result{}, nil
 	}
+	settings := r.applyRpaasInstanceAnnotations(rpaasInstance)
 
-	zoneNames, err := r.getNginxRateLimitingZones(&pod)
+	discoveredZones, err := r.getNginxRateLimitingZones(ctx, rpaasInstanceName, &pod)
 	if err != nil {
 		r.Log.Error(err, "Failed to get rate limiting zones", "namespace", req.Namespace, "name", req.Name)
 		return ctrl.Result{RequeueAfter: time.Second * 5}, err
 	}
+	zoneFilter := r.zoneFilter(settings)
+	zoneNames := zoneFilter.Filter(discoveredZones)
 
-	if len(zoneNames) == 0 {
-		r.Log.Info("no rate limiting zones found", "namespace", req.Namespace, "name", req.Name)
+	worker, exists := r.ManagerGoroutine.GetWorker(rpaasInstanceName)
+	if !exists && len(zoneNames) == 0 {
+		r.Log.Info("no rate limiting zones found", "namespace", req.Namespace, "name", req.Name, "discoveredZones", discoveredZones)
 		return ctrl.Result{}, nil
 	}
-
-	worker, exists := r.ManagerGoroutine.GetWorker(rpaasInstanceName)
 	if !exists {
 		instanceLogger := logger.NewLogger(map[string]string{"emitter": "rate-limit-control-plane"}, os.Stdout)
 		rpaasInstanceData := manager.RpaasInstanceData{Instance: rpaasInstanceName, Service: rpaasServiceName}
-		worker = manager.NewRpaasInstanceSyncWorker(rpaasInstanceData, zoneNames, instanceLogger, r.Notify, new(aggregator.CompleteAggregator))
+		worker = manager.NewRpaasInstanceSyncWorker(rpaasInstanceData, zoneNames, instanceLogger, r.Notify, newZoneAggregator(settings.Aggregator), r.Overrides, r.Allowlists)
 		if !r.ManagerGoroutine.AddWorker(worker) {
 			r.Log.Info("worker already exists after add attempt", "instanceName", rpaasInstanceName, "request", req)
 		}
@@ -126,12 +167,29 @@ func (r *RateLimitControllerReconcile) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, nil
 	}
 
+	var newAggregator manager.ZoneAggregator
+	if current := rpaasInstanceSyncWorker.Settings(); current.Aggregator != settings.Aggregator {
+		newAggregator = newZoneAggregator(settings.Aggregator)
+	}
+	rpaasInstanceSyncWorker.SetSettings(settings, newAggregator)
+	rpaasInstanceSyncWorker.UpdateZones(discoveredZones, zoneFilter)
+
 	rpaasInstanceSyncWorker.AddPodWorker(pod.Status.PodIP, pod.Name)
+	rpaasInstanceSyncWorker.ObservePodRestarts(pod.Name, podRestarts(&pod))
 
 	r.Log.Info("pod started", "namespace", req.Namespace, "name", req.Name, "podIP", pod.Status.PodIP)
 	return ctrl.Result{}, nil
 }
 
+// podRestarts sums the restarts of every container, as nginx counters live in the pod memory.
+func podRestarts(pod *corev1.Pod) int32 {
+	var restarts int32
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
+
 func (r *RateLimitControllerReconcile) handlePodNotFound(req ctrl.Request) (ctrl.Result, error) {
 	r.Log.Info("pod not found", "req", req)
 	instanceName, err := instanceNameFromDeploymentPodName(req.Name)
@@ -171,16 +229,16 @@ func (r *RateLimitControllerReconcile) handlePodStoped(rpaasInstanceName string,
 	return ctrl.Result{}, nil
 }
 
-func (r *RateLimitControllerReconcile) validateRpaasInstanceFlavor(request ctrl.Request, rpaasInstanceName string) error {
+func (r *RateLimitControllerReconcile) validateRpaasInstanceFlavor(request ctrl.Request, rpaasInstanceName string) (*rpaasOperatorv1alpha1.RpaasInstance, error) {
 	var rpaasInstance rpaasOperatorv1alpha1.RpaasInstance
 	if err := r.Get(context.Background(), types.NamespacedName{Namespace: request.Namespace, Name: rpaasInstanceName}, &rpaasInstance); err != nil {
-		return fmt.Errorf("failed to get RpaasInstance %s/%s: %w", request.Namespace, request.Name, err)
+		return nil, fmt.Errorf("failed to get RpaasInstance %s/%s: %w", request.Namespace, request.Name, err)
 	}
 
 	if !slices.Contains(rpaasInstance.Spec.Flavors, flavor) {
-		return fmt.Errorf("RpaasInstance %s/%s does not have expected flavor %s", request.Namespace, request.Name, flavor)
+		return nil, fmt.Errorf("RpaasInstance %s/%s does not have expected flavor %s", request.Namespace, request.Name, flavor)
 	}
-	return nil
+	return &rpaasInstance, nil
 }
 
 func (r *RateLimitControllerReconcile) getRpaasInstanceWorker(rpaasInstanceName string) (*manager.RpaasInstanceSyncWorker, error) {
@@ -209,21 +267,58 @@ func (r *RateLimitControllerReconcile) removePodWorkerFromRpaasInstanceWorker(rp
 	return nil
 }
 
-func (r *RateLimitControllerReconcile) getNginxRateLimitingZones(nginxInstance *corev1.Pod) ([]string, error) {
-	zones := []string{}
+func (r *RateLimitControllerReconcile) getNginxRateLimitingZones(ctx context.Context, rpaasInstanceName string, nginxInstance *corev1.Pod) ([]string, error) {
 	endpoint := fmt.Sprintf("http://%s:%d/%s", nginxInstance.Status.PodIP, administrativePort, "rate-limit")
-	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	release, err := manager.AcquirePodRequest(ctx, rpaasInstanceName, manager.RequestOperationDiscovery)
 	if err != nil {
 		return nil, err
 	}
-	response, err := http.DefaultClient.Do(req)
+	defer release()
+	client, releaseClient := manager.AcquirePodClient()
+	defer releaseClient()
+	response, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
-	decoder := msgpack.NewDecoder(response.Body)
+	return decodeZoneNames(response.Body)
+}
+
+// decodeZoneNames reads the msgpack array of zone names nginx lists.
+func decodeZoneNames(body io.Reader) ([]string, error) {
+	zones := []string{}
+	decoder := msgpack.NewDecoder(body)
 	if err := decoder.Decode(&zones); err != nil {
 		return nil, err
 	}
 	return zones, nil
 }
+
+// zoneFilter merges the global zone filter with the one of the instance
+// annotations, which were validated when parsed.
+func (r *RateLimitControllerReconcile) zoneFilter(settings manager.InstanceSettings) manager.ZoneFilter {
+	instanceFilter, err := settings.ZoneFilter()
+	if err != nil {
+		return r.ZoneFilter
+	}
+	return r.ZoneFilter.Merge(instanceFilter)
+}
+
+// newZoneAggregator returns the named aggregator, or the one of the global configuration when name is empty.
+func newZoneAggregator(name string) manager.ZoneAggregator {
+	if name == "" && config.Spec.PrefixGroupingEnabled && config.Spec.PrefixGroupingEnforce {
+		name = aggregatorPrefix
+	}
+	if name == aggregatorPrefix {
+		return &aggregator.PrefixAggregator{
+			Aggregator:       new(aggregator.CompleteAggregator),
+			IPv4PrefixLength: config.Spec.PrefixGroupingIPv4Length,
+			IPv6PrefixLength: config.Spec.PrefixGroupingIPv6Length,
+		}
+	}
+	return new(aggregator.CompleteAggregator)
+}
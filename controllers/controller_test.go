@@ -0,0 +1,37 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controllers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func FuzzDecodeZoneNames(f *testing.F) {
+	for _, zones := range [][]string{nil, {}, {"one"}, {"one", "two", ""}} {
+		encoded, err := msgpack.Marshal(zones)
+		require.NoError(f, err)
+		f.Add(encoded)
+	}
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		zones, err := decodeZoneNames(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		encoded, err := msgpack.Marshal(zones)
+		require.NoError(t, err)
+		decoded, err := decodeZoneNames(bytes.NewReader(encoded))
+		require.NoError(t, err)
+		require.Len(t, decoded, len(zones))
+		for i := range zones {
+			require.Equal(t, zones[i], decoded[i])
+		}
+	})
+}
@@ -0,0 +1,283 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rpaasOperatorv1alpha1 "github.com/tsuru/rpaas-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/manager"
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+	"github.com/tsuru/rate-limit-control-plane/internal/repository"
+	"github.com/tsuru/rate-limit-control-plane/internal/simulator"
+)
+
+const (
+	e2eNamespace = "default"
+	e2eService   = "rpaasv2"
+	e2eTimeout   = 20 * time.Second
+	e2ePoll      = 100 * time.Millisecond
+)
+
+// e2eSuite runs the controller against an envtest API server. There is no
+// kubelet, so pods get their IP and phase by status updates, each IP being a
+// loopback address where a simulated nginx serves the admin port.
+type e2eSuite struct {
+	t       *testing.T
+	client  client.Client
+	workers *manager.GoroutineManager
+	repo    *repository.ZoneDataRepository
+	ctx     context.Context
+}
+
+func TestControllerEndToEnd(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		// make test and make test-e2e provision the binaries, a skip there would hide the suite
+		if os.Getenv("E2E_REQUIRED") != "" {
+			t.Fatal("KUBEBUILDER_ASSETS is not set, see https://book.kubebuilder.io/reference/envtest.html")
+		}
+		t.Skip("KUBEBUILDER_ASSETS is not set, see https://book.kubebuilder.io/reference/envtest.html")
+	}
+	s := newE2ESuite(t)
+
+	s.createInstance("e2e", []string{flavor})
+	s.createInstance("plain", nil)
+	s.startNginx("127.0.0.2")
+	s.startNginx("127.0.0.3")
+	s.startNginx("127.0.0.4")
+
+	t.Run("sync worker is created for the first pod", func(t *testing.T) {
+		s.createPod("e2e-nginx-aaaaa", "e2e", "127.0.0.2")
+		require.Eventually(t, func() bool {
+			return s.podWorkers("e2e") == 1
+		}, e2eTimeout, e2ePoll)
+	})
+
+	t.Run("pods join the sync worker", func(t *testing.T) {
+		s.createPod("e2e-nginx-bbbbb", "e2e", "127.0.0.3")
+		require.Eventually(t, func() bool {
+			return s.podWorkers("e2e") == 2
+		}, e2eTimeout, e2ePoll)
+	})
+
+	t.Run("annotation changes apply without pod changes", func(t *testing.T) {
+		s.annotateInstance("e2e", intervalAnnotation, "5s")
+		require.Eventually(t, func() bool {
+			worker := s.syncWorker("e2e")
+			return worker != nil && worker.Settings().Interval == 5*time.Second
+		}, e2eTimeout, e2ePoll)
+	})
+
+	t.Run("aggregated data reaches the repository", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			data, _, ok := s.repo.GetRpaasZoneSnapshot("e2e")
+			if !ok {
+				return false
+			}
+			for _, entry := range data {
+				if entry.Key == simulator.AbuserKey && entry.Zone == "one" && entry.Excess > 0 {
+					return true
+				}
+			}
+			return false
+		}, e2eTimeout, e2ePoll)
+	})
+
+	t.Run("instances without the flavor are ignored", func(t *testing.T) {
+		s.createPod("plain-nginx-ccccc", "plain", "127.0.0.4")
+		assert.Never(t, func() bool {
+			_, exists := s.workers.GetWorker("plain")
+			return exists
+		}, 3*time.Second, e2ePoll)
+	})
+
+	t.Run("pods leave the sync worker", func(t *testing.T) {
+		s.deletePod("e2e-nginx-bbbbb")
+		require.Eventually(t, func() bool {
+			return s.podWorkers("e2e") == 1
+		}, e2eTimeout, e2ePoll)
+	})
+
+	t.Run("sync worker is removed with the last pod", func(t *testing.T) {
+		s.deletePod("e2e-nginx-aaaaa")
+		require.Eventually(t, func() bool {
+			_, exists := s.workers.GetWorker("e2e")
+			return !exists
+		}, e2eTimeout, e2ePoll)
+	})
+}
+
+func newE2ESuite(t *testing.T) *e2eSuite {
+	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{rpaasCRDPath(t)},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Logf("Error stopping envtest: %v", err)
+		}
+	})
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, rpaasOperatorv1alpha1.AddToScheme(scheme))
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: "0",
+	})
+	require.NoError(t, err)
+
+	workers := manager.NewGoroutineManager()
+	require.NoError(t, mgr.Add(workers))
+	repo, ch := repository.NewRpaasZoneDataRepository()
+	zoneFilter, err := manager.NewZoneFilter(nil, nil)
+	require.NoError(t, err)
+	err = (&RateLimitControllerReconcile{
+		Client:           mgr.GetClient(),
+		Log:              mgr.GetLogger().WithName("controllers").WithName("RateLimitControllerReconcile"),
+		ManagerGoroutine: workers,
+		Namespace:        e2eNamespace,
+		Notify:           ch,
+		Recorder:         mgr.GetEventRecorderFor("rate-limit-control-plane"),
+		ZoneFilter:       zoneFilter,
+	}).SetupWithManager(mgr)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- mgr.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	return &e2eSuite{t: t, client: mgr.GetClient(), workers: workers, repo: repo, ctx: ctx}
+}
+
+// rpaasCRDPath finds the CRDs shipped with the rpaas-operator module this repository depends on.
+func rpaasCRDPath(t *testing.T) string {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/tsuru/rpaas-operator").Output()
+	require.NoError(t, err, "locating the rpaas-operator module")
+	return filepath.Join(strings.TrimSpace(string(out)), "config", "crd", "bases", "extensions.tsuru.io_rpaasinstances.yaml")
+}
+
+// startNginx serves a simulated nginx with an abuser on the admin port of ip.
+func (s *e2eSuite) startNginx(ip string) {
+	fleet, err := simulator.NewFleet(simulator.Settings{
+		Pods:     1,
+		Address:  ip,
+		BasePort: administrativePort,
+		Zones:    []simulator.ZoneSettings{{Name: "one", Key: ratelimit.RemoteAddress, Rate: 10, Burst: 20}},
+		Traffic:  simulator.TrafficSettings{Pattern: simulator.PatternAbuser, Clients: 5, ClientRate: 2, BurstFactor: 25},
+	}, slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		s.t.Skipf("Can't listen on %s, loopback addresses other than 127.0.0.1 are needed: %v", ip, err)
+	}
+	require.NoError(s.t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- fleet.Run(ctx) }()
+	s.t.Cleanup(func() {
+		cancel()
+		assert.NoError(s.t, <-done)
+	})
+}
+
+func (s *e2eSuite) createInstance(name string, flavors []string) {
+	instance := &rpaasOperatorv1alpha1.RpaasInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: e2eNamespace},
+		Spec: rpaasOperatorv1alpha1.RpaasInstanceSpec{
+			PlanName: "basic",
+			Flavors:  flavors,
+		},
+	}
+	require.NoError(s.t, s.client.Create(s.ctx, instance))
+}
+
+func (s *e2eSuite) createPod(name, instance, ip string) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: e2eNamespace,
+			Labels: map[string]string{
+				"rpaas.extensions.tsuru.io/instance-name": instance,
+				"rpaas.extensions.tsuru.io/service-name":  e2eService,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "nginx", Image: "nginx"}},
+		},
+	}
+	require.NoError(s.t, s.client.Create(s.ctx, pod))
+	pod.Status = corev1.PodStatus{
+		Phase:  corev1.PodRunning,
+		PodIP:  ip,
+		PodIPs: []corev1.PodIP{{IP: ip}},
+	}
+	require.NoError(s.t, s.client.Status().Update(s.ctx, pod))
+}
+
+func (s *e2eSuite) deletePod(name string) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: e2eNamespace}}
+	// Pods never scheduled to a node are removed at once
+	require.NoError(s.t, s.client.Delete(s.ctx, pod, client.GracePeriodSeconds(0)))
+}
+
+func (s *e2eSuite) annotateInstance(name, annotation, value string) {
+	var instance rpaasOperatorv1alpha1.RpaasInstance
+	require.NoError(s.t, s.client.Get(s.ctx, client.ObjectKey{Namespace: e2eNamespace, Name: name}, &instance))
+	if instance.Annotations == nil {
+		instance.Annotations = map[string]string{}
+	}
+	instance.Annotations[annotation] = value
+	require.NoError(s.t, s.client.Update(s.ctx, &instance))
+}
+
+// syncWorker returns the sync worker of an instance, nil when there is none.
+func (s *e2eSuite) syncWorker(instance string) *manager.RpaasInstanceSyncWorker {
+	worker, exists := s.workers.GetWorker(instance)
+	if !exists {
+		return nil
+	}
+	syncWorker, ok := worker.(*manager.RpaasInstanceSyncWorker)
+	if !ok {
+		panic(fmt.Sprintf("unexpected worker type %T", worker))
+	}
+	return syncWorker
+}
+
+func (s *e2eSuite) podWorkers(instance string) int {
+	syncWorker := s.syncWorker(instance)
+	if syncWorker == nil {
+		return 0
+	}
+	return syncWorker.CountWorkers()
+}
@@ -0,0 +1,184 @@
+// Package aggregatortest is a conformance suite for zone aggregators,
+// checking the properties the sync workers rely on whatever the aggregation
+// policy is.
+package aggregatortest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+type ZoneAggregator interface {
+	AggregateZones(zonePerPod []ratelimit.Zone, fullZone map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry) (ratelimit.Zone, map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry)
+}
+
+// Round is the input of an aggregation, the zone read from each pod and the
+// state kept since the previous round.
+type Round struct {
+	Zones    []ratelimit.Zone
+	FullZone map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry
+}
+
+const zoneName = "zone"
+
+var header = ratelimit.RateLimitHeader{Key: ratelimit.RemoteAddress, Now: 1_700_000_000_000, NowMonotonic: 86_400_000}
+
+// keys share a few IPv4 and IPv6 prefixes, with a key that isn't an address.
+var keys = []string{
+	"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4",
+	"10.0.1.1", "10.0.1.2", "192.0.2.10",
+	"2001:db8::1", "2001:db8::2", "2001:db8:1::1",
+	"user-42",
+}
+
+// RandomZones reads a zone from 1 to 4 pods, each with a random subset of the keys.
+func RandomZones(rnd *rand.Rand) []ratelimit.Zone {
+	zones := make([]ratelimit.Zone, 1+rnd.Intn(4))
+	for i := range zones {
+		zones[i] = ratelimit.Zone{Name: zoneName, RateLimitHeader: header}
+		for _, key := range keys {
+			if rnd.Intn(2) == 0 {
+				continue
+			}
+			zones[i].RateLimitEntries = append(zones[i].RateLimitEntries, ratelimit.RateLimitEntry{
+				Key:    ratelimit.Key(key),
+				Last:   header.Now - rnd.Int63n(60_000),
+				Excess: rnd.Int63n(20) * 500,
+			})
+		}
+	}
+	return zones
+}
+
+// Run checks the properties on sequences of random rounds, each one starting
+// from the state of the previous one.
+func Run(t *testing.T, newAggregator func() ZoneAggregator) {
+	rnd := rand.New(rand.NewSource(1))
+	for sequence := range 50 {
+		t.Run(fmt.Sprintf("sequence %d", sequence), func(t *testing.T) {
+			RunSequence(t, newAggregator, rnd, 5)
+		})
+	}
+}
+
+// RunSequence checks the properties on rounds random rounds.
+func RunSequence(t testing.TB, newAggregator func() ZoneAggregator, rnd *rand.Rand, rounds int) {
+	t.Helper()
+	var fullZone map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry
+	for range rounds {
+		_, fullZone = CheckRound(t, newAggregator, Round{Zones: RandomZones(rnd), FullZone: fullZone}, rnd)
+	}
+}
+
+// CheckRound aggregates a round and checks:
+//   - excess is never negative
+//   - every key read is aggregated once, with the latest Last of the pods
+//   - the aggregated entries are the ones kept for the next round
+//   - the order of the pods doesn't matter
+//   - the same round gives the same result and its input is left untouched
+//   - once the aggregated entries are written back and read from every pod,
+//     aggregating them again changes nothing
+func CheckRound(t testing.TB, newAggregator func() ZoneAggregator, round Round, rnd *rand.Rand) (ratelimit.Zone, map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry) {
+	t.Helper()
+	input := cloneRound(round)
+	zone, fullZone := newAggregator().AggregateZones(round.Zones, round.FullZone)
+	require.Equal(t, input, round, "aggregation changed its input")
+
+	aggregated := entriesByKey(t, zone)
+	latest := map[string]int64{}
+	for _, podZone := range round.Zones {
+		for _, entry := range podZone.RateLimitEntries {
+			key := entry.Key.String(podZone.RateLimitHeader)
+			latest[key] = max(latest[key], entry.Last)
+		}
+	}
+	require.Len(t, aggregated, len(latest), "every key read must be aggregated")
+	for key, entry := range aggregated {
+		require.GreaterOrEqual(t, entry.Excess, int64(0), "negative excess for %s", key)
+		require.Equal(t, latest[key], entry.Last, "Last of %s is not the latest of the pods", key)
+		kept, ok := fullZone[ratelimit.FullZoneKey{Zone: zone.Name, Key: key}]
+		require.True(t, ok, "%s is not kept for the next round", key)
+		require.Equal(t, entry, summary{Last: kept.Last, Excess: kept.Excess}, "%s differs from the entry kept for the next round", key)
+	}
+	for key, entry := range fullZone {
+		require.GreaterOrEqual(t, entry.Excess, int64(0), "negative excess kept for %v", key)
+	}
+
+	again, againFullZone := newAggregator().AggregateZones(round.Zones, round.FullZone)
+	require.Equal(t, aggregated, entriesByKey(t, again), "aggregation is not deterministic")
+	require.Equal(t, summarize(fullZone), summarize(againFullZone), "aggregation is not deterministic")
+
+	shuffled := cloneRound(round)
+	rnd.Shuffle(len(shuffled.Zones), func(i, j int) {
+		shuffled.Zones[i], shuffled.Zones[j] = shuffled.Zones[j], shuffled.Zones[i]
+	})
+	shuffledZone, shuffledFullZone := newAggregator().AggregateZones(shuffled.Zones, shuffled.FullZone)
+	require.Equal(t, aggregated, entriesByKey(t, shuffledZone), "aggregation depends on the order of the pods")
+	require.Equal(t, summarize(fullZone), summarize(shuffledFullZone), "aggregation depends on the order of the pods")
+
+	agreed := Round{FullZone: fullZone}
+	for range round.Zones {
+		agreed.Zones = append(agreed.Zones, cloneZone(zone))
+	}
+	convergedZone, convergedFullZone := newAggregator().AggregateZones(agreed.Zones, agreed.FullZone)
+	require.Equal(t, aggregated, entriesByKey(t, convergedZone), "pods agreeing with the previous round must not change it")
+	require.Equal(t, summarize(fullZone), summarize(convergedFullZone), "pods agreeing with the previous round must not change it")
+
+	return zone, fullZone
+}
+
+type summary struct {
+	Last   int64
+	Excess int64
+}
+
+func entriesByKey(t testing.TB, zone ratelimit.Zone) map[string]summary {
+	t.Helper()
+	entries := make(map[string]summary, len(zone.RateLimitEntries))
+	for _, entry := range zone.RateLimitEntries {
+		key := entry.Key.String(zone.RateLimitHeader)
+		_, duplicated := entries[key]
+		require.False(t, duplicated, "%s is aggregated more than once", key)
+		entries[key] = summary{Last: entry.Last, Excess: entry.Excess}
+	}
+	return entries
+}
+
+func summarize(fullZone map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry) map[ratelimit.FullZoneKey]summary {
+	summaries := make(map[ratelimit.FullZoneKey]summary, len(fullZone))
+	for key, entry := range fullZone {
+		summaries[key] = summary{Last: entry.Last, Excess: entry.Excess}
+	}
+	return summaries
+}
+
+func cloneZone(zone ratelimit.Zone) ratelimit.Zone {
+	clone := zone
+	clone.RateLimitEntries = nil
+	for _, entry := range zone.RateLimitEntries {
+		entry.Key = append(ratelimit.Key(nil), entry.Key...)
+		clone.RateLimitEntries = append(clone.RateLimitEntries, entry)
+	}
+	return clone
+}
+
+func cloneRound(round Round) Round {
+	var clone Round
+	for _, zone := range round.Zones {
+		clone.Zones = append(clone.Zones, cloneZone(zone))
+	}
+	if round.FullZone != nil {
+		clone.FullZone = make(map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry, len(round.FullZone))
+		for key, entry := range round.FullZone {
+			entryClone := *entry
+			entryClone.Key = append(ratelimit.Key(nil), entry.Key...)
+			clone.FullZone[key] = &entryClone
+		}
+	}
+	return clone
+}
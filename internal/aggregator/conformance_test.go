@@ -0,0 +1,40 @@
+package aggregator
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/aggregator/aggregatortest"
+)
+
+func newAggregators() map[string]func() aggregatortest.ZoneAggregator {
+	return map[string]func() aggregatortest.ZoneAggregator{
+		"complete": func() aggregatortest.ZoneAggregator {
+			return &CompleteAggregator{}
+		},
+		"prefix /24 /64": func() aggregatortest.ZoneAggregator {
+			return &PrefixAggregator{Aggregator: &CompleteAggregator{}, IPv4PrefixLength: 24, IPv6PrefixLength: 64}
+		},
+		"prefix /16 /32": func() aggregatortest.ZoneAggregator {
+			return &PrefixAggregator{Aggregator: &CompleteAggregator{}, IPv4PrefixLength: 16, IPv6PrefixLength: 32}
+		},
+	}
+}
+
+func TestConformance(t *testing.T) {
+	for name, newAggregator := range newAggregators() {
+		t.Run(name, func(t *testing.T) {
+			aggregatortest.Run(t, newAggregator)
+		})
+	}
+}
+
+func FuzzConformance(f *testing.F) {
+	f.Add(int64(1), uint8(3))
+	f.Add(int64(42), uint8(10))
+	f.Fuzz(func(t *testing.T, seed int64, rounds uint8) {
+		for _, newAggregator := range newAggregators() {
+			aggregatortest.RunSequence(t, newAggregator, rand.New(rand.NewSource(seed)), int(rounds%16))
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package aggregator
+
+import (
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+type zoneAggregator interface {
+	AggregateZones(zonePerPod []ratelimit.Zone, fullZone map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry) (ratelimit.Zone, map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry)
+}
+
+// PrefixAggregator enforces limits per network instead of per address, so
+// clients rotating addresses inside a prefix share the same excess. Every
+// address of a prefix is pushed back to the pods with the excess of the whole
+// prefix, which is kept in fullZone under the prefix in CIDR notation.
+type PrefixAggregator struct {
+	Aggregator       zoneAggregator
+	IPv4PrefixLength int
+	IPv6PrefixLength int
+}
+
+type prefixGroup struct {
+	excess  int64
+	last    int64
+	members []int
+}
+
+func (a *PrefixAggregator) AggregateZones(zonePerPod []ratelimit.Zone, fullZone map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry) (ratelimit.Zone, map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry) {
+	zone, newFullZone := a.Aggregator.AggregateZones(zonePerPod, fullZone)
+
+	groups := make(map[string]*prefixGroup)
+	for i, entry := range zone.RateLimitEntries {
+		key := entry.Key.String(zone.RateLimitHeader)
+		prefix, ok := ratelimit.KeyPrefix(key, a.IPv4PrefixLength, a.IPv6PrefixLength)
+		if !ok {
+			continue
+		}
+		group, exists := groups[prefix]
+		if !exists {
+			group = &prefixGroup{}
+			if previous, ok := fullZone[ratelimit.FullZoneKey{Zone: zone.Name, Key: prefix}]; ok {
+				group.excess = previous.Excess
+			}
+			groups[prefix] = group
+		}
+		// Addresses were pushed with the excess of the prefix, so only what
+		// changed since then counts towards it
+		var previousExcess int64
+		if previous, ok := fullZone[ratelimit.FullZoneKey{Zone: zone.Name, Key: key}]; ok {
+			previousExcess = previous.Excess
+		}
+		group.excess += entry.Excess - previousExcess
+		group.last = max(group.last, entry.Last)
+		group.members = append(group.members, i)
+	}
+
+	for prefix, group := range groups {
+		if group.excess < 0 {
+			group.excess = 0
+		}
+		for _, i := range group.members {
+			entry := &zone.RateLimitEntries[i]
+			entry.Excess = group.excess
+			if fullEntry, ok := newFullZone[ratelimit.FullZoneKey{Zone: zone.Name, Key: entry.Key.String(zone.RateLimitHeader)}]; ok {
+				fullEntry.Excess = group.excess
+			}
+		}
+		newFullZone[ratelimit.FullZoneKey{Zone: zone.Name, Key: prefix}] = &ratelimit.RateLimitEntry{
+			Key:    ratelimit.Key(prefix),
+			Last:   group.last,
+			Excess: group.excess,
+		}
+	}
+	return zone, newFullZone
+}
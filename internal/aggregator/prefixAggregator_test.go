@@ -0,0 +1,105 @@
+package aggregator
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+func binaryKey(ip string) ratelimit.Key {
+	return ratelimit.Key(net.ParseIP(ip).To4())
+}
+
+func excessByKey(zone ratelimit.Zone) map[string]int64 {
+	excess := make(map[string]int64)
+	for _, entry := range zone.RateLimitEntries {
+		excess[entry.Key.String(zone.RateLimitHeader)] = entry.Excess
+	}
+	return excess
+}
+
+func TestPrefixAggregator(t *testing.T) {
+	aggregator := &PrefixAggregator{
+		Aggregator:       &CompleteAggregator{},
+		IPv4PrefixLength: 24,
+		IPv6PrefixLength: 64,
+	}
+	header := ratelimit.RateLimitHeader{Key: ratelimit.BinaryRemoteAddress, Now: 400, NowMonotonic: 40}
+
+	firstRound := []ratelimit.Zone{
+		{
+			Name:            "zone1",
+			RateLimitHeader: header,
+			RateLimitEntries: []ratelimit.RateLimitEntry{
+				{Key: binaryKey("10.0.0.1"), Last: 10, Excess: 5},
+			},
+		},
+		{
+			Name:            "zone1",
+			RateLimitHeader: header,
+			RateLimitEntries: []ratelimit.RateLimitEntry{
+				{Key: binaryKey("10.0.0.2"), Last: 20, Excess: 7},
+				{Key: binaryKey("10.1.0.1"), Last: 15, Excess: 3},
+			},
+		},
+	}
+
+	zone, fullZone := aggregator.AggregateZones(firstRound, map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry{})
+	assert.Equal(t, map[string]int64{"10.0.0.1": 12, "10.0.0.2": 12, "10.1.0.1": 3}, excessByKey(zone))
+	require.Contains(t, fullZone, ratelimit.FullZoneKey{Zone: "zone1", Key: "10.0.0.0/24"})
+	assert.Equal(t, &ratelimit.RateLimitEntry{Key: ratelimit.Key("10.0.0.0/24"), Last: 20, Excess: 12}, fullZone[ratelimit.FullZoneKey{Zone: "zone1", Key: "10.0.0.0/24"}])
+	assert.Equal(t, int64(12), fullZone[ratelimit.FullZoneKey{Zone: "zone1", Key: "10.0.0.1"}].Excess)
+
+	// Pods were pushed the excess of the prefix and saw a few more requests since
+	secondRound := []ratelimit.Zone{
+		{
+			Name:            "zone1",
+			RateLimitHeader: header,
+			RateLimitEntries: []ratelimit.RateLimitEntry{
+				{Key: binaryKey("10.0.0.1"), Last: 30, Excess: 14},
+				{Key: binaryKey("10.0.0.2"), Last: 20, Excess: 12},
+			},
+		},
+		{
+			Name:            "zone1",
+			RateLimitHeader: header,
+			RateLimitEntries: []ratelimit.RateLimitEntry{
+				{Key: binaryKey("10.0.0.1"), Last: 10, Excess: 12},
+				{Key: binaryKey("10.0.0.2"), Last: 35, Excess: 13},
+				{Key: binaryKey("10.0.0.3"), Last: 35, Excess: 4},
+			},
+		},
+	}
+
+	zone, fullZone = aggregator.AggregateZones(secondRound, fullZone)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 19, "10.0.0.2": 19, "10.0.0.3": 19}, excessByKey(zone))
+	assert.Equal(t, int64(19), fullZone[ratelimit.FullZoneKey{Zone: "zone1", Key: "10.0.0.0/24"}].Excess)
+	assert.Equal(t, int64(35), fullZone[ratelimit.FullZoneKey{Zone: "zone1", Key: "10.0.0.0/24"}].Last)
+	assert.NotContains(t, fullZone, ratelimit.FullZoneKey{Zone: "zone1", Key: "10.1.0.0/24"})
+}
+
+func TestPrefixAggregatorIgnoresNonIPKeys(t *testing.T) {
+	aggregator := &PrefixAggregator{
+		Aggregator:       &CompleteAggregator{},
+		IPv4PrefixLength: 24,
+		IPv6PrefixLength: 64,
+	}
+	zonePerPod := []ratelimit.Zone{
+		{
+			Name:            "zone1",
+			RateLimitHeader: ratelimit.RateLimitHeader{Key: "$http_x_api_key"},
+			RateLimitEntries: []ratelimit.RateLimitEntry{
+				{Key: []byte("key1"), Last: 10, Excess: 5},
+				{Key: []byte("key2"), Last: 10, Excess: 7},
+			},
+		},
+	}
+
+	zone, fullZone := aggregator.AggregateZones(zonePerPod, map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry{})
+	assert.Equal(t, map[string]int64{"key1": 5, "key2": 7}, excessByKey(zone))
+	assert.Len(t, fullZone, 2)
+}
@@ -0,0 +1,141 @@
+package alerting
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/repository"
+)
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Alert is the payload of generic webhooks.
+type Alert struct {
+	Status    string    `json:"status"`
+	Rule      string    `json:"rule"`
+	Instance  string    `json:"instance"`
+	Zone      string    `json:"zone"`
+	Key       string    `json:"key"`
+	Excess    int64     `json:"excess"`
+	Threshold int64     `json:"threshold"`
+	Rounds    int       `json:"rounds"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt,omitempty"`
+}
+
+type alertID struct {
+	rule     int
+	instance string
+	zone     string
+	key      string
+}
+
+type alertState struct {
+	rounds   int
+	firing   bool
+	startsAt time.Time
+	excess   int64
+}
+
+type Notifier interface {
+	Notify(webhook Webhook, alert Alert)
+}
+
+// Evaluator checks the rules on every repository insert, notifying once when
+// an alert starts firing and once when it is resolved.
+type Evaluator struct {
+	mu       sync.Mutex
+	rules    []Rule
+	notifier Notifier
+	states   map[alertID]*alertState
+	now      func() time.Time
+}
+
+func NewEvaluator(rules []Rule, notifier Notifier) *Evaluator {
+	return &Evaluator{
+		rules:    rules,
+		notifier: notifier,
+		states:   make(map[alertID]*alertState),
+		now:      time.Now,
+	}
+}
+
+func (e *Evaluator) Observe(instance string, data []repository.Data) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	for ruleIndex, rule := range e.rules {
+		if !globMatch(rule.Instance, instance) {
+			continue
+		}
+		seen := make(map[alertID]struct{})
+		for _, d := range data {
+			if d.Excess < rule.Threshold || !globMatch(rule.Zone, d.Zone) {
+				continue
+			}
+			id := alertID{rule: ruleIndex, instance: instance, zone: d.Zone, key: d.Key}
+			seen[id] = struct{}{}
+			state, exists := e.states[id]
+			if !exists {
+				state = &alertState{startsAt: now}
+				e.states[id] = state
+			}
+			state.rounds++
+			state.excess = d.Excess
+			if !state.firing && state.rounds >= rule.Rounds {
+				state.firing = true
+				alertsCounterVec.WithLabelValues(rule.Name, instance, StatusFiring).Inc()
+				e.notifier.Notify(rule.Webhook, e.alert(StatusFiring, rule, id, state, time.Time{}))
+			}
+		}
+		for id, state := range e.states {
+			if id.rule != ruleIndex || id.instance != instance {
+				continue
+			}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			e.resolve(id, state, now)
+		}
+	}
+}
+
+// Forget resolves the firing alerts of a removed instance and drops its state.
+func (e *Evaluator) Forget(instance string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	for id, state := range e.states {
+		if id.instance == instance {
+			e.resolve(id, state, now)
+		}
+	}
+}
+
+// resolve notifies the end of an alert that fired and drops its state, must be called with mu held.
+func (e *Evaluator) resolve(id alertID, state *alertState, now time.Time) {
+	rule := e.rules[id.rule]
+	if state.firing {
+		alertsCounterVec.WithLabelValues(rule.Name, id.instance, StatusResolved).Inc()
+		e.notifier.Notify(rule.Webhook, e.alert(StatusResolved, rule, id, state, now))
+	}
+	delete(e.states, id)
+}
+
+func (e *Evaluator) alert(status string, rule Rule, id alertID, state *alertState, endsAt time.Time) Alert {
+	return Alert{
+		Status:    status,
+		Rule:      rule.Name,
+		Instance:  id.instance,
+		Zone:      id.zone,
+		Key:       id.key,
+		Excess:    state.excess,
+		Threshold: rule.Threshold,
+		Rounds:    state.rounds,
+		StartsAt:  state.startsAt,
+		EndsAt:    endsAt,
+	}
+}
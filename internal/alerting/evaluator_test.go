@@ -0,0 +1,102 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/repository"
+)
+
+type notifierSpy struct {
+	alerts []Alert
+}
+
+func (n *notifierSpy) Notify(webhook Webhook, alert Alert) {
+	n.alerts = append(n.alerts, alert)
+}
+
+func TestEvaluator(t *testing.T) {
+	rules := []Rule{{
+		Name:      "abuse",
+		Instance:  "instance-*",
+		Zone:      "one",
+		Threshold: 1000,
+		Rounds:    2,
+		Webhook:   Webhook{URL: "http://localhost", Format: FormatGeneric},
+	}}
+	spy := &notifierSpy{}
+	evaluator := NewEvaluator(rules, spy)
+	now := time.Now()
+	evaluator.now = func() time.Time { return now }
+
+	abusive := []repository.Data{
+		{Key: "10.0.0.1", Zone: "one", Excess: 1500},
+		{Key: "10.0.0.2", Zone: "one", Excess: 10},
+		{Key: "10.0.0.3", Zone: "two", Excess: 5000},
+	}
+
+	evaluator.Observe("instance-a", abusive)
+	assert.Empty(t, spy.alerts, "should wait for the sustained rounds")
+
+	evaluator.Observe("instance-a", abusive)
+	require.Len(t, spy.alerts, 1)
+	assert.Equal(t, Alert{
+		Status:    StatusFiring,
+		Rule:      "abuse",
+		Instance:  "instance-a",
+		Zone:      "one",
+		Key:       "10.0.0.1",
+		Excess:    1500,
+		Threshold: 1000,
+		Rounds:    2,
+		StartsAt:  now,
+	}, spy.alerts[0])
+
+	evaluator.Observe("instance-a", abusive)
+	assert.Len(t, spy.alerts, 1, "should not notify twice while firing")
+
+	evaluator.Observe("other", abusive)
+	evaluator.Observe("other", abusive)
+	assert.Len(t, spy.alerts, 1, "should ignore instances not matching the rule")
+
+	evaluator.Observe("instance-a", []repository.Data{{Key: "10.0.0.1", Zone: "one", Excess: 10}})
+	require.Len(t, spy.alerts, 2)
+	assert.Equal(t, StatusResolved, spy.alerts[1].Status)
+	assert.Equal(t, "10.0.0.1", spy.alerts[1].Key)
+	assert.Equal(t, now, spy.alerts[1].EndsAt)
+
+	evaluator.Observe("instance-a", abusive)
+	evaluator.Observe("instance-a", []repository.Data{})
+	assert.Len(t, spy.alerts, 2, "should not resolve alerts that never fired")
+}
+
+func TestEvaluatorForget(t *testing.T) {
+	rules := []Rule{{Name: "abuse", Instance: "*", Zone: "*", Threshold: 1000, Rounds: 1}}
+	spy := &notifierSpy{}
+	evaluator := NewEvaluator(rules, spy)
+	now := time.Now()
+	evaluator.now = func() time.Time { return now }
+
+	evaluator.Observe("instance-a", []repository.Data{
+		{Key: "10.0.0.1", Zone: "one", Excess: 1500},
+		{Key: "10.0.0.2", Zone: "two", Excess: 2000},
+	})
+	evaluator.Observe("instance-b", []repository.Data{{Key: "10.0.0.1", Zone: "one", Excess: 1500}})
+	require.Len(t, spy.alerts, 3)
+
+	// A removed instance is never observed again, its alerts are resolved right away
+	evaluator.Forget("instance-a")
+	require.Len(t, spy.alerts, 5)
+	for _, alert := range spy.alerts[3:] {
+		assert.Equal(t, StatusResolved, alert.Status)
+		assert.Equal(t, "instance-a", alert.Instance)
+		assert.Equal(t, now, alert.EndsAt)
+	}
+	assert.Len(t, evaluator.states, 1)
+
+	evaluator.Forget("instance-a")
+	assert.Len(t, spy.alerts, 5)
+}
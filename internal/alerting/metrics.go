@@ -0,0 +1,23 @@
+package alerting
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var alertsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "alerts_total",
+	Help:      "Total number of alert notifications by rule and status",
+}, []string{"rule", "rpaas_instance", "status"})
+
+var webhookFailuresCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "alert_webhook_failures_total",
+	Help:      "Total number of alert webhooks that could not be delivered",
+}, []string{"rule"})
+
+func init() {
+	metrics.Registry.MustRegister(alertsCounterVec)
+	metrics.Registry.MustRegister(webhookFailuresCounterVec)
+}
@@ -0,0 +1,91 @@
+package alerting
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	FormatGeneric = "generic"
+	FormatSlack   = "slack"
+)
+
+// Rule fires when the aggregated excess of a key stays at or above Threshold for Rounds consecutive rounds.
+// Instance and Zone are glob patterns, empty matches everything.
+type Rule struct {
+	Name      string  `json:"name"`
+	Instance  string  `json:"instance,omitempty"`
+	Zone      string  `json:"zone,omitempty"`
+	Threshold int64   `json:"threshold"`
+	Rounds    int     `json:"rounds,omitempty"`
+	Webhook   Webhook `json:"webhook"`
+}
+
+type Webhook struct {
+	URL    string `json:"url"`
+	Format string `json:"format,omitempty"`
+}
+
+type RuleSet struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules reads alerting rules from a YAML or JSON file.
+func LoadRules(filename string) ([]Rule, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading alerting rules: %w", err)
+	}
+	var ruleSet RuleSet
+	if err := yaml.UnmarshalStrict(content, &ruleSet); err != nil {
+		return nil, fmt.Errorf("error parsing alerting rules %s: %w", filename, err)
+	}
+	for i := range ruleSet.Rules {
+		if err := ruleSet.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid alerting rule %d: %w", i, err)
+		}
+	}
+	return ruleSet.Rules, nil
+}
+
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Threshold <= 0 {
+		return fmt.Errorf("rule %s: threshold must be positive", r.Name)
+	}
+	if r.Rounds == 0 {
+		r.Rounds = 1
+	}
+	if r.Rounds < 0 {
+		return fmt.Errorf("rule %s: rounds must be positive", r.Name)
+	}
+	if r.Webhook.URL == "" {
+		return fmt.Errorf("rule %s: webhook url is required", r.Name)
+	}
+	switch r.Webhook.Format {
+	case "":
+		r.Webhook.Format = FormatGeneric
+	case FormatGeneric, FormatSlack:
+	default:
+		return fmt.Errorf("rule %s: unknown webhook format %q", r.Name, r.Webhook.Format)
+	}
+	for _, pattern := range []string{r.Instance, r.Zone} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("rule %s: invalid pattern %q: %w", r.Name, pattern, err)
+		}
+	}
+	return nil
+}
+
+func globMatch(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
@@ -0,0 +1,98 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+type webhookRequest struct {
+	webhook Webhook
+	alert   Alert
+}
+
+// WebhookNotifier delivers alerts in the background so slow webhooks never delay the repository.
+type WebhookNotifier struct {
+	client   *http.Client
+	logger   *slog.Logger
+	requests chan webhookRequest
+}
+
+func NewWebhookNotifier(logger *slog.Logger, timeout time.Duration, queueSize int) *WebhookNotifier {
+	n := &WebhookNotifier{
+		client:   &http.Client{Timeout: timeout},
+		logger:   logger,
+		requests: make(chan webhookRequest, queueSize),
+	}
+	go n.run()
+	return n
+}
+
+func (n *WebhookNotifier) Notify(webhook Webhook, alert Alert) {
+	select {
+	case n.requests <- webhookRequest{webhook: webhook, alert: alert}:
+	default:
+		n.logger.Error("Alert webhook queue is full - dropping alert", "rule", alert.Rule, "instance", alert.Instance, "status", alert.Status)
+		webhookFailuresCounterVec.WithLabelValues(alert.Rule).Inc()
+	}
+}
+
+func (n *WebhookNotifier) run() {
+	for req := range n.requests {
+		if err := n.send(req.webhook, req.alert); err != nil {
+			n.logger.Error("Error sending alert webhook", "rule", req.alert.Rule, "instance", req.alert.Instance, "status", req.alert.Status, "error", err)
+			webhookFailuresCounterVec.WithLabelValues(req.alert.Rule).Inc()
+		}
+	}
+}
+
+func (n *WebhookNotifier) send(webhook Webhook, alert Alert) error {
+	var payload any = alert
+	if webhook.Format == FormatSlack {
+		payload = slackMessage{Text: slackText(alert)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding alert: %w", err)
+	}
+	resp, err := n.client.Post(webhook.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The url.Error of the client repeats the URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("error sending request to %s: %w", webhook.origin(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, webhook.origin())
+	}
+	return nil
+}
+
+// origin returns the scheme and host of the webhook URL, whose path and query
+// are often the credentials, as in Slack incoming webhooks.
+func (w Webhook) origin() string {
+	u, err := url.Parse(w.URL)
+	if err != nil || u.Host == "" {
+		return "invalid webhook URL"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func slackText(alert Alert) string {
+	if alert.Status == StatusResolved {
+		return fmt.Sprintf(":white_check_mark: [%s] resolved: key `%s` on instance `%s` zone `%s` is back under %d excess", alert.Rule, alert.Key, alert.Instance, alert.Zone, alert.Threshold)
+	}
+	return fmt.Sprintf(":rotating_light: [%s] key `%s` on instance `%s` zone `%s` has excess %d (threshold %d) for %d rounds", alert.Rule, alert.Key, alert.Instance, alert.Zone, alert.Excess, alert.Threshold, alert.Rounds)
+}
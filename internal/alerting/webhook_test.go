@@ -0,0 +1,94 @@
+package alerting
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan map[string]any, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var payload map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(slog.New(slog.NewTextHandler(io.Discard, nil)), time.Second, 10)
+	alert := Alert{Status: StatusFiring, Rule: "abuse", Instance: "instance-a", Zone: "one", Key: "10.0.0.1", Excess: 1500, Threshold: 1000, Rounds: 2}
+
+	notifier.Notify(Webhook{URL: server.URL, Format: FormatGeneric}, alert)
+	payload := <-received
+	assert.Equal(t, "firing", payload["status"])
+	assert.Equal(t, "10.0.0.1", payload["key"])
+	assert.Equal(t, float64(1500), payload["excess"])
+
+	notifier.Notify(Webhook{URL: server.URL, Format: FormatSlack}, alert)
+	payload = <-received
+	assert.Contains(t, payload["text"], "`10.0.0.1`")
+	assert.Contains(t, payload["text"], "instance-a")
+}
+
+func TestWebhookNotifierFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier := &WebhookNotifier{client: server.Client()}
+	err := notifier.send(Webhook{URL: server.URL + "/services/T000/B000/secret"}, Alert{})
+	assert.ErrorContains(t, err, "unexpected status code 500 from "+server.URL)
+	assert.NotContains(t, err.Error(), "secret")
+
+	server.Close()
+	err = notifier.send(Webhook{URL: server.URL + "/services/T000/B000/secret"}, Alert{})
+	assert.ErrorContains(t, err, "error sending request to "+server.URL)
+	assert.NotContains(t, err.Error(), "secret")
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(`
+rules:
+- name: abuse
+  zone: one
+  threshold: 1000
+  webhook:
+    url: http://localhost/hook
+    format: slack
+`), 0o600))
+	rules, err := LoadRules(filename)
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{{
+		Name:      "abuse",
+		Zone:      "one",
+		Threshold: 1000,
+		Rounds:    1,
+		Webhook:   Webhook{URL: "http://localhost/hook", Format: FormatSlack},
+	}}, rules)
+
+	invalidRules := map[string]string{
+		"threshold": "rules: [{name: a, threshold: 0, webhook: {url: http://localhost}}]",
+		"webhook":   "rules: [{name: a, threshold: 1}]",
+		"format":    "rules: [{name: a, threshold: 1, webhook: {url: http://localhost, format: teams}}]",
+		"pattern":   "rules: [{name: a, zone: '[', threshold: 1, webhook: {url: http://localhost}}]",
+		"unknown":   "rules: [{name: a, treshold: 1, webhook: {url: http://localhost}}]",
+	}
+	for name, content := range invalidRules {
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0o600))
+		_, err := LoadRules(filename)
+		assert.Error(t, err, name)
+	}
+}
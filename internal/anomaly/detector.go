@@ -0,0 +1,216 @@
+package anomaly
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/repository"
+)
+
+const (
+	SignalTotalExcess = "total_excess"
+	SignalEntries     = "entries"
+	SignalNewKeyRate  = "new_key_rate"
+)
+
+var signals = []string{SignalTotalExcess, SignalEntries, SignalNewKeyRate}
+
+type Anomaly struct {
+	Instance string    `json:"instance"`
+	Zone     string    `json:"zone"`
+	Signal   string    `json:"signal"`
+	Value    float64   `json:"value"`
+	Mean     float64   `json:"mean"`
+	StdDev   float64   `json:"stdDev"`
+	Score    float64   `json:"score"`
+	Time     time.Time `json:"time"`
+}
+
+// EventSink is told when a signal becomes anomalous, not on every anomalous round.
+type EventSink interface {
+	Emit(anomaly Anomaly)
+}
+
+type Settings struct {
+	// Alpha is the EWMA smoothing factor, higher values forget the past faster.
+	Alpha float64
+	// Threshold is the z-score above which a value is an anomaly.
+	Threshold float64
+	// WarmupRounds are observed before anything is flagged.
+	WarmupRounds int
+	// MaxAnomalies is how many recent anomalies are kept for the API.
+	MaxAnomalies int
+}
+
+type ewma struct {
+	mean     float64
+	variance float64
+	rounds   int
+	flagged  bool
+}
+
+// score returns the z-score of x against the moving average, before x is added to it.
+// The standard deviation has a floor of 10% of the mean so flat series don't flag tiny changes.
+func (e *ewma) score(x float64) (float64, float64) {
+	stdDev := math.Max(math.Sqrt(e.variance), math.Max(1, 0.1*math.Abs(e.mean)))
+	return (x - e.mean) / stdDev, stdDev
+}
+
+func (e *ewma) add(x, alpha float64) {
+	if e.rounds == 0 {
+		e.mean = x
+	} else {
+		diff := x - e.mean
+		increment := alpha * diff
+		e.mean += increment
+		e.variance = (1 - alpha) * (e.variance + diff*increment)
+	}
+	e.rounds++
+}
+
+type zoneID struct {
+	instance string
+	zone     string
+}
+
+type zoneState struct {
+	keys    map[string]struct{}
+	signals map[string]*ewma
+}
+
+// Detector flags spikes of the per-zone traffic of each instance, such as botnets spraying new IPs.
+type Detector struct {
+	mu        sync.Mutex
+	settings  Settings
+	sink      EventSink
+	zones     map[zoneID]*zoneState
+	anomalies []Anomaly
+	now       func() time.Time
+}
+
+func NewDetector(settings Settings, sink EventSink) *Detector {
+	return &Detector{
+		settings: settings,
+		sink:     sink,
+		zones:    make(map[zoneID]*zoneState),
+		now:      time.Now,
+	}
+}
+
+func (d *Detector) Observe(instance string, data []repository.Data) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+
+	keysPerZone := make(map[string]map[string]struct{})
+	totalExcessPerZone := make(map[string]float64)
+	for _, entry := range data {
+		if _, ok := keysPerZone[entry.Zone]; !ok {
+			keysPerZone[entry.Zone] = make(map[string]struct{})
+		}
+		keysPerZone[entry.Zone][entry.Key] = struct{}{}
+		totalExcessPerZone[entry.Zone] += float64(entry.Excess)
+	}
+
+	for zone, keys := range keysPerZone {
+		id := zoneID{instance: instance, zone: zone}
+		state, exists := d.zones[id]
+		if !exists {
+			state = &zoneState{signals: make(map[string]*ewma)}
+			for _, signal := range signals {
+				state.signals[signal] = &ewma{}
+			}
+			d.zones[id] = state
+		}
+
+		newKeys := 0
+		for key := range keys {
+			if _, seen := state.keys[key]; !seen {
+				newKeys++
+			}
+		}
+		values := map[string]float64{
+			SignalTotalExcess: totalExcessPerZone[zone],
+			SignalEntries:     float64(len(keys)),
+		}
+		// The first round has no previous keys to compare with
+		if state.keys != nil {
+			values[SignalNewKeyRate] = float64(newKeys)
+		}
+		state.keys = keys
+
+		for signal, value := range values {
+			d.evaluate(instance, zone, signal, value, state.signals[signal], now)
+		}
+	}
+}
+
+// Forget drops the moving averages of a removed instance, keeping its recent anomalies.
+func (d *Detector) Forget(instance string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id := range d.zones {
+		if id.instance != instance {
+			continue
+		}
+		for _, signal := range signals {
+			anomalyScoreGaugeVec.DeleteLabelValues(instance, id.zone, signal)
+		}
+		delete(d.zones, id)
+	}
+}
+
+func (d *Detector) evaluate(instance, zone, signal string, value float64, series *ewma, now time.Time) {
+	score, stdDev := series.score(value)
+	if series.rounds < d.settings.WarmupRounds {
+		score = 0
+	}
+	anomalyScoreGaugeVec.WithLabelValues(instance, zone, signal).Set(score)
+	if score >= d.settings.Threshold {
+		anomaly := Anomaly{
+			Instance: instance,
+			Zone:     zone,
+			Signal:   signal,
+			Value:    value,
+			Mean:     series.mean,
+			StdDev:   stdDev,
+			Score:    score,
+			Time:     now,
+		}
+		anomaliesCounterVec.WithLabelValues(instance, zone, signal).Inc()
+		d.record(anomaly)
+		if !series.flagged && d.sink != nil {
+			d.sink.Emit(anomaly)
+		}
+		series.flagged = true
+	} else {
+		series.flagged = false
+	}
+	// Anomalous values are learned too, so a lasting change of traffic becomes the new baseline
+	series.add(value, d.settings.Alpha)
+}
+
+func (d *Detector) record(anomaly Anomaly) {
+	d.anomalies = append(d.anomalies, anomaly)
+	if len(d.anomalies) > d.settings.MaxAnomalies {
+		d.anomalies = d.anomalies[len(d.anomalies)-d.settings.MaxAnomalies:]
+	}
+}
+
+// Anomalies returns the recent anomalies of an instance, or of every instance when it is empty, newest first.
+func (d *Detector) Anomalies(instance string) []Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	anomalies := []Anomaly{}
+	for _, anomaly := range d.anomalies {
+		if instance == "" || anomaly.Instance == instance {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Time.After(anomalies[j].Time)
+	})
+	return anomalies
+}
@@ -0,0 +1,120 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/auth"
+	"github.com/tsuru/rate-limit-control-plane/internal/repository"
+)
+
+type sinkSpy struct {
+	anomalies []Anomaly
+}
+
+func (s *sinkSpy) Emit(anomaly Anomaly) {
+	s.anomalies = append(s.anomalies, anomaly)
+}
+
+type fakeResolver map[string]auth.InstanceInfo
+
+func (f fakeResolver) ResolveInstance(ctx context.Context, instance string) (auth.InstanceInfo, error) {
+	info, ok := f[instance]
+	if !ok {
+		return auth.InstanceInfo{}, auth.ErrInstanceUnknown
+	}
+	return info, nil
+}
+
+func steadyRound(round int) []repository.Data {
+	data := []repository.Data{}
+	for i := 0; i < 10; i++ {
+		data = append(data, repository.Data{Key: fmt.Sprintf("10.0.0.%d", i), Zone: "one", Excess: int64(100 + (round+i)%7)})
+	}
+	return data
+}
+
+func TestDetector(t *testing.T) {
+	settings := Settings{Alpha: 0.2, Threshold: 4, WarmupRounds: 10, MaxAnomalies: 5}
+
+	t.Run("should not flag steady traffic", func(t *testing.T) {
+		sink := &sinkSpy{}
+		detector := NewDetector(settings, sink)
+		for round := 0; round < 50; round++ {
+			detector.Observe("instance-a", steadyRound(round))
+		}
+		assert.Empty(t, sink.anomalies)
+		assert.Empty(t, detector.Anomalies(""))
+	})
+
+	t.Run("should flag an IP spray once while it lasts", func(t *testing.T) {
+		sink := &sinkSpy{}
+		detector := NewDetector(settings, sink)
+		now := time.Now()
+		detector.now = func() time.Time { return now }
+		for round := 0; round < 30; round++ {
+			detector.Observe("instance-a", steadyRound(round))
+		}
+		spray := steadyRound(0)
+		for i := 0; i < 500; i++ {
+			spray = append(spray, repository.Data{Key: fmt.Sprintf("172.16.%d.%d", i/256, i%256), Zone: "one", Excess: 100})
+		}
+		detector.Observe("instance-a", spray)
+		detector.Observe("instance-a", spray)
+
+		signals := map[string]bool{}
+		for _, anomaly := range sink.anomalies {
+			assert.Equal(t, "instance-a", anomaly.Instance)
+			assert.Equal(t, "one", anomaly.Zone)
+			assert.GreaterOrEqual(t, anomaly.Score, settings.Threshold)
+			assert.False(t, signals[anomaly.Signal], "signal %s emitted twice", anomaly.Signal)
+			signals[anomaly.Signal] = true
+		}
+		assert.True(t, signals[SignalEntries])
+		assert.True(t, signals[SignalNewKeyRate])
+		assert.True(t, signals[SignalTotalExcess])
+
+		anomalies := detector.Anomalies("instance-a")
+		assert.Len(t, anomalies, 3)
+		assert.Empty(t, detector.Anomalies("instance-b"))
+	})
+
+	t.Run("should forget removed instances", func(t *testing.T) {
+		detector := NewDetector(settings, &sinkSpy{})
+		detector.Observe("instance-a", steadyRound(0))
+		detector.Observe("instance-b", steadyRound(0))
+		require.Len(t, detector.zones, 2)
+		detector.Forget("instance-a")
+		assert.Len(t, detector.zones, 1)
+		assert.Contains(t, detector.zones, zoneID{instance: "instance-b", zone: "one"})
+	})
+
+	t.Run("should not flag during warmup", func(t *testing.T) {
+		sink := &sinkSpy{}
+		detector := NewDetector(settings, sink)
+		detector.Observe("instance-a", steadyRound(0))
+		detector.Observe("instance-a", []repository.Data{{Key: "a", Zone: "one", Excess: 1_000_000}})
+		assert.Empty(t, sink.anomalies)
+	})
+}
+
+func TestKubernetesEventSink(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	sink := &KubernetesEventSink{
+		Recorder: recorder,
+		Resolver: fakeResolver{"instance-a": {Name: "instance-a", Namespace: "rpaasv2"}},
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	sink.Emit(Anomaly{Instance: "instance-a", Zone: "one", Signal: SignalNewKeyRate, Value: 500, Mean: 1, Score: 50})
+	sink.Emit(Anomaly{Instance: "unknown", Zone: "one", Signal: SignalNewKeyRate})
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Warning RateLimitAnomaly Spike of new_key_rate on zone one: 500 against an average of 1 (z-score 50.0)", <-recorder.Events)
+}
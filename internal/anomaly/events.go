@@ -0,0 +1,42 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	rpaasOperatorv1alpha1 "github.com/tsuru/rpaas-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/auth"
+)
+
+const eventReason = "RateLimitAnomaly"
+
+// KubernetesEventSink records anomalies as warning Events of the RpaasInstance.
+type KubernetesEventSink struct {
+	Recorder record.EventRecorder
+	Resolver auth.InstanceResolver
+	Logger   *slog.Logger
+}
+
+func (s *KubernetesEventSink) Emit(anomaly Anomaly) {
+	info, err := s.Resolver.ResolveInstance(context.Background(), anomaly.Instance)
+	if err != nil {
+		s.Logger.Error("Error resolving instance for anomaly event", "instance", anomaly.Instance, "error", err)
+		return
+	}
+	instance := &rpaasOperatorv1alpha1.RpaasInstance{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rpaasOperatorv1alpha1.GroupVersion.String(),
+			Kind:       "RpaasInstance",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: info.Name, Namespace: info.Namespace},
+	}
+	s.Recorder.Event(instance, corev1.EventTypeWarning, eventReason, fmt.Sprintf(
+		"Spike of %s on zone %s: %.0f against an average of %.0f (z-score %.1f)",
+		anomaly.Signal, anomaly.Zone, anomaly.Value, anomaly.Mean, anomaly.Score,
+	))
+}
@@ -0,0 +1,23 @@
+package anomaly
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var anomalyScoreGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_instance_anomaly_score",
+	Help:      "Z-score of the last round of each zone signal against its moving average",
+}, []string{"rpaas_instance", "zone", "signal"})
+
+var anomaliesCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_instance_anomalies_total",
+	Help:      "Total number of rounds flagged as anomalous by zone signal",
+}, []string{"rpaas_instance", "zone", "signal"})
+
+func init() {
+	metrics.Registry.MustRegister(anomalyScoreGaugeVec)
+	metrics.Registry.MustRegister(anomaliesCounterVec)
+}
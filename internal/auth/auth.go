@@ -0,0 +1,87 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrInstanceUnknown = errors.New("rpaas instance not found")
+)
+
+type UserInfo struct {
+	Name   string
+	Groups []string
+}
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*UserInfo, error)
+}
+
+type Authorizer interface {
+	// Authorize reports whether the user may see the data of the given RpaasInstance,
+	// or change it for the authorizers checking writes.
+	Authorize(ctx context.Context, user *UserInfo, instance string) (bool, error)
+}
+
+type InstanceInfo struct {
+	Name      string
+	Namespace string
+	TeamOwner string
+}
+
+type InstanceResolver interface {
+	ResolveInstance(ctx context.Context, instance string) (InstanceInfo, error)
+}
+
+// AllowAll authenticates any request as an anonymous user and authorizes every instance.
+type AllowAll struct{}
+
+func (AllowAll) Authenticate(ctx context.Context, token string) (*UserInfo, error) {
+	return &UserInfo{Name: "anonymous"}, nil
+}
+
+func (AllowAll) Authorize(ctx context.Context, user *UserInfo, instance string) (bool, error) {
+	return true, nil
+}
+
+// AdminGroupsAuthorizer authorizes members of the admin groups for every
+// instance and delegates everybody else to the wrapped Authorizer.
+type AdminGroupsAuthorizer struct {
+	AdminGroups []string
+	Authorizer  Authorizer
+}
+
+func (a *AdminGroupsAuthorizer) Authorize(ctx context.Context, user *UserInfo, instance string) (bool, error) {
+	for _, group := range user.Groups {
+		if slices.Contains(a.AdminGroups, group) {
+			return true, nil
+		}
+	}
+	return a.Authorizer.Authorize(ctx, user, instance)
+}
+
+// TeamAuthorizer authorizes users whose groups contain the tsuru team owning the instance.
+type TeamAuthorizer struct {
+	Resolver InstanceResolver
+}
+
+func (a *TeamAuthorizer) Authorize(ctx context.Context, user *UserInfo, instance string) (bool, error) {
+	info, err := a.Resolver.ResolveInstance(ctx, instance)
+	if err != nil {
+		if errors.Is(err, ErrInstanceUnknown) {
+			return false, nil
+		}
+		return false, err
+	}
+	if info.TeamOwner == "" {
+		return false, nil
+	}
+	return slices.Contains(user.Groups, info.TeamOwner), nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rpaasOperatorv1alpha1 "github.com/tsuru/rpaas-operator/api/v1alpha1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeTokenReviewer struct {
+	users map[string]authenticationv1.UserInfo
+}
+
+func (f *fakeTokenReviewer) Create(ctx context.Context, review *authenticationv1.TokenReview, opts metav1.CreateOptions) (*authenticationv1.TokenReview, error) {
+	user, ok := f.users[review.Spec.Token]
+	review.Status.Authenticated = ok
+	review.Status.User = user
+	return review, nil
+}
+
+type fakeAccessReviewer struct {
+	lastReview *authorizationv1.SubjectAccessReview
+	allowed    map[string]string
+}
+
+func (f *fakeAccessReviewer) Create(ctx context.Context, review *authorizationv1.SubjectAccessReview, opts metav1.CreateOptions) (*authorizationv1.SubjectAccessReview, error) {
+	f.lastReview = review
+	review.Status.Allowed = f.allowed[review.Spec.User] == review.Spec.ResourceAttributes.Name
+	return review, nil
+}
+
+type fakeResolver map[string]InstanceInfo
+
+func (f fakeResolver) ResolveInstance(ctx context.Context, instance string) (InstanceInfo, error) {
+	info, ok := f[instance]
+	if !ok {
+		return InstanceInfo{}, ErrInstanceUnknown
+	}
+	return info, nil
+}
+
+func TestStaticTokenAuthenticator(t *testing.T) {
+	tokens, err := parseTokenFile(strings.NewReader(`# comment
+token1,alice,1,"team-a,team-b"
+token2,bob
+`))
+	require.NoError(t, err)
+	authenticator := NewStaticTokenAuthenticator(tokens)
+
+	user, err := authenticator.Authenticate(context.Background(), "token1")
+	require.NoError(t, err)
+	assert.Equal(t, &UserInfo{Name: "alice", Groups: []string{"team-a", "team-b"}}, user)
+
+	user, err = authenticator.Authenticate(context.Background(), "token2")
+	require.NoError(t, err)
+	assert.Equal(t, &UserInfo{Name: "bob"}, user)
+
+	_, err = authenticator.Authenticate(context.Background(), "invalid")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = authenticator.Authenticate(context.Background(), "")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	_, err = parseTokenFile(strings.NewReader("only-token\n"))
+	assert.Error(t, err)
+}
+
+func TestKubernetesAuthenticator(t *testing.T) {
+	authenticator := &KubernetesAuthenticator{Reviewer: &fakeTokenReviewer{users: map[string]authenticationv1.UserInfo{
+		"valid": {Username: "system:serviceaccount:default:reader", Groups: []string{"team-a"}},
+	}}}
+
+	user, err := authenticator.Authenticate(context.Background(), "valid")
+	require.NoError(t, err)
+	assert.Equal(t, &UserInfo{Name: "system:serviceaccount:default:reader", Groups: []string{"team-a"}}, user)
+
+	_, err = authenticator.Authenticate(context.Background(), "invalid")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestKubernetesAuthorizer(t *testing.T) {
+	reviewer := &fakeAccessReviewer{allowed: map[string]string{"alice": "instance-a"}}
+	authorizer := &KubernetesAuthorizer{
+		Reviewer: reviewer,
+		Resolver: fakeResolver{"instance-a": {Name: "instance-a", Namespace: "rpaasv2"}},
+	}
+
+	allowed, err := authorizer.Authorize(context.Background(), &UserInfo{Name: "alice"}, "instance-a")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, "rpaasv2", reviewer.lastReview.Spec.ResourceAttributes.Namespace)
+	assert.Equal(t, "rpaasinstances", reviewer.lastReview.Spec.ResourceAttributes.Resource)
+	assert.Equal(t, "get", reviewer.lastReview.Spec.ResourceAttributes.Verb)
+
+	allowed, err = authorizer.Authorize(context.Background(), &UserInfo{Name: "bob"}, "instance-a")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = authorizer.Authorize(context.Background(), &UserInfo{Name: "alice"}, "unknown")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	authorizer.Verb = "update"
+	_, err = authorizer.Authorize(context.Background(), &UserInfo{Name: "alice"}, "instance-a")
+	require.NoError(t, err)
+	assert.Equal(t, "update", reviewer.lastReview.Spec.ResourceAttributes.Verb)
+}
+
+func TestTeamAuthorizer(t *testing.T) {
+	authorizer := &AdminGroupsAuthorizer{
+		AdminGroups: []string{"admin"},
+		Authorizer: &TeamAuthorizer{Resolver: fakeResolver{
+			"instance-a": {Name: "instance-a", TeamOwner: "team-a"},
+			"orphan":     {Name: "orphan"},
+		}},
+	}
+
+	tests := []struct {
+		user     UserInfo
+		instance string
+		allowed  bool
+	}{
+		{user: UserInfo{Name: "alice", Groups: []string{"team-a"}}, instance: "instance-a", allowed: true},
+		{user: UserInfo{Name: "bob", Groups: []string{"team-b"}}, instance: "instance-a", allowed: false},
+		{user: UserInfo{Name: "bob", Groups: []string{"team-b"}}, instance: "orphan", allowed: false},
+		{user: UserInfo{Name: "bob", Groups: []string{"team-b"}}, instance: "unknown", allowed: false},
+		{user: UserInfo{Name: "root", Groups: []string{"admin"}}, instance: "orphan", allowed: true},
+	}
+	for _, tt := range tests {
+		allowed, err := authorizer.Authorize(context.Background(), &tt.user, tt.instance)
+		require.NoError(t, err)
+		assert.Equal(t, tt.allowed, allowed, "user %s instance %s", tt.user.Name, tt.instance)
+	}
+}
+
+func TestKubernetesInstanceResolver(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, rpaasOperatorv1alpha1.AddToScheme(scheme))
+	instance := &rpaasOperatorv1alpha1.RpaasInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "instance-a",
+			Namespace: "rpaasv2",
+			Labels:    map[string]string{rpaasOperatorv1alpha1.RpaasOperatorTeamOwnerLabelKey: "team-a"},
+		},
+	}
+	resolver := &KubernetesInstanceResolver{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(instance).Build()}
+
+	info, err := resolver.ResolveInstance(context.Background(), "instance-a")
+	require.NoError(t, err)
+	assert.Equal(t, InstanceInfo{Name: "instance-a", Namespace: "rpaasv2", TeamOwner: "team-a"}, info)
+
+	_, err = resolver.ResolveInstance(context.Background(), "unknown")
+	assert.True(t, errors.Is(err, ErrInstanceUnknown))
+}
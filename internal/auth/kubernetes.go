@@ -0,0 +1,121 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	rpaasOperatorv1alpha1 "github.com/tsuru/rpaas-operator/api/v1alpha1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TokenReviewer is satisfied by the client-go TokenReviews client.
+type TokenReviewer interface {
+	Create(ctx context.Context, tokenReview *authenticationv1.TokenReview, opts metav1.CreateOptions) (*authenticationv1.TokenReview, error)
+}
+
+// SubjectAccessReviewer is satisfied by the client-go SubjectAccessReviews client.
+type SubjectAccessReviewer interface {
+	Create(ctx context.Context, subjectAccessReview *authorizationv1.SubjectAccessReview, opts metav1.CreateOptions) (*authorizationv1.SubjectAccessReview, error)
+}
+
+// KubernetesAuthenticator validates bearer tokens with the Kubernetes TokenReview API.
+type KubernetesAuthenticator struct {
+	Reviewer  TokenReviewer
+	Audiences []string
+}
+
+func (a *KubernetesAuthenticator) Authenticate(ctx context.Context, token string) (*UserInfo, error) {
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	review, err := a.Reviewer.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: a.Audiences,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error creating token review: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, ErrUnauthenticated
+	}
+	return &UserInfo{
+		Name:   review.Status.User.Username,
+		Groups: review.Status.User.Groups,
+	}, nil
+}
+
+// KubernetesAuthorizer asks the Kubernetes SubjectAccessReview API whether the
+// user is allowed to get the RpaasInstance, or to use Verb on it when set.
+type KubernetesAuthorizer struct {
+	Reviewer SubjectAccessReviewer
+	Resolver InstanceResolver
+	Verb     string
+}
+
+func (a *KubernetesAuthorizer) Authorize(ctx context.Context, user *UserInfo, instance string) (bool, error) {
+	info, err := a.Resolver.ResolveInstance(ctx, instance)
+	if err != nil {
+		if errors.Is(err, ErrInstanceUnknown) {
+			return false, nil
+		}
+		return false, err
+	}
+	verb := a.Verb
+	if verb == "" {
+		verb = "get"
+	}
+	review, err := a.Reviewer.Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Name,
+			Groups: user.Groups,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:     rpaasOperatorv1alpha1.GroupVersion.Group,
+				Resource:  "rpaasinstances",
+				Verb:      verb,
+				Namespace: info.Namespace,
+				Name:      info.Name,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("error creating subject access review: %w", err)
+	}
+	return review.Status.Allowed, nil
+}
+
+// KubernetesInstanceResolver finds RpaasInstances by name using a controller-runtime client.
+type KubernetesInstanceResolver struct {
+	Client    client.Reader
+	Namespace string
+}
+
+func (r *KubernetesInstanceResolver) ResolveInstance(ctx context.Context, instance string) (InstanceInfo, error) {
+	var list rpaasOperatorv1alpha1.RpaasInstanceList
+	opts := []client.ListOption{}
+	if r.Namespace != "" {
+		opts = append(opts, client.InNamespace(r.Namespace))
+	}
+	if err := r.Client.List(ctx, &list, opts...); err != nil {
+		return InstanceInfo{}, fmt.Errorf("error listing RpaasInstances: %w", err)
+	}
+	for _, item := range list.Items {
+		if item.Name == instance {
+			return InstanceInfo{
+				Name:      item.Name,
+				Namespace: item.Namespace,
+				TeamOwner: item.TeamOwner(),
+			}, nil
+		}
+	}
+	return InstanceInfo{}, fmt.Errorf("%w: %s", ErrInstanceUnknown, instance)
+}
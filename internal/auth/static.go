@@ -0,0 +1,83 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// StaticTokenAuthenticator authenticates bearer tokens against a fixed list of tokens.
+type StaticTokenAuthenticator struct {
+	tokens map[string]UserInfo
+}
+
+func NewStaticTokenAuthenticator(tokens map[string]UserInfo) *StaticTokenAuthenticator {
+	return &StaticTokenAuthenticator{tokens: tokens}
+}
+
+// NewStaticTokenAuthenticatorFromFile reads tokens in the kube-apiserver token
+// file format: one `token,user[,uid][,"group1,group2"]` per line.
+func NewStaticTokenAuthenticatorFromFile(path string) (*StaticTokenAuthenticator, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening token file: %w", err)
+	}
+	defer file.Close()
+	tokens, err := parseTokenFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing token file %s: %w", path, err)
+	}
+	return NewStaticTokenAuthenticator(tokens), nil
+}
+
+func parseTokenFile(r io.Reader) (map[string]UserInfo, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	tokens := make(map[string]UserInfo)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: expected at least token and user", line)
+		}
+		user := UserInfo{Name: record[1]}
+		if len(record) >= 4 && record[3] != "" {
+			for _, group := range strings.Split(record[3], ",") {
+				if group = strings.TrimSpace(group); group != "" {
+					user.Groups = append(user.Groups, group)
+				}
+			}
+		}
+		tokens[record[0]] = user
+	}
+	return tokens, nil
+}
+
+func (a *StaticTokenAuthenticator) Authenticate(ctx context.Context, token string) (*UserInfo, error) {
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	for candidate, user := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			user := user
+			return &user, nil
+		}
+	}
+	return nil, ErrUnauthenticated
+}
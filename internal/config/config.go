@@ -5,27 +5,173 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
 
 type Specification struct {
-	ControllerIntervalDuration       time.Duration `default:"1s" envconfig:"controller_interval_duration"`
+	ConfigFile                       string        `envconfig:"config_file"`
+	ControllerIntervalDuration       time.Duration `default:"1s" envconfig:"controller_interval_duration" reload:"true"`
+	AdaptiveIntervalEnabled          bool          `default:"false" envconfig:"adaptive_interval_enabled" reload:"true"`
+	AdaptiveIntervalMinFactor        float64       `default:"0.5" envconfig:"adaptive_interval_min_factor" reload:"true"`
+	AdaptiveIntervalMaxFactor        float64       `default:"4" envconfig:"adaptive_interval_max_factor" reload:"true"`
+	AdaptiveIntervalLatencyBudget    float64       `default:"0.5" envconfig:"adaptive_interval_latency_budget" reload:"true"`
+	AdaptiveIntervalSpikeRatio       float64       `default:"2" envconfig:"adaptive_interval_spike_ratio" reload:"true"`
+	AdaptiveIntervalJitter           float64       `default:"0.1" envconfig:"adaptive_interval_jitter" reload:"true"`
+	PodRequestConcurrency            int           `default:"64" envconfig:"pod_request_concurrency"`
+	PodRequestTimeout                time.Duration `default:"10s" envconfig:"pod_request_timeout"`
+	PodDialTimeout                   time.Duration `default:"5s" envconfig:"pod_dial_timeout"`
+	PodTLSHandshakeTimeout           time.Duration `default:"10s" envconfig:"pod_tls_handshake_timeout"`
+	PodIdleConnTimeout               time.Duration `default:"90s" envconfig:"pod_idle_conn_timeout"`
+	PodMaxIdleConns                  int           `default:"1000" envconfig:"pod_max_idle_conns"`
+	PodMaxIdleConnsPerHost           int           `default:"10" envconfig:"pod_max_idle_conns_per_host"`
+	ClockSkewCorrectionEnabled       bool          `default:"true" envconfig:"clock_skew_correction_enabled" reload:"true"`
+	ClockSkewCorrectionThreshold     time.Duration `default:"100ms" envconfig:"clock_skew_correction_threshold" reload:"true"`
+	ClockSkewWarnThreshold           time.Duration `default:"1s" envconfig:"clock_skew_warn_threshold" reload:"true"`
+	PodFullReadInterval              time.Duration `default:"5m" envconfig:"pod_full_read_interval" reload:"true"`
+	RecorderEnabled                  bool          `default:"false" envconfig:"recorder_enabled"`
+	RecorderPath                     string        `default:"zones.rec" envconfig:"recorder_path"`
+	RecorderMaxFileSize              int64         `default:"104857600" envconfig:"recorder_max_file_size"`
+	RecorderMaxFiles                 int           `default:"5" envconfig:"recorder_max_files"`
+	RecorderInstances                []string      `envconfig:"recorder_instances"`
+	ShutdownTimeout                  time.Duration `default:"30s" envconfig:"shutdown_timeout"`
+	ShutdownWriteBack                bool          `default:"false" envconfig:"shutdown_write_back" reload:"true"`
 	LogLevel                         string        `default:"info" envconfig:"log_level"`
-	WarnZoneCollectionTime           time.Duration `default:"100ms" envconfig:"warn_zone_collection_time"`
-	WarnZoneReadTime                 time.Duration `default:"50ms" envconfig:"warn_zone_read_time"`
-	WarnZoneAggregationTime          time.Duration `default:"50ms" envconfig:"warn_zone_aggregation_time"`
-	FeatureFlagPersistAggregatedData bool          `default:"false" envconfig:"feature_flag_persist_aggregated_data"`
-	MaxTopOffendersReport            int           `default:"100" envconfig:"max_top_offenders_report"`
+	WarnZoneCollectionTime           time.Duration `default:"100ms" envconfig:"warn_zone_collection_time" reload:"true"`
+	WarnZoneReadTime                 time.Duration `default:"50ms" envconfig:"warn_zone_read_time" reload:"true"`
+	WarnZoneAggregationTime          time.Duration `default:"50ms" envconfig:"warn_zone_aggregation_time" reload:"true"`
+	FeatureFlagPersistAggregatedData bool          `default:"false" envconfig:"feature_flag_persist_aggregated_data" reload:"true"`
+	MaxTopOffendersReport            int           `default:"100" envconfig:"max_top_offenders_report" reload:"true"`
+	TopOffendersMetricsEnabled       bool          `default:"false" envconfig:"top_offenders_metrics_enabled"`
+	TopOffendersMetricsLimit         int           `default:"10" envconfig:"top_offenders_metrics_limit"`
+	TopOffendersMetricsKeyMode       string        `default:"raw" envconfig:"top_offenders_metrics_key_mode"`
+	TopOffendersMetricsKeyLength     int           `default:"16" envconfig:"top_offenders_metrics_key_length"`
+	AlertRulesFile                   string        `envconfig:"alert_rules_file"`
+	AlertWebhookTimeout              time.Duration `default:"5s" envconfig:"alert_webhook_timeout"`
+	AnomalyDetectionEnabled          bool          `default:"false" envconfig:"anomaly_detection_enabled"`
+	AnomalyEWMAAlpha                 float64       `default:"0.1" envconfig:"anomaly_ewma_alpha"`
+	AnomalyZScoreThreshold           float64       `default:"4" envconfig:"anomaly_zscore_threshold"`
+	AnomalyWarmupRounds              int           `default:"30" envconfig:"anomaly_warmup_rounds"`
+	AnomalyMaxReported               int           `default:"100" envconfig:"anomaly_max_reported"`
+	OverrideDefaultTTL               time.Duration `default:"10m" envconfig:"override_default_ttl" reload:"true"`
+	OverrideMaxTTL                   time.Duration `default:"24h" envconfig:"override_max_ttl" reload:"true"`
+	OverrideAuditSize                int           `default:"1000" envconfig:"override_audit_size"`
+	AllowlistMaxExcess               int64         `default:"0" envconfig:"allowlist_max_excess"`
+	PrefixGroupingEnabled            bool          `default:"false" envconfig:"prefix_grouping_enabled"`
+	PrefixGroupingEnforce            bool          `default:"false" envconfig:"prefix_grouping_enforce"`
+	PrefixGroupingIPv4Length         int           `default:"24" envconfig:"prefix_grouping_ipv4_length"`
+	PrefixGroupingIPv6Length         int           `default:"64" envconfig:"prefix_grouping_ipv6_length"`
+	ZoneInclude                      []string      `envconfig:"zone_include"`
+	ZoneExclude                      []string      `envconfig:"zone_exclude"`
+	GeoIPDatabaseFiles               []string      `envconfig:"geoip_database_files"`
+	GeoIPReloadInterval              time.Duration `default:"1m" envconfig:"geoip_reload_interval"`
+	AuthMode                         string        `default:"none" envconfig:"auth_mode"`
+	AuthTokenFile                    string        `envconfig:"auth_token_file"`
+	AuthAudiences                    []string      `envconfig:"auth_audiences"`
+	AuthorizationMode                string        `default:"none" envconfig:"authorization_mode"`
+	AuthAdminGroups                  []string      `envconfig:"auth_admin_groups"`
 }
 
+// Spec holds the configuration loaded at startup. Settings tagged with reload
+// may change while running and must be read with Get.
 var Spec Specification
 
+var (
+	mu      sync.RWMutex
+	fromEnv Specification
+	current Specification
+	changed = make(chan struct{})
+)
+
 func init() {
 	err := envconfig.Process("", &Spec)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	fromEnv = Spec
+	if Spec.ConfigFile != "" {
+		Spec, err = Load(Spec.ConfigFile)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	current = Spec
+}
+
+// Get returns the effective configuration, including reloaded settings.
+func Get() Specification {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Changed returns a channel closed on the next change of the effective
+// configuration. Get it before calling Get to not miss changes in between.
+func Changed() <-chan struct{} {
+	mu.RLock()
+	defer mu.RUnlock()
+	return changed
+}
+
+// set makes spec effective and wakes up whoever waits on Changed.
+func set(spec Specification) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = spec
+	close(changed)
+	changed = make(chan struct{})
+}
+
+func (s Specification) Validate() error {
+	var errs []error
+	if s.ControllerIntervalDuration <= 0 {
+		errs = append(errs, errors.New("controller_interval_duration must be positive"))
+	}
+	if s.AdaptiveIntervalMinFactor <= 0 || s.AdaptiveIntervalMinFactor > 1 || s.AdaptiveIntervalMaxFactor < 1 {
+		errs = append(errs, errors.New("adaptive_interval_min_factor must be in (0, 1] and adaptive_interval_max_factor at least 1"))
+	}
+	if s.AdaptiveIntervalLatencyBudget <= 0 || s.AdaptiveIntervalSpikeRatio <= 1 {
+		errs = append(errs, errors.New("adaptive_interval_latency_budget must be positive and adaptive_interval_spike_ratio greater than 1"))
+	}
+	if s.AdaptiveIntervalJitter < 0 || s.AdaptiveIntervalJitter >= 1 {
+		errs = append(errs, errors.New("adaptive_interval_jitter must be in [0, 1)"))
+	}
+	if s.PodRequestTimeout <= 0 || s.PodDialTimeout <= 0 {
+		errs = append(errs, errors.New("pod_request_timeout and pod_dial_timeout must be positive"))
+	}
+	if s.PodFullReadInterval < 0 {
+		errs = append(errs, errors.New("pod_full_read_interval must not be negative"))
+	}
+	if s.RecorderEnabled && (s.RecorderPath == "" || s.RecorderMaxFileSize <= 0 || s.RecorderMaxFiles <= 0) {
+		errs = append(errs, errors.New("recorder_path must be set and recorder_max_file_size and recorder_max_files must be positive when the recorder is enabled"))
+	}
+	if s.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+	if s.MaxTopOffendersReport <= 0 {
+		errs = append(errs, errors.New("max_top_offenders_report must be positive"))
+	}
+	if s.OverrideDefaultTTL <= 0 || s.OverrideDefaultTTL > s.OverrideMaxTTL {
+		errs = append(errs, errors.New("override_default_ttl must be positive and at most override_max_ttl"))
+	}
+	switch s.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("unknown log_level %q", s.LogLevel))
+	}
+	if s.PrefixGroupingIPv4Length < 0 || s.PrefixGroupingIPv4Length > 32 {
+		errs = append(errs, errors.New("prefix_grouping_ipv4_length must be between 0 and 32"))
+	}
+	if s.PrefixGroupingIPv6Length < 0 || s.PrefixGroupingIPv6Length > 128 {
+		errs = append(errs, errors.New("prefix_grouping_ipv6_length must be between 0 and 128"))
+	}
+	if s.AnomalyEWMAAlpha <= 0 || s.AnomalyEWMAAlpha > 1 {
+		errs = append(errs, errors.New("anomaly_ewma_alpha must be in (0, 1]"))
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,97 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+	return filename
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("should apply the file over the environment", func(t *testing.T) {
+		spec, err := Load(writeConfigFile(t, `
+controller_interval_duration: 5s
+max_top_offenders_report: 20
+feature_flag_persist_aggregated_data: true
+anomaly_ewma_alpha: 0.5
+auth_admin_groups: [admins, sre]
+`))
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Second, spec.ControllerIntervalDuration)
+		assert.Equal(t, 20, spec.MaxTopOffendersReport)
+		assert.True(t, spec.FeatureFlagPersistAggregatedData)
+		assert.Equal(t, 0.5, spec.AnomalyEWMAAlpha)
+		assert.Equal(t, []string{"admins", "sre"}, spec.AuthAdminGroups)
+		assert.Equal(t, fromEnv.OverrideMaxTTL, spec.OverrideMaxTTL)
+	})
+
+	t.Run("should reject unknown settings", func(t *testing.T) {
+		_, err := Load(writeConfigFile(t, "controler_interval_duration: 5s\n"))
+		assert.ErrorContains(t, err, `unknown setting "controler_interval_duration"`)
+	})
+
+	t.Run("should reject invalid values", func(t *testing.T) {
+		_, err := Load(writeConfigFile(t, "controller_interval_duration: soon\n"))
+		assert.ErrorContains(t, err, "controller_interval_duration")
+
+		_, err = Load(writeConfigFile(t, "max_top_offenders_report: 0\n"))
+		assert.ErrorContains(t, err, "max_top_offenders_report must be positive")
+	})
+}
+
+func TestReload(t *testing.T) {
+	previousSpec, previousCurrent := Spec, Get()
+	defer func() {
+		Spec = previousSpec
+		set(previousCurrent)
+	}()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	Spec.ConfigFile = writeConfigFile(t, "controller_interval_duration: 1s\n")
+	changed := Changed()
+
+	require.NoError(t, os.WriteFile(Spec.ConfigFile, []byte("controller_interval_duration: 3s\nauth_mode: static\n"), 0o644))
+	Reload(logger)
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("expected a change notification")
+	}
+	assert.Equal(t, 3*time.Second, Get().ControllerIntervalDuration)
+	// Settings that aren't reloadable need a restart
+	assert.Equal(t, previousCurrent.AuthMode, Get().AuthMode)
+
+	changed = Changed()
+	require.NoError(t, os.WriteFile(Spec.ConfigFile, []byte("controller_interval_duration: -1s\n"), 0o644))
+	Reload(logger)
+	assert.Equal(t, 3*time.Second, Get().ControllerIntervalDuration)
+	select {
+	case <-changed:
+		t.Fatal("invalid files must not change the configuration")
+	default:
+	}
+}
+
+func TestValues(t *testing.T) {
+	values := Get().Values()
+	assert.Equal(t, Get().ControllerIntervalDuration.String(), values["controller_interval_duration"])
+	assert.Contains(t, values, "max_top_offenders_report")
+	assert.Contains(t, Reloadable(), "controller_interval_duration")
+	assert.NotContains(t, Reloadable(), "auth_mode")
+}
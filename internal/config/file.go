@@ -0,0 +1,169 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Load reads a YAML file whose keys are the envconfig names of the settings,
+// e.g. controller_interval_duration: 5s. Values in the file take precedence
+// over environment variables.
+func Load(filename string) (Specification, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return Specification{}, fmt.Errorf("error reading config file: %w", err)
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return Specification{}, fmt.Errorf("error parsing config file %s: %w", filename, err)
+	}
+	spec := fromEnv
+	if err := spec.apply(values); err != nil {
+		return Specification{}, fmt.Errorf("invalid config file %s: %w", filename, err)
+	}
+	if err := spec.Validate(); err != nil {
+		return Specification{}, fmt.Errorf("invalid config file %s: %w", filename, err)
+	}
+	return spec, nil
+}
+
+func (s *Specification) apply(values map[string]any) error {
+	fields := make(map[string]reflect.Value)
+	v := reflect.ValueOf(s).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		fields[v.Type().Field(i).Tag.Get("envconfig")] = v.Field(i)
+	}
+	for key, value := range values {
+		name := strings.ToLower(key)
+		field, ok := fields[name]
+		if !ok || name == "config_file" {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value any) error {
+	if field.Kind() == reflect.Slice {
+		var items []string
+		switch v := value.(type) {
+		case []any:
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+		case string:
+			items = strings.Split(v, ",")
+		default:
+			return fmt.Errorf("expected a list, got %v", value)
+		}
+		field.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	var str string
+	switch v := value.(type) {
+	case string:
+		str = v
+	case float64:
+		str = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		str = strconv.FormatBool(v)
+	default:
+		return fmt.Errorf("unexpected value %v", value)
+	}
+
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(str)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// Values returns the settings keyed by their envconfig names, as accepted in the config file.
+func (s Specification) Values() map[string]any {
+	values := make(map[string]any)
+	v := reflect.ValueOf(s)
+	for i := 0; i < v.NumField(); i++ {
+		key := v.Type().Field(i).Tag.Get("envconfig")
+		switch value := v.Field(i).Interface().(type) {
+		case time.Duration:
+			values[key] = value.String()
+		default:
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// Reloadable returns the names of the settings applied without a restart.
+func Reloadable() []string {
+	var names []string
+	t := reflect.TypeOf(Specification{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("reload") == "true" {
+			names = append(names, t.Field(i).Tag.Get("envconfig"))
+		}
+	}
+	return names
+}
+
+// merge returns current with the reloadable settings of loaded, and the
+// names of the other settings that changed and need a restart.
+func merge(current, loaded Specification) (Specification, []string) {
+	var restart []string
+	c := reflect.ValueOf(&current).Elem()
+	l := reflect.ValueOf(loaded)
+	for i := 0; i < c.NumField(); i++ {
+		if reflect.DeepEqual(c.Field(i).Interface(), l.Field(i).Interface()) {
+			continue
+		}
+		field := c.Type().Field(i)
+		if field.Tag.Get("reload") == "true" {
+			c.Field(i).Set(l.Field(i))
+		} else {
+			restart = append(restart, field.Tag.Get("envconfig"))
+		}
+	}
+	return current, restart
+}
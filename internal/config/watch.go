@@ -0,0 +1,72 @@
+// Copyright 2025 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce groups the events of editors and ConfigMap updates, which
+// write, rename and chmod files in quick succession.
+const watchDebounce = 100 * time.Millisecond
+
+// Watch reloads the config file whenever it changes until ctx is done.
+// Invalid files are logged and ignored, keeping the current configuration.
+func Watch(ctx context.Context, logger *slog.Logger) error {
+	filename := Spec.ConfigFile
+	if filename == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error watching config file: %w", err)
+	}
+	defer watcher.Close()
+	// Watching the directory catches files replaced by renames and ConfigMap symlink swaps
+	if err := watcher.Add(filepath.Dir(filename)); err != nil {
+		return fmt.Errorf("error watching config file: %w", err)
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			logger.Error("Error watching config file", "file", filename, "error", err)
+		case <-watcher.Events:
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			debounce = nil
+			Reload(logger)
+		}
+	}
+}
+
+// Reload applies the reloadable settings of the config file.
+func Reload(logger *slog.Logger) {
+	loaded, err := Load(Spec.ConfigFile)
+	if err != nil {
+		logger.Error("Error reloading config file - keeping current configuration", "file", Spec.ConfigFile, "error", err)
+		return
+	}
+	previous := Get()
+	spec, restart := merge(previous, loaded)
+	if len(restart) > 0 {
+		logger.Warn("Config file changes need a restart to take effect", "settings", restart)
+	}
+	if reflect.DeepEqual(spec, previous) {
+		return
+	}
+	set(spec)
+	logger.Info("Config file reloaded", "file", Spec.ConfigFile)
+}
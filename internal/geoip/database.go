@@ -0,0 +1,184 @@
+package geoip
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Info is what is known about the origin of an address.
+type Info struct {
+	Country        string `json:"country,omitempty"`
+	ASN            uint   `json:"asn,omitempty"`
+	ASOrganization string `json:"asOrganization,omitempty"`
+}
+
+func (i Info) Empty() bool {
+	return i.Country == "" && i.ASN == 0
+}
+
+// record holds the fields of the GeoLite2/GeoIP2 Country, City and ASN databases we use.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+type databaseFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	reader  *maxminddb.Reader
+}
+
+// Database looks addresses up in local MaxMind format database files, usually
+// a country and an ASN one. Files are reopened when they change on disk.
+type Database struct {
+	mu     sync.RWMutex
+	logger *slog.Logger
+	files  []*databaseFile
+	stop   chan struct{}
+}
+
+func NewDatabase(logger *slog.Logger, paths []string, reloadInterval time.Duration) (*Database, error) {
+	d := &Database{
+		logger: logger,
+		stop:   make(chan struct{}),
+	}
+	for _, path := range paths {
+		file, err := openDatabaseFile(path)
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.files = append(d.files, file)
+	}
+	if reloadInterval > 0 {
+		go d.watch(reloadInterval)
+	}
+	return d, nil
+}
+
+func openDatabaseFile(path string) (*databaseFile, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading geoip database: %w", err)
+	}
+	// Read into memory instead of mmap'ing, so files rewritten in place don't
+	// change under the current reader
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading geoip database: %w", err)
+	}
+	reader, err := maxminddb.FromBytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("error opening geoip database %s: %w", path, err)
+	}
+	return &databaseFile{
+		path:    path,
+		modTime: stat.ModTime(),
+		size:    stat.Size(),
+		reader:  reader,
+	}, nil
+}
+
+func (d *Database) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.Reload()
+		}
+	}
+}
+
+// Reload reopens the files that changed since they were opened. A file that
+// can't be read keeps being served from the previous version.
+func (d *Database) Reload() {
+	d.mu.RLock()
+	files := make([]*databaseFile, len(d.files))
+	copy(files, d.files)
+	d.mu.RUnlock()
+
+	for i, file := range files {
+		stat, err := os.Stat(file.path)
+		if err != nil {
+			d.logger.Error("Error checking geoip database", "path", file.path, "error", err)
+			continue
+		}
+		if stat.ModTime().Equal(file.modTime) && stat.Size() == file.size {
+			continue
+		}
+		newFile, err := openDatabaseFile(file.path)
+		if err != nil {
+			d.logger.Error("Error reloading geoip database", "path", file.path, "error", err)
+			continue
+		}
+		d.mu.Lock()
+		if i >= len(d.files) || d.files[i] != file {
+			// Closed meanwhile
+			d.mu.Unlock()
+			newFile.reader.Close()
+			return
+		}
+		d.files[i] = newFile
+		d.mu.Unlock()
+		file.reader.Close()
+		d.logger.Info("GeoIP database reloaded", "path", file.path)
+	}
+}
+
+// Lookup merges what every database file knows about ip.
+func (d *Database) Lookup(ip net.IP) (Info, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var info Info
+	for _, file := range d.files {
+		var r record
+		if err := file.reader.Lookup(ip, &r); err != nil {
+			continue
+		}
+		if info.Country == "" {
+			info.Country = r.Country.ISOCode
+		}
+		if info.ASN == 0 {
+			info.ASN = r.AutonomousSystemNumber
+			info.ASOrganization = r.AutonomousSystemOrganization
+		}
+	}
+	return info, !info.Empty()
+}
+
+// LookupKey looks up rate limit keys, keys that are not IP addresses are never found.
+func (d *Database) LookupKey(key string) (Info, bool) {
+	ip := net.ParseIP(key)
+	if ip == nil {
+		return Info{}, false
+	}
+	return d.Lookup(ip)
+}
+
+func (d *Database) Close() {
+	select {
+	case <-d.stop:
+		return
+	default:
+		close(d.stop)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, file := range d.files {
+		file.reader.Close()
+	}
+	d.files = nil
+}
@@ -0,0 +1,96 @@
+package geoip
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func country(isoCode string) map[string]any {
+	return map[string]any{"country": map[string]any{"iso_code": isoCode}}
+}
+
+func asn(number int, organization string) map[string]any {
+	return map[string]any{
+		"autonomous_system_number":       number,
+		"autonomous_system_organization": organization,
+	}
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
+}
+
+func TestDatabaseLookup(t *testing.T) {
+	dir := t.TempDir()
+	countryPath := filepath.Join(dir, "country.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	writeTestDatabase(t, countryPath, map[string]map[string]any{
+		"10.0.0.0/8":    country("BR"),
+		"192.0.2.0/24":  country("US"),
+		"2001:db8::/32": country("DE"),
+	})
+	writeTestDatabase(t, asnPath, map[string]map[string]any{
+		"10.1.0.0/16":   asn(64500, "Example Hosting"),
+		"2001:db8::/48": asn(64501, "Example Cloud"),
+	})
+
+	db, err := NewDatabase(testLogger(), []string{countryPath, asnPath}, 0)
+	require.NoError(t, err)
+	defer db.Close()
+
+	info, ok := db.LookupKey("10.1.2.3")
+	assert.True(t, ok)
+	assert.Equal(t, Info{Country: "BR", ASN: 64500, ASOrganization: "Example Hosting"}, info)
+
+	info, ok = db.LookupKey("10.2.0.1")
+	assert.True(t, ok)
+	assert.Equal(t, Info{Country: "BR"}, info)
+
+	info, ok = db.Lookup(net.ParseIP("2001:db8::1"))
+	assert.True(t, ok)
+	assert.Equal(t, Info{Country: "DE", ASN: 64501, ASOrganization: "Example Cloud"}, info)
+
+	_, ok = db.LookupKey("172.16.0.1")
+	assert.False(t, ok)
+
+	_, ok = db.LookupKey("api-key")
+	assert.False(t, ok)
+}
+
+func TestDatabaseReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	writeTestDatabase(t, path, map[string]map[string]any{"10.0.0.0/8": country("BR")})
+
+	db, err := NewDatabase(testLogger(), []string{path}, 0)
+	require.NoError(t, err)
+	defer db.Close()
+
+	info, _ := db.LookupKey("10.0.0.1")
+	assert.Equal(t, "BR", info.Country)
+
+	writeTestDatabase(t, path, map[string]map[string]any{"10.0.0.0/8": country("AR"), "192.0.2.0/24": country("US")})
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+	db.Reload()
+
+	info, _ = db.LookupKey("10.0.0.1")
+	assert.Equal(t, "AR", info.Country)
+
+	// A broken file keeps the previous version
+	require.NoError(t, os.WriteFile(path, []byte("broken"), 0o644))
+	db.Reload()
+	info, _ = db.LookupKey("192.0.2.1")
+	assert.Equal(t, "US", info.Country)
+}
+
+func TestNewDatabaseMissingFile(t *testing.T) {
+	_, err := NewDatabase(testLogger(), []string{filepath.Join(t.TempDir(), "missing.mmdb")}, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestDatabase writes a MaxMind DB with 24 bit records, enough for test
+// fixtures without depending on a writer library. IPv4 networks are stored in
+// the ::/96 subtree like the official databases.
+func writeTestDatabase(t *testing.T, path string, networks map[string]map[string]any) {
+	t.Helper()
+
+	type node struct {
+		children [2]*node
+		leaf     bool
+		data     int
+	}
+	root := &node{}
+	var data bytes.Buffer
+	cidrs := make([]string, 0, len(networks))
+	for cidr := range networks {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		ones, bits := network.Mask.Size()
+		ip := network.IP.To16()
+		if bits == 32 {
+			ip = append(make(net.IP, 12), network.IP.To4()...)
+			ones += 96
+		}
+		offset := data.Len()
+		encodeValue(t, &data, networks[cidr])
+
+		current := root
+		for i := 0; i < ones; i++ {
+			bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+			if current.children[bit] == nil {
+				current.children[bit] = &node{}
+			}
+			current = current.children[bit]
+		}
+		current.leaf = true
+		current.data = offset
+	}
+
+	var nodes []*node
+	ids := map[*node]int{}
+	queue := []*node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		ids[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, child := range n.children {
+			if child != nil && !child.leaf {
+				queue = append(queue, child)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		for _, child := range n.children {
+			value := nodeCount
+			switch {
+			case child == nil:
+			case child.leaf:
+				value = nodeCount + 16 + child.data
+			default:
+				value = ids[child]
+			}
+			out.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	encodeValue(t, &out, map[string]any{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(6),
+		"database_type":               "Test",
+		"languages":                   []any{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(0),
+		"description":                 map[string]any{"en": "Test database"},
+	})
+	require.NoError(t, os.WriteFile(path, out.Bytes(), 0o644))
+}
+
+const (
+	typeString = 2
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeUint64 = 9
+	typeArray  = 11
+)
+
+func encodeControl(buf *bytes.Buffer, dataType, size int) {
+	var control byte
+	var extended []byte
+	if dataType > 7 {
+		extended = []byte{byte(dataType - 7)}
+	} else {
+		control = byte(dataType << 5)
+	}
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+		control |= byte(size)
+	case size < 285:
+		control |= 29
+		sizeBytes = []byte{byte(size - 29)}
+	default:
+		control |= 30
+		sizeBytes = binary.BigEndian.AppendUint16(nil, uint16(size-285))
+	}
+	buf.WriteByte(control)
+	buf.Write(extended)
+	buf.Write(sizeBytes)
+}
+
+func encodeUint(buf *bytes.Buffer, dataType int, value uint64) {
+	var b []byte
+	for ; value > 0; value >>= 8 {
+		b = append([]byte{byte(value)}, b...)
+	}
+	encodeControl(buf, dataType, len(b))
+	buf.Write(b)
+}
+
+func encodeValue(t *testing.T, buf *bytes.Buffer, value any) {
+	switch v := value.(type) {
+	case string:
+		encodeControl(buf, typeString, len(v))
+		buf.WriteString(v)
+	case uint16:
+		encodeUint(buf, typeUint16, uint64(v))
+	case uint32:
+		encodeUint(buf, typeUint32, uint64(v))
+	case uint64:
+		encodeUint(buf, typeUint64, v)
+	case int:
+		encodeUint(buf, typeUint32, uint64(v))
+	case []any:
+		encodeControl(buf, typeArray, len(v))
+		for _, item := range v {
+			encodeValue(t, buf, item)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		encodeControl(buf, typeMap, len(v))
+		for _, key := range keys {
+			encodeValue(t, buf, key)
+			encodeValue(t, buf, v[key])
+		}
+	default:
+		t.Fatalf("unsupported type %T", value)
+	}
+}
@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+)
+
+const (
+	adaptiveIntervalWarmupRounds = 5
+	adaptiveIntervalExcessAlpha  = 0.2
+	adaptiveIntervalStretch      = 1.5
+)
+
+// roundStats describe a sync round, they drive the adaptive interval.
+type roundStats struct {
+	duration time.Duration
+	excess   int64
+}
+
+// adaptiveInterval stretches the sync interval while rounds take longer than
+// their latency budget and shortens it on traffic spikes, relaxing back to
+// the configured interval otherwise.
+type adaptiveInterval struct {
+	current   time.Duration
+	excessAvg float64
+	rounds    int
+	random    func() float64
+}
+
+// next returns the effective interval and the time to wait for the next
+// round, which is the interval with jitter so instances don't read their pods
+// in lockstep.
+func (a *adaptiveInterval) next(base time.Duration, stats roundStats, cfg config.Specification) (time.Duration, time.Duration) {
+	if a.current == 0 {
+		a.current = base
+	}
+	minInterval := time.Duration(float64(base) * cfg.AdaptiveIntervalMinFactor)
+	maxInterval := time.Duration(float64(base) * cfg.AdaptiveIntervalMaxFactor)
+
+	switch {
+	case stats.duration > time.Duration(float64(a.current)*cfg.AdaptiveIntervalLatencyBudget):
+		a.current = time.Duration(float64(a.current) * adaptiveIntervalStretch)
+	case a.rounds >= adaptiveIntervalWarmupRounds && a.excessAvg > 0 && float64(stats.excess) > cfg.AdaptiveIntervalSpikeRatio*a.excessAvg:
+		a.current /= 2
+	default:
+		a.current += (base - a.current) / 4
+	}
+	if a.current < minInterval {
+		a.current = minInterval
+	}
+	if a.current > maxInterval {
+		a.current = maxInterval
+	}
+
+	if a.rounds == 0 {
+		a.excessAvg = float64(stats.excess)
+	} else {
+		a.excessAvg += adaptiveIntervalExcessAlpha * (float64(stats.excess) - a.excessAvg)
+	}
+	a.rounds++
+
+	random := a.random
+	if random == nil {
+		random = rand.Float64
+	}
+	wait := time.Duration(float64(a.current) * (1 + cfg.AdaptiveIntervalJitter*(2*random()-1)))
+	return a.current, max(wait, time.Millisecond)
+}
@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+)
+
+func adaptiveIntervalConfig() config.Specification {
+	return config.Specification{
+		AdaptiveIntervalEnabled:       true,
+		AdaptiveIntervalMinFactor:     0.5,
+		AdaptiveIntervalMaxFactor:     4,
+		AdaptiveIntervalLatencyBudget: 0.5,
+		AdaptiveIntervalSpikeRatio:    2,
+		AdaptiveIntervalJitter:        0.1,
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	cfg := adaptiveIntervalConfig()
+	base := time.Second
+	noJitter := func() float64 { return 0.5 }
+
+	t.Run("should stretch while rounds are slow up to the max factor", func(t *testing.T) {
+		a := &adaptiveInterval{random: noJitter}
+		interval, wait := a.next(base, roundStats{duration: 800 * time.Millisecond}, cfg)
+		assert.Equal(t, 1500*time.Millisecond, interval)
+		assert.Equal(t, interval, wait)
+		for range 10 {
+			interval, _ = a.next(base, roundStats{duration: 10 * time.Second}, cfg)
+		}
+		assert.Equal(t, 4*time.Second, interval)
+	})
+
+	t.Run("should relax back to the configured interval", func(t *testing.T) {
+		a := &adaptiveInterval{random: noJitter, current: 4 * time.Second}
+		var interval time.Duration
+		for range 30 {
+			interval, _ = a.next(base, roundStats{duration: 10 * time.Millisecond}, cfg)
+		}
+		assert.InDelta(t, float64(base), float64(interval), float64(time.Millisecond))
+	})
+
+	t.Run("should shorten on traffic spikes down to the min factor", func(t *testing.T) {
+		a := &adaptiveInterval{random: noJitter}
+		for range adaptiveIntervalWarmupRounds {
+			a.next(base, roundStats{duration: time.Millisecond, excess: 1000}, cfg)
+		}
+		interval, _ := a.next(base, roundStats{duration: time.Millisecond, excess: 5000}, cfg)
+		assert.Equal(t, 500*time.Millisecond, interval)
+		interval, _ = a.next(base, roundStats{duration: time.Millisecond, excess: 50000}, cfg)
+		assert.Equal(t, 500*time.Millisecond, interval)
+	})
+
+	t.Run("should add jitter to the wait", func(t *testing.T) {
+		a := &adaptiveInterval{random: func() float64 { return 1 }}
+		interval, wait := a.next(base, roundStats{}, cfg)
+		assert.Equal(t, base, interval)
+		assert.Equal(t, 1100*time.Millisecond, wait)
+
+		a = &adaptiveInterval{random: func() float64 { return 0 }}
+		_, wait = a.next(base, roundStats{}, cfg)
+		assert.Equal(t, 900*time.Millisecond, wait)
+	})
+}
@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+// InstanceAllowlist is the allowlist of an instance split by where entries come from.
+type InstanceAllowlist struct {
+	Annotation []string `json:"annotation"`
+	API        []string `json:"api"`
+	MaxExcess  int64    `json:"maxExcess"`
+}
+
+// AllowlistStore keeps the keys exempt from global aggregation per instance.
+// Allowlisted keys are left out of aggregation, or have their aggregated excess
+// capped at maxExcess when it is positive.
+type AllowlistStore struct {
+	mu         sync.Mutex
+	annotation map[string][]string
+	api        map[string][]string
+	matchers   map[string]ratelimit.Allowlist
+	maxExcess  int64
+}
+
+func NewAllowlistStore(maxExcess int64) *AllowlistStore {
+	return &AllowlistStore{
+		annotation: make(map[string][]string),
+		api:        make(map[string][]string),
+		matchers:   make(map[string]ratelimit.Allowlist),
+		maxExcess:  maxExcess,
+	}
+}
+
+func (s *AllowlistStore) SetAnnotationEntries(instance string, entries []string) error {
+	for _, entry := range entries {
+		if err := ratelimit.ValidateAllowlistEntry(entry); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(entries) == 0 {
+		delete(s.annotation, instance)
+	} else {
+		s.annotation[instance] = entries
+	}
+	return s.rebuild(instance)
+}
+
+func (s *AllowlistStore) Add(instance, entry string) error {
+	if err := ratelimit.ValidateAllowlistEntry(entry); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Contains(s.api[instance], entry) {
+		return nil
+	}
+	s.api[instance] = append(s.api[instance], entry)
+	sort.Strings(s.api[instance])
+	return s.rebuild(instance)
+}
+
+func (s *AllowlistStore) Remove(instance, entry string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := slices.Index(s.api[instance], entry)
+	if index < 0 {
+		return false
+	}
+	s.api[instance] = slices.Delete(s.api[instance], index, index+1)
+	if len(s.api[instance]) == 0 {
+		delete(s.api, instance)
+	}
+	s.rebuild(instance)
+	return true
+}
+
+func (s *AllowlistStore) Get(instance string) InstanceAllowlist {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return InstanceAllowlist{
+		Annotation: append([]string{}, s.annotation[instance]...),
+		API:        append([]string{}, s.api[instance]...),
+		MaxExcess:  s.maxExcess,
+	}
+}
+
+func (s *AllowlistStore) Matcher(instance string) (ratelimit.Allowlist, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.matchers[instance], s.maxExcess
+}
+
+func (s *AllowlistStore) rebuild(instance string) error {
+	entries := append(slices.Clone(s.annotation[instance]), s.api[instance]...)
+	if len(entries) == 0 {
+		delete(s.matchers, instance)
+		return nil
+	}
+	allowlist, err := ratelimit.NewAllowlist(entries)
+	if err != nil {
+		return err
+	}
+	s.matchers[instance] = allowlist
+	return nil
+}
+
+// skipAllowlisted removes allowlisted entries from the zones read from pods so they stay local to each pod.
+func skipAllowlisted(zones []ratelimit.Zone, allowlist ratelimit.Allowlist) int {
+	skipped := 0
+	for i := range zones {
+		entries := zones[i].RateLimitEntries[:0]
+		for _, entry := range zones[i].RateLimitEntries {
+			if allowlist.Contains(entry.Key.String(zones[i].RateLimitHeader)) {
+				skipped++
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		zones[i].RateLimitEntries = entries
+	}
+	return skipped
+}
+
+// capAllowlisted limits the aggregated excess of allowlisted keys to maxExcess.
+func capAllowlisted(zone *ratelimit.Zone, fullZone map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry, allowlist ratelimit.Allowlist, maxExcess int64) int {
+	capped := 0
+	for i := range zone.RateLimitEntries {
+		key := zone.RateLimitEntries[i].Key.String(zone.RateLimitHeader)
+		if zone.RateLimitEntries[i].Excess <= maxExcess || !allowlist.Contains(key) {
+			continue
+		}
+		capped++
+		zone.RateLimitEntries[i].Excess = maxExcess
+		if entry, ok := fullZone[ratelimit.FullZoneKey{Zone: zone.Name, Key: key}]; ok {
+			entry.Excess = maxExcess
+		}
+	}
+	return capped
+}
@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+func TestAllowlistStore(t *testing.T) {
+	store := NewAllowlistStore(0)
+	require.NoError(t, store.SetAnnotationEntries(instanceName, []string{"10.0.0.0/8"}))
+	require.NoError(t, store.Add(instanceName, "healthcheck"))
+	require.NoError(t, store.Add(instanceName, "healthcheck"))
+	assert.Error(t, store.Add(instanceName, "10.0.0.0/99"))
+	assert.Error(t, store.SetAnnotationEntries(instanceName, []string{"1.2.3.4/40"}))
+
+	assert.Equal(t, InstanceAllowlist{Annotation: []string{"10.0.0.0/8"}, API: []string{"healthcheck"}}, store.Get(instanceName))
+	matcher, maxExcess := store.Matcher(instanceName)
+	assert.Equal(t, int64(0), maxExcess)
+	assert.True(t, matcher.Contains("10.1.1.1"))
+	assert.True(t, matcher.Contains("healthcheck"))
+
+	assert.True(t, store.Remove(instanceName, "healthcheck"))
+	assert.False(t, store.Remove(instanceName, "healthcheck"))
+	matcher, _ = store.Matcher(instanceName)
+	assert.False(t, matcher.Contains("healthcheck"))
+
+	require.NoError(t, store.SetAnnotationEntries(instanceName, nil))
+	matcher, _ = store.Matcher(instanceName)
+	assert.True(t, matcher.Empty())
+	matcher, _ = store.Matcher("other")
+	assert.True(t, matcher.Empty())
+}
+
+func TestSkipAllowlisted(t *testing.T) {
+	allowlist, err := ratelimit.NewAllowlist([]string{"healthcheck"})
+	require.NoError(t, err)
+	zones := []ratelimit.Zone{
+		{Name: "one", RateLimitEntries: []ratelimit.RateLimitEntry{{Key: []byte("healthcheck"), Excess: 10}, {Key: []byte("client"), Excess: 5}}},
+		{Name: "one", RateLimitEntries: []ratelimit.RateLimitEntry{{Key: []byte("healthcheck"), Excess: 12}}},
+	}
+	assert.Equal(t, 2, skipAllowlisted(zones, allowlist))
+	assert.Equal(t, []ratelimit.RateLimitEntry{{Key: []byte("client"), Excess: 5}}, zones[0].RateLimitEntries)
+	assert.Empty(t, zones[1].RateLimitEntries)
+}
+
+func TestCapAllowlisted(t *testing.T) {
+	allowlist, err := ratelimit.NewAllowlist([]string{"healthcheck"})
+	require.NoError(t, err)
+	zone := ratelimit.Zone{Name: "one", RateLimitEntries: []ratelimit.RateLimitEntry{
+		{Key: []byte("healthcheck"), Excess: 5000},
+		{Key: []byte("client"), Excess: 5000},
+	}}
+	fullZone := map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry{
+		{Zone: "one", Key: "healthcheck"}: {Key: []byte("healthcheck"), Excess: 5000},
+	}
+	assert.Equal(t, 1, capAllowlisted(&zone, fullZone, allowlist, 1000))
+	assert.Equal(t, int64(1000), zone.RateLimitEntries[0].Excess)
+	assert.Equal(t, int64(5000), zone.RateLimitEntries[1].Excess)
+	assert.Equal(t, int64(1000), fullZone[ratelimit.FullZoneKey{Zone: "one", Key: "healthcheck"}].Excess)
+}
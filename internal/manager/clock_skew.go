@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+)
+
+// clockSkewAlpha is the EWMA smoothing factor of the offset samples.
+const clockSkewAlpha = 0.2
+
+// clockSkew estimates how far the clock of a pod is ahead of the controller
+// one. As in NTP, the time a pod reports is compared with the middle of the
+// request, so each sample is off by at most half of the round trip.
+type clockSkew struct {
+	mu      sync.Mutex
+	offset  float64
+	samples int
+	warned  bool
+}
+
+// observe adds a sample from a response sent at podNow, in milliseconds,
+// to a request made between start and end. It returns the estimated offset
+// and whether it just went over the warning threshold.
+func (c *clockSkew) observe(podNow int64, start, end time.Time, warnThreshold time.Duration) (time.Duration, bool) {
+	middle := start.Add(end.Sub(start) / 2)
+	sample := float64(podNow - middle.UnixMilli())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.samples == 0 {
+		c.offset = sample
+	} else {
+		c.offset += clockSkewAlpha * (sample - c.offset)
+	}
+	c.samples++
+	offset := time.Duration(c.offset) * time.Millisecond
+	exceeded := offset > warnThreshold || offset < -warnThreshold
+	warn := exceeded && !c.warned
+	c.warned = exceeded
+	return offset, warn
+}
+
+// estimate returns the estimated offset.
+func (c *clockSkew) estimate() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.offset) * time.Millisecond
+}
+
+// correction returns the milliseconds to subtract from pod times to get to
+// the controller timebase. Offsets under the threshold can't be told apart
+// from network delays and are left alone.
+func (c *clockSkew) correction(cfg config.Specification) int64 {
+	if !cfg.ClockSkewCorrectionEnabled {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.samples == 0 || math.Abs(c.offset) < float64(cfg.ClockSkewCorrectionThreshold.Milliseconds()) {
+		return 0
+	}
+	return int64(math.Round(c.offset))
+}
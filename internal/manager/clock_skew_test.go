@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+	"github.com/tsuru/rate-limit-control-plane/test"
+)
+
+func TestClockSkew(t *testing.T) {
+	cfg := config.Get()
+	cfg.ClockSkewCorrectionEnabled = true
+	cfg.ClockSkewCorrectionThreshold = 100 * time.Millisecond
+	start := time.UnixMilli(1_000_000)
+	end := start.Add(20 * time.Millisecond)
+
+	var clock clockSkew
+	assert.Equal(t, int64(0), clock.correction(cfg))
+
+	// The pod answered 10ms after the request started, in the middle of it, with its clock 2s ahead
+	offset, warn := clock.observe(start.UnixMilli()+2010, start, end, time.Second)
+	assert.Equal(t, 2*time.Second, offset)
+	assert.True(t, warn)
+	assert.Equal(t, int64(2000), clock.correction(cfg))
+
+	// Samples are smoothed and the warning isn't repeated
+	offset, warn = clock.observe(start.UnixMilli()+1010, start, end, time.Second)
+	assert.Equal(t, 1800*time.Millisecond, offset)
+	assert.False(t, warn)
+
+	cfg.ClockSkewCorrectionEnabled = false
+	assert.Equal(t, int64(0), clock.correction(cfg))
+
+	var small clockSkew
+	small.observe(start.UnixMilli()+50, start, end, time.Second)
+	cfg.ClockSkewCorrectionEnabled = true
+	assert.Equal(t, int64(0), small.correction(cfg))
+}
+
+func TestRpaasPodWorkerClockSkewCorrection(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+	repository := test.NewRepository()
+	go test.NewServerMock(listener, repository)
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	// The pod wall clock is 5s ahead, its monotonic clock matches the controller one
+	now := time.Now().UnixMilli()
+	repository.Values["one"].Header.Now = now + 5000
+	repository.Values["one"].Header.NowMonotonic = now
+	setRepositoryData(repository, "one", []*test.Body{{Key: []byte("192.168.0.1"), Last: now - 100, Excess: 500}})
+
+	podWorker := NewRpaasPodWorker(RpaasPodData{Name: "pod", URL: fmt.Sprintf("http://localhost:%s", port)}, RpaasInstanceData{Instance: instanceName, Service: serviceName}, logger, nil)
+	defer podWorker.releaseClient()
+	zone, err := podWorker.getZoneData(context.Background(), "one")
+	require.NoError(t, err)
+	require.Len(t, zone.RateLimitEntries, 1)
+	assert.InDelta(t, now-100, zone.RateLimitEntries[0].Last, 100)
+	assert.InDelta(t, now, zone.RateLimitHeader.Now, 100)
+	assert.InDelta(t, 5*time.Second, podWorker.clock.estimate(), float64(100*time.Millisecond))
+}
@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+)
+
+// ClientSettings configures the HTTP clients used to reach nginx pods.
+// Clients with the same settings share a transport, and so its connections.
+type ClientSettings struct {
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration
+	TLSHandshakeTimeout time.Duration
+	RequestTimeout      time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+}
+
+func ClientSettingsFromConfig(cfg config.Specification) ClientSettings {
+	return ClientSettings{
+		DialTimeout:         cfg.PodDialTimeout,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: cfg.PodTLSHandshakeTimeout,
+		RequestTimeout:      cfg.PodRequestTimeout,
+		IdleConnTimeout:     cfg.PodIdleConnTimeout,
+		MaxIdleConns:        cfg.PodMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.PodMaxIdleConnsPerHost,
+	}
+}
+
+func (s ClientSettings) newClient() *http.Client {
+	transport := &http.Transport{
+		MaxIdleConns:        s.MaxIdleConns,
+		MaxIdleConnsPerHost: s.MaxIdleConnsPerHost,
+		IdleConnTimeout:     s.IdleConnTimeout,
+		DialContext: (&net.Dialer{
+			Timeout:   s.DialTimeout,
+			KeepAlive: s.KeepAlive,
+		}).DialContext,
+		TLSHandshakeTimeout: s.TLSHandshakeTimeout,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   s.RequestTimeout,
+	}
+}
+
+type pooledClient struct {
+	client *http.Client
+	refs   int
+}
+
+// ClientPool hands out shared clients, closing the idle connections of a
+// transport once the last of its users releases it.
+type ClientPool struct {
+	mu      sync.Mutex
+	clients map[ClientSettings]*pooledClient
+}
+
+func NewClientPool() *ClientPool {
+	return &ClientPool{
+		clients: make(map[ClientSettings]*pooledClient),
+	}
+}
+
+var defaultClientPool = NewClientPool()
+
+// AcquirePodClient returns the shared client for nginx pods, the returned function releases it.
+func AcquirePodClient() (*http.Client, func()) {
+	return defaultClientPool.Acquire(ClientSettingsFromConfig(config.Spec))
+}
+
+func (p *ClientPool) Acquire(settings ClientSettings) (*http.Client, func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pooled, exists := p.clients[settings]
+	if !exists {
+		pooled = &pooledClient{client: settings.newClient()}
+		p.clients[settings] = pooled
+		httpTransportsGauge.Inc()
+	}
+	pooled.refs++
+	var once sync.Once
+	return pooled.client, func() {
+		once.Do(func() {
+			p.release(settings)
+		})
+	}
+}
+
+func (p *ClientPool) release(settings ClientSettings) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pooled, exists := p.clients[settings]
+	if !exists {
+		return
+	}
+	pooled.refs--
+	if pooled.refs > 0 {
+		return
+	}
+	// Requests still running keep their connections, which are closed when idle for IdleConnTimeout
+	pooled.client.CloseIdleConnections()
+	delete(p.clients, settings)
+	httpTransportsGauge.Dec()
+}
@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+)
+
+func TestClientPool(t *testing.T) {
+	pool := NewClientPool()
+	settings := ClientSettingsFromConfig(config.Spec)
+	other := settings
+	other.RequestTimeout = time.Second
+
+	client1, release1 := pool.Acquire(settings)
+	client2, release2 := pool.Acquire(settings)
+	client3, release3 := pool.Acquire(other)
+	assert.Same(t, client1, client2)
+	assert.Same(t, client1.Transport, client2.Transport)
+	assert.NotSame(t, client1, client3)
+	assert.Equal(t, time.Second, client3.Timeout)
+	assert.Len(t, pool.clients, 2)
+
+	release1()
+	// Releasing twice must not drop the reference of the other user
+	release1()
+	assert.Equal(t, 1, pool.clients[settings].refs)
+
+	release2()
+	release3()
+	assert.Empty(t, pool.clients)
+
+	client4, release4 := pool.Acquire(settings)
+	defer release4()
+	assert.NotSame(t, client1, client4)
+}
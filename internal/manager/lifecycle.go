@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle ties a worker to a context: Stop cancels it and Done is closed once the worker returns.
+type lifecycle struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	stopped bool
+	done    chan struct{}
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{done: make(chan struct{})}
+}
+
+// start returns the context the worker runs on, which is already done if Stop came first.
+func (l *lifecycle) start(parent context.Context) context.Context {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ctx, cancel := context.WithCancel(parent)
+	l.cancel = cancel
+	if l.stopped {
+		cancel()
+	}
+	return ctx
+}
+
+// Stop asks the worker to stop without waiting for it.
+func (l *lifecycle) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stopped = true
+	if l.cancel != nil {
+		l.cancel()
+	}
+}
+
+// Done is closed once the worker has stopped.
+func (l *lifecycle) Done() <-chan struct{} {
+	return l.done
+}
+
+// finish is called by the worker when it returns.
+func (l *lifecycle) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cancel != nil {
+		l.cancel()
+	}
+	close(l.done)
+}
@@ -5,30 +5,75 @@
 package manager
 
 import (
+	"context"
 	"sync"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
 )
 
 type GoroutineManager struct {
-	mu      sync.Mutex
-	workers map[string]Worker
+	mu       sync.Mutex
+	workers  map[string]Worker
+	ctx      context.Context
+	cancel   context.CancelFunc
+	running  sync.WaitGroup
+	shutdown bool
 }
 
 func NewGoroutineManager() *GoroutineManager {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &GoroutineManager{
 		workers: make(map[string]Worker),
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 }
 
+// AddWorker starts a worker unless one with the same ID exists or the manager is shutting down.
 func (gm *GoroutineManager) AddWorker(worker Worker) bool {
 	gm.mu.Lock()
 	defer gm.mu.Unlock()
 	id := worker.GetID()
-	if _, exists := gm.workers[id]; !exists {
-		gm.workers[id] = worker
-		go worker.Start()
-		return true
+	if _, exists := gm.workers[id]; exists || gm.shutdown {
+		return false
 	}
-	return false
+	gm.workers[id] = worker
+	gm.running.Add(1)
+	worker.Start(gm.ctx)
+	go func() {
+		<-worker.Done()
+		gm.running.Done()
+	}()
+	return true
+}
+
+// Shutdown stops every worker and waits for them, including removed ones
+// still finishing their round, until ctx is done.
+func (gm *GoroutineManager) Shutdown(ctx context.Context) error {
+	gm.mu.Lock()
+	gm.shutdown = true
+	gm.mu.Unlock()
+	gm.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		gm.running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Start lets the controller-runtime manager shut the workers down when it stops.
+func (gm *GoroutineManager) Start(ctx context.Context) error {
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Get().ShutdownTimeout)
+	defer cancel()
+	return gm.Shutdown(shutdownCtx)
 }
 
 func (gm *GoroutineManager) RemoveWorker(id string) bool {
@@ -59,10 +104,17 @@ func (gm *GoroutineManager) ListWorkerIDs() []string {
 	return ids
 }
 
+// ForEachWorker calls f for every worker without holding the manager lock, so
+// f may block, e.g. sending to a busy worker, without blocking the others.
+// Workers removed meanwhile are still visited; f must not wait on them.
 func (gm *GoroutineManager) ForEachWorker(f func(Worker)) {
 	gm.mu.Lock()
-	defer gm.mu.Unlock()
+	workers := make([]Worker, 0, len(gm.workers))
 	for _, worker := range gm.workers {
+		workers = append(workers, worker)
+	}
+	gm.mu.Unlock()
+	for _, worker := range workers {
 		f(worker)
 	}
 }
@@ -5,19 +5,34 @@
 package manager
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type work struct {
+	*lifecycle
 	ID string
+	// hold delays stopping, like a round in progress
+	hold chan struct{}
 }
 
-func (work) Start() {
+func newWork(id string) work {
+	return work{lifecycle: newLifecycle(), ID: id}
 }
 
-func (work) Stop() {
+func (w work) Start(ctx context.Context) {
+	ctx = w.start(ctx)
+	go func() {
+		defer w.finish()
+		<-ctx.Done()
+		if w.hold != nil {
+			<-w.hold
+		}
+	}()
 }
 
 func (w work) GetID() string {
@@ -34,8 +49,8 @@ func TestManagerGoRoutines(t *testing.T) {
 		manager := NewGoroutineManager()
 		assert.Len(manager.workers, 0)
 
-		w := work{ID: "1"}
-		w2 := work{ID: "2"}
+		w := newWork("1")
+		w2 := newWork("2")
 
 		manager.AddWorker(w)
 		assert.Len(manager.workers, 1)
@@ -47,8 +62,8 @@ func TestManagerGoRoutines(t *testing.T) {
 	t.Run("should remove work", func(t *testing.T) {
 		assert := assert.New(t)
 		manager := NewGoroutineManager()
-		w1 := work{ID: "1"}
-		w2 := work{ID: "2"}
+		w1 := newWork("1")
+		w2 := newWork("2")
 		manager.AddWorker(w1)
 		assert.Len(manager.workers, 1)
 		manager.AddWorker(w2)
@@ -57,5 +72,54 @@ func TestManagerGoRoutines(t *testing.T) {
 		assert.Len(manager.workers, 1)
 		ids := manager.ListWorkerIDs()
 		assert.Equal("2", ids[0])
+		<-w1.Done()
+	})
+
+	t.Run("should wait for workers on shutdown", func(t *testing.T) {
+		manager := NewGoroutineManager()
+		w1 := newWork("1")
+		w1.hold = make(chan struct{})
+		w2 := newWork("2")
+		require.True(t, manager.AddWorker(w1))
+		require.True(t, manager.AddWorker(w2))
+
+		// Removed workers still finishing are waited for too
+		manager.RemoveWorker("1")
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, manager.Shutdown(ctx), context.DeadlineExceeded)
+		<-w2.Done()
+
+		close(w1.hold)
+		assert.NoError(t, manager.Shutdown(context.Background()))
+		assert.False(t, manager.AddWorker(newWork("3")))
+	})
+
+	t.Run("should not lock the manager while visiting workers", func(t *testing.T) {
+		manager := NewGoroutineManager()
+		defer manager.Shutdown(context.Background())
+		require.True(t, manager.AddWorker(newWork("1")))
+		visiting := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		go manager.ForEachWorker(func(Worker) {
+			close(visiting)
+			<-release
+		})
+		<-visiting
+
+		done := make(chan struct{})
+		go func() {
+			manager.ListWorkerIDs()
+			manager.AddWorker(newWork("2"))
+			manager.RemoveWorker("1")
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("manager locked by a worker being visited")
+		}
+		assert.Equal(t, []string{"2"}, manager.ListWorkerIDs())
 	})
 }
@@ -12,6 +12,13 @@ var readLatencyHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpt
 	Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
 }, []string{"service_name", "rpaas_instance", "zone"})
 
+var readEntriesHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_nginx_pod_read_entries",
+	Help:      "Histogram of entries transferred per rate-limit RPaaS pod zone read",
+	Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+}, []string{"service_name", "rpaas_instance", "zone", "read_type"})
+
 var aggregateLatencyHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 	Namespace: "rate_limit_control_plane",
 	Name:      "rpaas_instance_rate_limit_aggregation_duration_seconds",
@@ -19,7 +26,56 @@ var aggregateLatencyHistogramVec = prometheus.NewHistogramVec(prometheus.Histogr
 	Buckets:   prometheus.ExponentialBuckets(0.001, 2, 12),
 }, []string{"service_name", "rpaas_instance", "zone"})
 
+var requestQueueWaitHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_nginx_pod_request_queue_wait_seconds",
+	Help:      "Histogram of time waited for a slot of the shared concurrency budget before RPaaS pod requests in seconds",
+	Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+}, []string{"rpaas_instance", "operation"})
+
+var inFlightRequestsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_nginx_pod_requests_in_flight",
+	Help:      "Number of RPaaS pod requests in flight",
+})
+
+var queuedRequestsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_nginx_pod_requests_queued",
+	Help:      "Number of RPaaS pod requests waiting for the shared concurrency budget",
+})
+
+var clockSkewGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_nginx_pod_clock_skew_seconds",
+	Help:      "Estimated offset of the RPaaS pod clock from the controller clock in seconds, positive when the pod is ahead",
+}, []string{"service_name", "rpaas_instance", "pod"})
+
+var podResetsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_nginx_pod_resets_total",
+	Help:      "Total number of RPaaS pod counter resets detected",
+}, []string{"service_name", "rpaas_instance", "pod", "reason"})
+
+var httpTransportsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_nginx_pod_http_transports",
+	Help:      "Number of HTTP transports shared by the RPaaS pod workers",
+})
+
 // Error/Reliability Metrics
+var workerPanicsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_worker_panics_total",
+	Help:      "Total number of panics recovered from RPaaS instance and pod workers",
+}, []string{"service_name", "rpaas_instance", "worker_type", "pod"})
+
+var workerRestartsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_worker_restarts_total",
+	Help:      "Total number of RPaaS instance and pod workers restarted after a panic",
+}, []string{"service_name", "rpaas_instance", "worker_type", "pod"})
+
 var readOperationsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
 	Namespace: "rate_limit_control_plane",
 	Name:      "rpaas_nginx_pod_read_operations_total",
@@ -38,6 +94,18 @@ var activeWorkersGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 	Help:      "Number of active workers by type",
 }, []string{"service_name", "rpaas_instance", "worker_type"})
 
+var synchronizedZonesGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_instance_synchronized_zones",
+	Help:      "Number of zones synchronized across the pods of RPaaS instances",
+}, []string{"service_name", "rpaas_instance"})
+
+var syncIntervalGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "rate_limit_control_plane",
+	Name:      "rpaas_instance_sync_interval_seconds",
+	Help:      "Effective interval between sync rounds of RPaaS instances, without jitter",
+}, []string{"service_name", "rpaas_instance"})
+
 // Rate Limiting Metrics
 var rateLimitEntriesCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
 	Namespace: "rate_limit_control_plane",
@@ -60,13 +128,24 @@ func GetZoneDataRepositoryMemoryGauge() prometheus.Gauge {
 func init() {
 	metrics.Registry.MustRegister(readLatencyHistogramVec)
 	metrics.Registry.MustRegister(aggregateLatencyHistogramVec)
+	metrics.Registry.MustRegister(requestQueueWaitHistogramVec)
+	metrics.Registry.MustRegister(inFlightRequestsGauge)
+	metrics.Registry.MustRegister(queuedRequestsGauge)
+	metrics.Registry.MustRegister(httpTransportsGauge)
+	metrics.Registry.MustRegister(clockSkewGaugeVec)
+	metrics.Registry.MustRegister(podResetsCounterVec)
+	metrics.Registry.MustRegister(readEntriesHistogramVec)
 
 	// Register error/reliability metrics
 	metrics.Registry.MustRegister(readOperationsCounterVec)
 	metrics.Registry.MustRegister(aggregationFailuresCounterVec)
+	metrics.Registry.MustRegister(workerPanicsCounterVec)
+	metrics.Registry.MustRegister(workerRestartsCounterVec)
 
 	// Register performance/throughput metrics
 	metrics.Registry.MustRegister(activeWorkersGaugeVec)
+	metrics.Registry.MustRegister(synchronizedZonesGaugeVec)
+	metrics.Registry.MustRegister(syncIntervalGaugeVec)
 
 	// Register rate limiting metrics
 	metrics.Registry.MustRegister(rateLimitEntriesCounterVec)
@@ -0,0 +1,185 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+const (
+	OverrideActionSet    = "set"
+	OverrideActionDelete = "delete"
+	OverrideActionExpire = "expire"
+)
+
+// Override forces the aggregated excess of a key in a zone until it expires.
+type Override struct {
+	Instance  string    `json:"instance"`
+	Zone      string    `json:"zone"`
+	Key       string    `json:"key"`
+	Excess    int64     `json:"excess"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	User     string    `json:"user,omitempty"`
+	Override Override  `json:"override"`
+}
+
+type overrideID struct {
+	Instance string
+	Zone     string
+	Key      string
+}
+
+type OverrideStore struct {
+	mu           sync.Mutex
+	logger       *slog.Logger
+	overrides    map[overrideID]Override
+	audit        []AuditEntry
+	maxAuditSize int
+}
+
+func NewOverrideStore(logger *slog.Logger, maxAuditSize int) *OverrideStore {
+	return &OverrideStore{
+		logger:       logger.With("component", "overrides"),
+		overrides:    make(map[overrideID]Override),
+		maxAuditSize: maxAuditSize,
+	}
+}
+
+func (s *OverrideStore) Set(override Override) error {
+	if override.Instance == "" || override.Zone == "" || override.Key == "" {
+		return fmt.Errorf("instance, zone and key are required")
+	}
+	if override.Excess < 0 {
+		return fmt.Errorf("excess must not be negative")
+	}
+	if !override.ExpiresAt.After(override.CreatedAt) {
+		return fmt.Errorf("override must expire after it is created")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[overrideID{Instance: override.Instance, Zone: override.Zone, Key: override.Key}] = override
+	s.record(AuditEntry{Time: override.CreatedAt, Action: OverrideActionSet, User: override.CreatedBy, Override: override})
+	return nil
+}
+
+func (s *OverrideStore) Delete(instance, zone, key, user string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := overrideID{Instance: instance, Zone: zone, Key: key}
+	override, exists := s.overrides[id]
+	if !exists {
+		return false
+	}
+	delete(s.overrides, id)
+	s.record(AuditEntry{Time: time.Now(), Action: OverrideActionDelete, User: user, Override: override})
+	return true
+}
+
+// List returns the overrides of an instance that have not expired.
+func (s *OverrideStore) List(instance string) []Override {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	overrides := []Override{}
+	for id, override := range s.overrides {
+		if id.Instance == instance {
+			overrides = append(overrides, override)
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		if overrides[i].Zone != overrides[j].Zone {
+			return overrides[i].Zone < overrides[j].Zone
+		}
+		return overrides[i].Key < overrides[j].Key
+	})
+	return overrides
+}
+
+// Active returns the overrides of an instance zone that have not expired at now.
+func (s *OverrideStore) Active(instance, zone string, now time.Time) []Override {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now)
+	overrides := []Override{}
+	for id, override := range s.overrides {
+		if id.Instance == instance && id.Zone == zone {
+			overrides = append(overrides, override)
+		}
+	}
+	return overrides
+}
+
+func (s *OverrideStore) Audit() []AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	audit := make([]AuditEntry, len(s.audit))
+	copy(audit, s.audit)
+	return audit
+}
+
+func (s *OverrideStore) expire(now time.Time) {
+	for id, override := range s.overrides {
+		if !now.Before(override.ExpiresAt) {
+			delete(s.overrides, id)
+			s.record(AuditEntry{Time: now, Action: OverrideActionExpire, Override: override})
+		}
+	}
+}
+
+func (s *OverrideStore) record(entry AuditEntry) {
+	s.logger.Info("Rate limit override changed", "action", entry.Action, "user", entry.User, "instance", entry.Override.Instance, "zone", entry.Override.Zone, "key", entry.Override.Key, "excess", entry.Override.Excess, "reason", entry.Override.Reason, "expiresAt", entry.Override.ExpiresAt)
+	s.audit = append(s.audit, entry)
+	if len(s.audit) > s.maxAuditSize {
+		s.audit = s.audit[len(s.audit)-s.maxAuditSize:]
+	}
+}
+
+// applyOverrides replaces the excess of overridden keys in the aggregated zone
+// and in its full zone, adding entries for keys the pods don't know yet.
+// It returns the zone with only the overridden entries, to be pushed to pods.
+func applyOverrides(zone *ratelimit.Zone, fullZone map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry, overrides []Override, now time.Time) (ratelimit.Zone, error) {
+	overridden := ratelimit.Zone{
+		Name:             zone.Name,
+		RateLimitHeader:  zone.RateLimitHeader,
+		RateLimitEntries: make([]ratelimit.RateLimitEntry, 0, len(overrides)),
+	}
+	var errs []error
+	indexByKey := make(map[string]int, len(zone.RateLimitEntries))
+	for i, entry := range zone.RateLimitEntries {
+		indexByKey[entry.Key.String(zone.RateLimitHeader)] = i
+	}
+	for _, override := range overrides {
+		key, err := ratelimit.ParseKey(override.Key, zone.RateLimitHeader)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid override key %q: %w", override.Key, err))
+			continue
+		}
+		keyString := key.String(zone.RateLimitHeader)
+		var entry ratelimit.RateLimitEntry
+		if i, exists := indexByKey[keyString]; exists {
+			zone.RateLimitEntries[i].Excess = override.Excess
+			entry = zone.RateLimitEntries[i]
+		} else {
+			entry = ratelimit.RateLimitEntry{Key: key, Last: now.UnixMilli(), Excess: override.Excess}
+			zone.RateLimitEntries = append(zone.RateLimitEntries, entry)
+		}
+		if fullZone != nil {
+			fullZone[ratelimit.FullZoneKey{Zone: zone.Name, Key: keyString}] = &ratelimit.RateLimitEntry{Key: entry.Key, Last: entry.Last, Excess: entry.Excess}
+		}
+		overridden.RateLimitEntries = append(overridden.RateLimitEntries, entry)
+	}
+	return overridden, errors.Join(errs...)
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+func TestOverrideStore(t *testing.T) {
+	now := time.Now()
+	newStore := func() *OverrideStore {
+		return NewOverrideStore(slog.New(slog.NewTextHandler(io.Discard, nil)), 3)
+	}
+
+	t.Run("should reject invalid overrides", func(t *testing.T) {
+		store := newStore()
+		assert.Error(t, store.Set(Override{Instance: instanceName, Zone: "one", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}))
+		assert.Error(t, store.Set(Override{Instance: instanceName, Zone: "one", Key: "k", Excess: -1, CreatedAt: now, ExpiresAt: now.Add(time.Minute)}))
+		assert.Error(t, store.Set(Override{Instance: instanceName, Zone: "one", Key: "k", CreatedAt: now, ExpiresAt: now}))
+		assert.Empty(t, store.Audit())
+	})
+
+	t.Run("should list active overrides and expire old ones", func(t *testing.T) {
+		store := newStore()
+		require.NoError(t, store.Set(Override{Instance: instanceName, Zone: "one", Key: "a", Excess: 10, CreatedAt: now, ExpiresAt: now.Add(time.Minute)}))
+		require.NoError(t, store.Set(Override{Instance: instanceName, Zone: "one", Key: "b", Excess: 10, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}))
+		require.NoError(t, store.Set(Override{Instance: instanceName, Zone: "two", Key: "a", Excess: 10, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}))
+		require.NoError(t, store.Set(Override{Instance: "other", Zone: "one", Key: "a", Excess: 10, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}))
+
+		assert.Len(t, store.Active(instanceName, "one", now), 2)
+		assert.Len(t, store.List(instanceName), 3)
+
+		active := store.Active(instanceName, "one", now.Add(2*time.Minute))
+		require.Len(t, active, 1)
+		assert.Equal(t, "b", active[0].Key)
+
+		audit := store.Audit()
+		require.Len(t, audit, 3)
+		assert.Equal(t, OverrideActionExpire, audit[2].Action)
+		assert.Equal(t, "a", audit[2].Override.Key)
+	})
+
+	t.Run("should delete overrides recording the user", func(t *testing.T) {
+		store := newStore()
+		require.NoError(t, store.Set(Override{Instance: instanceName, Zone: "one", Key: "a", CreatedBy: "alice", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}))
+		assert.True(t, store.Delete(instanceName, "one", "a", "bob"))
+		assert.False(t, store.Delete(instanceName, "one", "a", "bob"))
+		assert.Empty(t, store.List(instanceName))
+		audit := store.Audit()
+		require.Len(t, audit, 2)
+		assert.Equal(t, AuditEntry{Time: now, Action: OverrideActionSet, User: "alice", Override: audit[0].Override}, audit[0])
+		assert.Equal(t, OverrideActionDelete, audit[1].Action)
+		assert.Equal(t, "bob", audit[1].User)
+	})
+}
+
+func TestApplyOverrides(t *testing.T) {
+	now := time.Now()
+	header := ratelimit.RateLimitHeader{Key: ratelimit.BinaryRemoteAddress}
+	zone := ratelimit.Zone{
+		Name:            "one",
+		RateLimitHeader: header,
+		RateLimitEntries: []ratelimit.RateLimitEntry{
+			{Key: ratelimit.Key(net.ParseIP("10.0.0.1").To4()), Last: 100, Excess: 500},
+			{Key: ratelimit.Key(net.ParseIP("10.0.0.2").To4()), Last: 100, Excess: 300},
+		},
+	}
+	fullZone := map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry{}
+	overrides := []Override{
+		{Zone: "one", Key: "10.0.0.1", Excess: 0},
+		{Zone: "one", Key: "10.0.0.3", Excess: 20000},
+		{Zone: "one", Key: "not-an-ip", Excess: 20000},
+	}
+
+	overridden, err := applyOverrides(&zone, fullZone, overrides, now)
+	assert.Error(t, err)
+
+	assert.Equal(t, []ratelimit.RateLimitEntry{
+		{Key: ratelimit.Key(net.ParseIP("10.0.0.1").To4()), Last: 100, Excess: 0},
+		{Key: ratelimit.Key(net.ParseIP("10.0.0.2").To4()), Last: 100, Excess: 300},
+		{Key: ratelimit.Key(net.ParseIP("10.0.0.3").To4()), Last: now.UnixMilli(), Excess: 20000},
+	}, zone.RateLimitEntries)
+	assert.Equal(t, []ratelimit.RateLimitEntry{
+		{Key: ratelimit.Key(net.ParseIP("10.0.0.1").To4()), Last: 100, Excess: 0},
+		{Key: ratelimit.Key(net.ParseIP("10.0.0.3").To4()), Last: now.UnixMilli(), Excess: 20000},
+	}, overridden.RateLimitEntries)
+	assert.Equal(t, int64(20000), fullZone[ratelimit.FullZoneKey{Zone: "one", Key: "10.0.0.3"}].Excess)
+	assert.Equal(t, int64(0), fullZone[ratelimit.FullZoneKey{Zone: "one", Key: "10.0.0.1"}].Excess)
+}
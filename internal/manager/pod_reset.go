@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+const (
+	PodResetReasonHeader           = "header"
+	PodResetReasonMonotonicClock   = "monotonic_clock"
+	PodResetReasonBootTime         = "boot_time"
+	PodResetReasonContainerRestart = "container_restart"
+)
+
+// podBootTimeTolerance is how much the wall time at the start of the pod
+// monotonic clock may drift between reads, as NTP adjusts the wall clock.
+const podBootTimeTolerance = time.Second
+
+// errPodReset is returned for the first read of each zone after a pod reset.
+// Its counters started over, so aggregating them would subtract the excess
+// from the other pods; the write back of the aggregated data seeds it instead.
+var errPodReset = errors.New("pod counters were reset, skipping until the zone is seeded")
+
+// podResetError is the errPodReset of a pod, which the instance worker seeds
+// with the aggregated zone even when aggregated data isn't persisted.
+type podResetError struct {
+	pod string
+}
+
+func (e podResetError) Error() string {
+	return errPodReset.Error()
+}
+
+func (e podResetError) Unwrap() error {
+	return errPodReset
+}
+
+// detectReset compares the headers of two reads of a zone.
+func detectReset(previous, current ratelimit.RateLimitHeader) string {
+	// Empty zones are read without a header
+	if previous.Now == 0 || current.Now == 0 {
+		return ""
+	}
+	if current.Key != previous.Key {
+		return PodResetReasonHeader
+	}
+	if current.NowMonotonic < previous.NowMonotonic {
+		return PodResetReasonMonotonicClock
+	}
+	drift := (current.Now - current.NowMonotonic) - (previous.Now - previous.NowMonotonic)
+	if drift > podBootTimeTolerance.Milliseconds() || drift < -podBootTimeTolerance.Milliseconds() {
+		return PodResetReasonBootTime
+	}
+	return ""
+}
+
+// ObserveRestarts resets the incremental read state when the container restart count of the pod changes.
+func (w *RpaasPodWorker) ObserveRestarts(restarts int32) {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	if w.restarts != nil && *w.restarts != restarts {
+		w.reset(PodResetReasonContainerRestart)
+	}
+	w.restarts = &restarts
+}
+
+// checkReset records the header of a zone read, resetting the state if it
+// tells the pod was reset. It returns whether the read must be skipped
+// because the zone wasn't seeded since the last reset.
+func (w *RpaasPodWorker) checkReset(zone string, header ratelimit.RateLimitHeader) bool {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	if reason := detectReset(w.lastHeaderPerZone[zone], header); reason != "" {
+		w.reset(reason)
+	}
+	w.lastHeaderPerZone[zone] = header
+	if w.resetZones != nil && !w.resetZones[zone] {
+		w.resetZones[zone] = true
+		return true
+	}
+	return false
+}
+
+// reset drops the incremental read state, must be called with stateMu held.
+func (w *RpaasPodWorker) reset(reason string) {
+	w.logger.Warn("Pod reset detected - resetting incremental read state", "reason", reason)
+	podResetsCounterVec.WithLabelValues(w.Service, w.Instance, w.Name, reason).Inc()
+	w.readStatePerZone = make(map[string]zoneReadState)
+	w.lastHeaderPerZone = make(map[string]ratelimit.RateLimitHeader)
+	w.entriesPerZone = make(map[string]zoneEntries)
+	// Holds the zones read since the reset, every other zone is skipped once
+	w.resetZones = make(map[string]bool)
+	w.resets++
+}
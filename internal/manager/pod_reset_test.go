@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+func TestDetectReset(t *testing.T) {
+	previous := ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_000_000, NowMonotonic: 5_000}
+
+	tests := []struct {
+		name    string
+		current ratelimit.RateLimitHeader
+		reason  string
+	}{
+		{"next read", ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_001_000, NowMonotonic: 6_000}, ""},
+		{"wall clock adjusted", ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_001_500, NowMonotonic: 6_000}, ""},
+		{"empty zone", ratelimit.RateLimitHeader{}, ""},
+		{"key changed", ratelimit.RateLimitHeader{Key: "http_x_real_ip", Now: 1_001_000, NowMonotonic: 6_000}, PodResetReasonHeader},
+		{"monotonic clock went back", ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_001_000, NowMonotonic: 100}, PodResetReasonMonotonicClock},
+		{"node rebooted", ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_500_000, NowMonotonic: 6_000}, PodResetReasonBootTime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.reason, detectReset(previous, tt.current))
+		})
+	}
+}
+
+func TestRpaasPodWorkerReset(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	w := NewRpaasPodWorker(RpaasPodData{Name: "pod-1"}, RpaasInstanceData{Instance: "instance", Service: "service"}, logger, nil)
+
+	header := ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_000_000, NowMonotonic: 5_000}
+	assert.False(t, w.checkReset("zone-a", header))
+	assert.False(t, w.checkReset("zone-b", header))
+	w.readStatePerZone["zone-a"] = zoneReadState{watermark: 5_000}
+
+	// The first restart count observed is the baseline
+	w.ObserveRestarts(2)
+	assert.Equal(t, 0, w.resetCount())
+
+	// The monotonic clock of the new process started over
+	header = ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_001_000, NowMonotonic: 100}
+	assert.True(t, w.checkReset("zone-a", header))
+	assert.Equal(t, 1, w.resetCount())
+	assert.Empty(t, w.readStatePerZone)
+
+	// Every zone is skipped once, until it is seeded with the aggregated data
+	assert.False(t, w.checkReset("zone-a", ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_002_000, NowMonotonic: 1_100}))
+	assert.True(t, w.checkReset("zone-b", ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_002_000, NowMonotonic: 1_100}))
+
+	w.ObserveRestarts(2)
+	assert.Equal(t, 1, w.resetCount())
+	w.ObserveRestarts(3)
+	assert.Equal(t, 2, w.resetCount())
+	assert.True(t, w.checkReset("zone-a", ratelimit.RateLimitHeader{Key: "remote_addr", Now: 1_003_000, NowMonotonic: 2_100}))
+}
@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+const (
+	ReadTypeFull        = "full"
+	ReadTypeIncremental = "incremental"
+
+	// entryEvictionHorizon is how long nginx keeps an idle entry of a
+	// limit_req zone at least before it may evict it.
+	entryEvictionHorizon = 60 * time.Second
+)
+
+// zoneReadState is the incremental read window of a zone of a pod. The
+// watermark is the pod monotonic clock when the previous successful read
+// started, every entry changed since then has a Last at or after it.
+type zoneReadState struct {
+	watermark    int64
+	lastFullRead time.Time
+}
+
+// readType reads everything on the first read and every fullReadInterval
+// after it, so entries a read window could miss are eventually synchronized.
+func (s zoneReadState) readType(now time.Time, fullReadInterval time.Duration) string {
+	if s.watermark == 0 {
+		return ReadTypeFull
+	}
+	if fullReadInterval > 0 && now.Sub(s.lastFullRead) >= fullReadInterval {
+		return ReadTypeFull
+	}
+	return ReadTypeIncremental
+}
+
+// advance moves the window after a successful read. The header is written
+// before the entries, so entries updated while the zone is sent are read
+// again next time rather than lost.
+func (s zoneReadState) advance(header ratelimit.RateLimitHeader, readType string, start time.Time) zoneReadState {
+	next := zoneReadState{watermark: header.NowMonotonic, lastFullRead: s.lastFullRead}
+	if readType == ReadTypeFull {
+		next.lastFullRead = start
+	}
+	return next
+}
+
+// zoneEntries holds the last value read from or written to a pod of every
+// entry of a zone, by key, in the controller timebase.
+type zoneEntries map[string]ratelimit.RateLimitEntry
+
+// mergeEntries records the entries read from a zone and returns every entry
+// of it known on the pod. Entries an incremental read leaves out didn't
+// change, so the aggregators always get the whole zone of the pod; a full
+// read replaces them, dropping the entries that expired. Between full reads,
+// entries idle past the eviction horizon at now, the header time in the
+// controller timebase, are dropped as the pod may have evicted them already.
+func mergeEntries(entries zoneEntries, read []ratelimit.RateLimitEntry, readType string, now int64) (zoneEntries, []ratelimit.RateLimitEntry) {
+	if readType == ReadTypeFull || entries == nil {
+		entries = make(zoneEntries, len(read))
+	}
+	entries.update(read)
+	if readType == ReadTypeFull {
+		return entries, read
+	}
+	horizon := now - entryEvictionHorizon.Milliseconds()
+	merged := make([]ratelimit.RateLimitEntry, 0, len(entries))
+	for key, entry := range entries {
+		if entry.Last < horizon {
+			delete(entries, key)
+			continue
+		}
+		merged = append(merged, entry)
+	}
+	return entries, merged
+}
+
+// update records entries written to the pod, which incremental reads may not
+// return again.
+func (e zoneEntries) update(written []ratelimit.RateLimitEntry) {
+	for _, entry := range written {
+		e[string(entry.Key)] = entry
+	}
+}
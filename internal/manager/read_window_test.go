@@ -0,0 +1,179 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/aggregator"
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+	"github.com/tsuru/rate-limit-control-plane/test"
+)
+
+func TestZoneReadStateReadType(t *testing.T) {
+	now := time.Now()
+	var state zoneReadState
+	assert.Equal(t, ReadTypeFull, state.readType(now, time.Minute))
+
+	state = state.advance(ratelimit.RateLimitHeader{Now: 2_000, NowMonotonic: 1_000}, ReadTypeFull, now)
+	assert.Equal(t, int64(1_000), state.watermark)
+	assert.Equal(t, ReadTypeIncremental, state.readType(now.Add(30*time.Second), time.Minute))
+	assert.Equal(t, ReadTypeFull, state.readType(now.Add(time.Minute), time.Minute))
+	assert.Equal(t, ReadTypeIncremental, state.readType(now.Add(time.Hour), 0))
+
+	// Incremental reads move the watermark but not the time of the last full read
+	state = state.advance(ratelimit.RateLimitHeader{Now: 3_000, NowMonotonic: 2_000}, ReadTypeIncremental, now.Add(30*time.Second))
+	assert.Equal(t, int64(2_000), state.watermark)
+	assert.Equal(t, now, state.lastFullRead)
+}
+
+func TestRpaasPodWorkerIncrementalReads(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+	repository := test.NewRepository()
+	go test.NewServerMock(listener, repository)
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	w := NewRpaasPodWorker(RpaasPodData{Name: "pod-1", URL: fmt.Sprintf("http://localhost:%s", port)}, RpaasInstanceData{Instance: instanceName, Service: serviceName}, logger, nil)
+	defer w.releaseClient()
+
+	excess := func(zone ratelimit.Zone) map[string]int64 {
+		excess := map[string]int64{}
+		for _, entry := range zone.RateLimitEntries {
+			excess[string(entry.Key)] = entry.Excess
+		}
+		return excess
+	}
+	round := func() int64 {
+		time.Sleep(5 * time.Millisecond)
+		now := time.Now().UnixMilli()
+		repository.SetHeader("one", test.Header{Key: "$remote_addr", Now: now, NowMonotonic: now})
+		return now
+	}
+
+	// The first read has no window
+	first := round()
+	entryA := &test.Body{Key: []byte("10.0.0.1"), Last: first - 500, Excess: 100}
+	entryB := &test.Body{Key: []byte("10.0.0.2"), Last: first - 300, Excess: 200}
+	setRepositoryData(repository, "one", []*test.Body{entryA, entryB})
+	zone, err := w.getZoneData(context.Background(), "one")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 100, "10.0.0.2": 200}, excess(zone))
+
+	// Entries changed at or after the start of the previous read are read
+	// again, new ones too, while the ones not transferred keep the value read
+	second := round()
+	entryA.Last = first
+	entryA.Excess = 150
+	entryB.Excess = 999
+	entryC := &test.Body{Key: []byte("10.0.0.3"), Last: second, Excess: 300}
+	setRepositoryData(repository, "one", []*test.Body{entryA, entryB, entryC})
+	zone, err = w.getZoneData(context.Background(), "one")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 150, "10.0.0.2": 200, "10.0.0.3": 300}, excess(zone))
+
+	// Unchanged entries aren't transferred, the ones at the watermark are read twice to be safe
+	round()
+	entryA.Excess = 999
+	entryC.Excess = 350
+	setRepositoryData(repository, "one", []*test.Body{entryA, entryB, entryC})
+	zone, err = w.getZoneData(context.Background(), "one")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 150, "10.0.0.2": 200, "10.0.0.3": 350}, excess(zone))
+
+	// An entry the pod evicted is dropped once idle past the eviction horizon,
+	// without waiting for the next full read. The skew correction takes part
+	// of the jump, so it goes well past the horizon
+	later := first + 2*entryEvictionHorizon.Milliseconds()
+	repository.SetHeader("one", test.Header{Key: "$remote_addr", Now: later, NowMonotonic: later})
+	entryA.Last = later
+	entryC.Last = later
+	setRepositoryData(repository, "one", []*test.Body{entryA, entryC})
+	zone, err = w.getZoneData(context.Background(), "one")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 999, "10.0.0.3": 350}, excess(zone))
+	assert.NotContains(t, w.entriesPerZone["one"], "10.0.0.2")
+
+	// A full read drops the entries that expired
+	w.readStatePerZone["one"] = zoneReadState{}
+	setRepositoryData(repository, "one", []*test.Body{entryC})
+	zone, err = w.getZoneData(context.Background(), "one")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"10.0.0.3": 350}, excess(zone))
+}
+
+func TestRpaasInstanceSyncWorkerIncrementalRounds(t *testing.T) {
+	tests := []struct {
+		persist bool
+		// Excess aggregated after each round
+		excess []int64
+	}{
+		// Pods are seeded with the aggregate, so each one only adds its increase
+		{persist: true, excess: []int64{20, 20, 30}},
+		// Pods keep their own counters, which are summed
+		{persist: false, excess: []int64{20, 20, 50}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("persist %t", tt.persist), func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+			notify := make(chan ratelimit.RpaasZoneData, 1)
+			rpaasInstanceData := RpaasInstanceData{Instance: instanceName, Service: serviceName}
+			worker := NewRpaasInstanceSyncWorker(rpaasInstanceData, []string{"one"}, logger, notify, &aggregator.CompleteAggregator{}, nil, nil)
+			defer worker.Ticker.Stop()
+			defer worker.PodWorkerManager.Shutdown(context.Background())
+			worker.SetSettings(InstanceSettings{PersistAggregatedData: &tt.persist}, nil)
+
+			var repositories []*test.Repositories
+			var podWorkers []*RpaasPodWorker
+			for _, name := range []string{"pod-a", "pod-b"} {
+				listener, err := net.Listen("tcp", ":0")
+				require.NoError(t, err)
+				defer listener.Close()
+				repository := test.NewRepository()
+				go test.NewServerMock(listener, repository)
+				_, port, err := net.SplitHostPort(listener.Addr().String())
+				require.NoError(t, err)
+				podWorker := NewRpaasPodWorker(RpaasPodData{Name: name, URL: fmt.Sprintf("http://localhost:%s", port)}, rpaasInstanceData, logger, worker.zoneDataChan)
+				require.True(t, worker.PodWorkerManager.AddWorker(podWorker))
+				repositories = append(repositories, repository)
+				podWorkers = append(podWorkers, podWorker)
+			}
+
+			round := func(excess *int64) int64 {
+				time.Sleep(5 * time.Millisecond)
+				now := time.Now().UnixMilli()
+				for _, repository := range repositories {
+					repository.SetHeader("one", test.Header{Key: "$remote_addr", Now: now, NowMonotonic: now})
+					if excess != nil {
+						// Before the watermark of the next read, which reads the entries at it twice
+						setRepositoryData(repository, "one", []*test.Body{{Key: []byte("10.0.0.1"), Last: now - 1, Excess: *excess}})
+					}
+				}
+				worker.processTick()
+				zoneData := <-notify
+				for _, podWorker := range podWorkers {
+					podWorker.flushWrites()
+				}
+				require.Len(t, zoneData.Data, 1)
+				require.Len(t, zoneData.Data[0].RateLimitEntries, 1)
+				return zoneData.Data[0].RateLimitEntries[0].Excess
+			}
+			ten, twentyFive := int64(10), int64(25)
+
+			assert.Equal(t, tt.excess[0], round(&ten))
+			// No entry changed, so the incremental reads transfer nothing
+			assert.Equal(t, tt.excess[1], round(nil))
+			assert.Equal(t, tt.excess[2], round(&twentyFive))
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+	"github.com/tsuru/rate-limit-control-plane/internal/recorder"
+)
+
+var zoneRecorder atomic.Pointer[recorder.Recorder]
+
+// SetRecorder records the zones read from the pods and their aggregation, nil stops recording.
+func SetRecorder(r *recorder.Recorder) {
+	zoneRecorder.Store(r)
+}
+
+// recordingBody tees the response body when the instance is recorded, the
+// returned buffer is nil otherwise.
+func (w *RpaasPodWorker) recordingBody(body io.Reader) (io.Reader, *bytes.Buffer) {
+	if !zoneRecorder.Load().Enabled(w.Instance) {
+		return body, nil
+	}
+	raw := new(bytes.Buffer)
+	return io.TeeReader(body, raw), raw
+}
+
+func (w *RpaasPodWorker) recordRead(zone, readType string, raw *bytes.Buffer, correction int64, err error) {
+	rec := zoneRecorder.Load()
+	if raw == nil || rec == nil {
+		return
+	}
+	record := recorder.Record{
+		Type:       recorder.RecordTypeRead,
+		Instance:   w.Instance,
+		Pod:        w.Name,
+		Zone:       zone,
+		ReadType:   readType,
+		Correction: correction,
+		Body:       raw.Bytes(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if err := rec.Record(record); err != nil {
+		w.logger.Warn("Error recording zone read", "zone", zone, "error", err)
+	}
+}
+
+func (w *RpaasInstanceSyncWorker) startRecordingRound() {
+	if rec := zoneRecorder.Load(); rec.Enabled(w.Instance) {
+		rec.StartRound(w.Instance)
+	}
+}
+
+func (w *RpaasInstanceSyncWorker) recordAggregated(zone ratelimit.Zone, persisted bool) {
+	rec := zoneRecorder.Load()
+	if !rec.Enabled(w.Instance) {
+		return
+	}
+	body, err := msgpack.Marshal(zone)
+	if err == nil {
+		err = rec.Record(recorder.Record{
+			Type:      recorder.RecordTypeAggregated,
+			Instance:  w.Instance,
+			Zone:      zone.Name,
+			Persisted: persisted,
+			Body:      body,
+		})
+	}
+	if err != nil {
+		w.logger.Warn("Error recording aggregated zone", "zone", zone.Name, "error", err)
+	}
+}
+
+// ReplayRound is the aggregation of a zone of an instance in a recorded round.
+type ReplayRound struct {
+	Instance string
+	Zone     string
+	Round    uint64
+	// Pods are the pods whose reads were aggregated, Skipped the ones whose reads failed or were reset.
+	Pods       []string
+	Skipped    []string
+	Aggregated ratelimit.Zone
+	// Recorded is the aggregation of the sync worker, when it was recorded.
+	Recorded *ratelimit.Zone
+}
+
+type replayKey struct {
+	instance string
+	zone     string
+}
+
+type replayPending struct {
+	round uint64
+	reads []recorder.Record
+}
+
+// Replay aggregates the recorded reads of each round with aggregator. The
+// state is carried between rounds as the sync worker does, when the recorded
+// aggregation says it was persisted or wasn't recorded, and so are the
+// entries known on each pod, which incremental reads only update. Allowlists
+// and overrides aren't applied, as they aren't part of the aggregation
+// recorded.
+func Replay(records []recorder.Record, aggregator ZoneAggregator) ([]ReplayRound, error) {
+	var rounds []ReplayRound
+	pending := map[replayKey]*replayPending{}
+	fullZones := map[replayKey]map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry{}
+	podEntries := map[replayKey]map[string]zoneEntries{}
+
+	flush := func(key replayKey, recorded *recorder.Record) error {
+		p := pending[key]
+		delete(pending, key)
+		round := ReplayRound{Instance: key.instance, Zone: key.zone}
+		var reads []recorder.Record
+		if p != nil {
+			round.Round = p.round
+			reads = p.reads
+		}
+		persisted := true
+		if recorded != nil {
+			round.Round = recorded.Round
+			var zone ratelimit.Zone
+			if err := msgpack.Unmarshal(recorded.Body, &zone); err != nil {
+				return fmt.Errorf("error decoding aggregated zone %s of %s in round %d: %w", key.zone, key.instance, recorded.Round, err)
+			}
+			round.Recorded = &zone
+			persisted = recorded.Persisted
+		}
+
+		if podEntries[key] == nil {
+			podEntries[key] = map[string]zoneEntries{}
+		}
+		pods := podEntries[key]
+		zoneData := []ratelimit.Zone{}
+		for _, read := range reads {
+			if read.Error == errPodReset.Error() {
+				delete(pods, read.Pod)
+			}
+			zone, err := replayRead(read)
+			if err != nil {
+				round.Skipped = append(round.Skipped, read.Pod)
+				continue
+			}
+			if len(read.Body) == 0 {
+				// The zone has no entries left
+				delete(pods, read.Pod)
+			} else {
+				pods[read.Pod], zone.RateLimitEntries = mergeEntries(pods[read.Pod], zone.RateLimitEntries, read.ReadType, zone.RateLimitHeader.Now)
+			}
+			round.Pods = append(round.Pods, read.Pod)
+			zoneData = append(zoneData, zone)
+		}
+		if len(zoneData) == 0 {
+			if recorded != nil {
+				rounds = append(rounds, round)
+			}
+			return nil
+		}
+		aggregated, newFullZone := aggregator.AggregateZones(zoneData, fullZones[key])
+		if persisted {
+			fullZones[key] = newFullZone
+			// The aggregated zone is written back to every pod
+			for _, entries := range pods {
+				entries.update(aggregated.RateLimitEntries)
+			}
+		}
+		round.Aggregated = aggregated
+		rounds = append(rounds, round)
+		return nil
+	}
+
+	for i := range records {
+		record := &records[i]
+		key := replayKey{instance: record.Instance, zone: record.Zone}
+		// Round numbers start over when the controller restarts
+		if p, ok := pending[key]; ok && p.round != record.Round {
+			if err := flush(key, nil); err != nil {
+				return nil, err
+			}
+		}
+		switch record.Type {
+		case recorder.RecordTypeRead:
+			if pending[key] == nil {
+				pending[key] = &replayPending{round: record.Round}
+			}
+			pending[key].reads = append(pending[key].reads, *record)
+		case recorder.RecordTypeAggregated:
+			if err := flush(key, record); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Rounds cut by the end of the recording
+	keys := make([]replayKey, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].instance < keys[j].instance || (keys[i].instance == keys[j].instance && keys[i].zone < keys[j].zone)
+	})
+	for _, key := range keys {
+		if err := flush(key, nil); err != nil {
+			return nil, err
+		}
+	}
+	return rounds, nil
+}
+
+// replayRead decodes a recorded read as getZoneData does.
+func replayRead(read recorder.Record) (ratelimit.Zone, error) {
+	if read.Error != "" {
+		return ratelimit.Zone{}, errors.New(read.Error)
+	}
+	header, entries, err := decodeZone(bytes.NewReader(read.Body))
+	if err != nil && err != io.EOF {
+		return ratelimit.Zone{}, err
+	}
+	if err == nil {
+		for i := range entries {
+			entries[i].NonMonotic(header)
+			entries[i].Last -= read.Correction
+		}
+		header.Now -= read.Correction
+	}
+	return ratelimit.Zone{Name: read.Zone, RateLimitHeader: header, RateLimitEntries: entries}, nil
+}
+
+// EntryDiff is a key whose entry differs between two aggregations, Left or Right being nil when it is missing.
+type EntryDiff struct {
+	Key   string
+	Left  *ratelimit.RateLimitEntry
+	Right *ratelimit.RateLimitEntry
+}
+
+// DiffZones returns the entries differing in Last or Excess, sorted by key.
+func DiffZones(left, right ratelimit.Zone) []EntryDiff {
+	entries := func(zone ratelimit.Zone) map[string]*ratelimit.RateLimitEntry {
+		byKey := make(map[string]*ratelimit.RateLimitEntry, len(zone.RateLimitEntries))
+		for i := range zone.RateLimitEntries {
+			byKey[zone.RateLimitEntries[i].Key.String(zone.RateLimitHeader)] = &zone.RateLimitEntries[i]
+		}
+		return byKey
+	}
+	leftEntries, rightEntries := entries(left), entries(right)
+	var diffs []EntryDiff
+	for key, l := range leftEntries {
+		r, ok := rightEntries[key]
+		if !ok || l.Last != r.Last || l.Excess != r.Excess {
+			diffs = append(diffs, EntryDiff{Key: key, Left: l, Right: r})
+		}
+	}
+	for key, r := range rightEntries {
+		if _, ok := leftEntries[key]; !ok {
+			diffs = append(diffs, EntryDiff{Key: key, Right: r})
+		}
+	}
+	slices.SortFunc(diffs, func(a, b EntryDiff) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return diffs
+}
@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/aggregator"
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+	"github.com/tsuru/rate-limit-control-plane/internal/recorder"
+	"github.com/tsuru/rate-limit-control-plane/test"
+)
+
+func TestReplay(t *testing.T) {
+	header := ratelimit.RateLimitHeader{Key: ratelimit.RemoteAddress, Now: 10_000, NowMonotonic: 1_000}
+	read := func(round uint64, pod string, excess int64) recorder.Record {
+		body := encodeZone(t, header, []ratelimit.RateLimitEntry{{Key: ratelimit.Key("10.0.0.1"), Last: 900, Excess: excess}})
+		return recorder.Record{Type: recorder.RecordTypeRead, Instance: "inst", Pod: pod, Zone: "one", Round: round, Correction: 100, Body: body}
+	}
+	aggregated := func(round uint64, excess int64, persisted bool) recorder.Record {
+		body, err := msgpack.Marshal(ratelimit.Zone{
+			Name:             "one",
+			RateLimitHeader:  header,
+			RateLimitEntries: []ratelimit.RateLimitEntry{{Key: ratelimit.Key("10.0.0.1"), Last: 9_800, Excess: excess}},
+		})
+		require.NoError(t, err)
+		return recorder.Record{Type: recorder.RecordTypeAggregated, Instance: "inst", Zone: "one", Round: round, Persisted: persisted, Body: body}
+	}
+	failed := read(1, "pod-c", 0)
+	failed.Error = "connection refused"
+
+	records := []recorder.Record{
+		read(1, "pod-a", 100),
+		read(1, "pod-b", 200),
+		failed,
+		aggregated(1, 300, true),
+		// The state of round 1 is subtracted, the recorded aggregation is wrong
+		read(2, "pod-a", 400),
+		read(2, "pod-b", 300),
+		aggregated(2, 999, false),
+		// Round 2 wasn't persisted, so the state is still the one of round 1
+		read(3, "pod-a", 400),
+		// The controller restarted, starting the rounds over
+		read(1, "pod-a", 50),
+	}
+	rounds, err := Replay(records, new(aggregator.CompleteAggregator))
+	require.NoError(t, err)
+	require.Len(t, rounds, 4)
+
+	assert.Equal(t, uint64(1), rounds[0].Round)
+	assert.Equal(t, []string{"pod-a", "pod-b"}, rounds[0].Pods)
+	assert.Equal(t, []string{"pod-c"}, rounds[0].Skipped)
+	require.Len(t, rounds[0].Aggregated.RateLimitEntries, 1)
+	assert.Equal(t, int64(300), rounds[0].Aggregated.RateLimitEntries[0].Excess)
+	// Last is moved to the wall clock of the pod and corrected
+	assert.Equal(t, int64(9_800), rounds[0].Aggregated.RateLimitEntries[0].Last)
+	require.NotNil(t, rounds[0].Recorded)
+	assert.Empty(t, DiffZones(rounds[0].Aggregated, *rounds[0].Recorded))
+
+	assert.Equal(t, int64(400), rounds[1].Aggregated.RateLimitEntries[0].Excess)
+	diffs := DiffZones(rounds[1].Aggregated, *rounds[1].Recorded)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, "10.0.0.1", diffs[0].Key)
+	assert.Equal(t, int64(999), diffs[0].Right.Excess)
+
+	assert.Equal(t, uint64(3), rounds[2].Round)
+	assert.Nil(t, rounds[2].Recorded)
+	assert.Equal(t, int64(400), rounds[2].Aggregated.RateLimitEntries[0].Excess)
+
+	assert.Equal(t, uint64(1), rounds[3].Round)
+	assert.Equal(t, int64(50), rounds[3].Aggregated.RateLimitEntries[0].Excess)
+}
+
+func TestReplayIncrementalReads(t *testing.T) {
+	header := ratelimit.RateLimitHeader{Key: ratelimit.RemoteAddress, Now: 10_000, NowMonotonic: 1_000}
+	read := func(round uint64, pod, readType string, entries ...ratelimit.RateLimitEntry) recorder.Record {
+		return recorder.Record{Type: recorder.RecordTypeRead, Instance: "inst", Pod: pod, Zone: "one", Round: round, ReadType: readType, Body: encodeZone(t, header, entries)}
+	}
+	entry := func(excess int64) ratelimit.RateLimitEntry {
+		return ratelimit.RateLimitEntry{Key: ratelimit.Key("10.0.0.1"), Last: 900, Excess: excess}
+	}
+
+	records := []recorder.Record{
+		// Reads recorded without a type are full ones
+		read(1, "pod-a", "", entry(10)),
+		read(1, "pod-b", ReadTypeFull, entry(10)),
+		// Nothing changed, the pods still have the aggregate written back
+		read(2, "pod-a", ReadTypeIncremental),
+		read(2, "pod-b", ReadTypeIncremental),
+		read(3, "pod-a", ReadTypeIncremental, entry(25)),
+		read(3, "pod-b", ReadTypeIncremental, entry(25)),
+		// The entry expired on pod-a
+		read(4, "pod-a", ReadTypeFull),
+		read(4, "pod-b", ReadTypeIncremental),
+	}
+	rounds, err := Replay(records, new(aggregator.CompleteAggregator))
+	require.NoError(t, err)
+	require.Len(t, rounds, 4)
+	for i, excess := range []int64{20, 20, 30} {
+		require.Len(t, rounds[i].Aggregated.RateLimitEntries, 1)
+		assert.Equal(t, excess, rounds[i].Aggregated.RateLimitEntries[0].Excess)
+	}
+	// pod-b keeps it until its next full read
+	require.Len(t, rounds[3].Aggregated.RateLimitEntries, 1)
+	assert.Equal(t, int64(30), rounds[3].Aggregated.RateLimitEntries[0].Excess)
+}
+
+func TestReplayInvalidAggregated(t *testing.T) {
+	_, err := Replay([]recorder.Record{{Type: recorder.RecordTypeAggregated, Instance: "inst", Zone: "one", Round: 1, Body: []byte{0xc1}}}, new(aggregator.CompleteAggregator))
+	assert.Error(t, err)
+}
+
+func TestDiffZones(t *testing.T) {
+	header := ratelimit.RateLimitHeader{Key: ratelimit.RemoteAddress}
+	left := ratelimit.Zone{RateLimitHeader: header, RateLimitEntries: []ratelimit.RateLimitEntry{
+		{Key: ratelimit.Key("10.0.0.1"), Last: 1, Excess: 100},
+		{Key: ratelimit.Key("10.0.0.2"), Last: 1, Excess: 100},
+		{Key: ratelimit.Key("10.0.0.3"), Last: 1, Excess: 100},
+	}}
+	right := ratelimit.Zone{RateLimitHeader: header, RateLimitEntries: []ratelimit.RateLimitEntry{
+		{Key: ratelimit.Key("10.0.0.4"), Last: 1, Excess: 100},
+		{Key: ratelimit.Key("10.0.0.2"), Last: 2, Excess: 100},
+		{Key: ratelimit.Key("10.0.0.1"), Last: 1, Excess: 100},
+	}}
+	diffs := DiffZones(left, right)
+	require.Len(t, diffs, 3)
+	assert.Equal(t, "10.0.0.2", diffs[0].Key)
+	assert.Equal(t, int64(2), diffs[0].Right.Last)
+	assert.Equal(t, "10.0.0.3", diffs[1].Key)
+	assert.Nil(t, diffs[1].Right)
+	assert.Equal(t, "10.0.0.4", diffs[2].Key)
+	assert.Nil(t, diffs[2].Left)
+}
+
+func TestRecordingReplaysSyncWorker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.rec")
+	rec, err := recorder.New(path, 1<<20, 2, []string{instanceName})
+	require.NoError(t, err)
+	SetRecorder(rec)
+	defer SetRecorder(nil)
+	defer rec.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+	repository := test.NewRepository()
+	go test.NewServerMock(listener, repository)
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	notify := make(chan ratelimit.RpaasZoneData, 2)
+	rpaasInstanceData := RpaasInstanceData{Instance: instanceName, Service: serviceName}
+	worker := NewRpaasInstanceSyncWorker(rpaasInstanceData, []string{"one"}, logger, notify, &aggregator.CompleteAggregator{}, nil, nil)
+	defer worker.Ticker.Stop()
+	for _, name := range []string{"pod-a", "pod-b"} {
+		worker.PodWorkerManager.AddWorker(NewRpaasPodWorker(RpaasPodData{Name: name, URL: fmt.Sprintf("http://localhost:%s", port)}, rpaasInstanceData, logger, worker.zoneDataChan))
+	}
+
+	setRepositoryData(repository, "one", []*test.Body{{Key: []byte("192.168.0.1"), Last: time.Now().UTC().UnixMilli(), Excess: 500}})
+	worker.processTick()
+	<-notify
+	setRepositoryData(repository, "one", []*test.Body{
+		{Key: []byte("192.168.0.1"), Last: time.Now().UTC().UnixMilli(), Excess: 700},
+		{Key: []byte("192.168.0.2"), Last: time.Now().UTC().UnixMilli(), Excess: 100},
+	})
+	worker.processTick()
+	<-notify
+	require.NoError(t, rec.Close())
+
+	records, err := recorder.ReadFiles(recorder.Files(path)...)
+	require.NoError(t, err)
+	rounds, err := Replay(records, new(aggregator.CompleteAggregator))
+	require.NoError(t, err)
+	require.Len(t, rounds, 2)
+	for _, round := range rounds {
+		assert.Equal(t, instanceName, round.Instance)
+		assert.ElementsMatch(t, []string{"pod-a", "pod-b"}, round.Pods)
+		require.NotNil(t, round.Recorded)
+		assert.Empty(t, DiffZones(round.Aggregated, *round.Recorded))
+	}
+	assert.Len(t, rounds[1].Aggregated.RateLimitEntries, 2)
+}
@@ -0,0 +1,138 @@
+package manager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+)
+
+const (
+	RequestOperationRead      = "read"
+	RequestOperationWrite     = "write"
+	RequestOperationDiscovery = "discovery"
+)
+
+type requestWaiter struct {
+	ready chan struct{}
+}
+
+// RequestScheduler bounds the concurrent admin requests to nginx pods across
+// every instance. Waiting requests are served round robin between instances,
+// so an instance with many pods can't starve the others.
+type RequestScheduler struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	queues   map[string][]*requestWaiter
+	// order holds the instances with waiting requests, the next one to be served first
+	order []string
+}
+
+// NewRequestScheduler returns a scheduler allowing limit concurrent requests, zero means no limit.
+func NewRequestScheduler(limit int) *RequestScheduler {
+	return &RequestScheduler{
+		limit:  limit,
+		queues: make(map[string][]*requestWaiter),
+	}
+}
+
+var defaultRequestScheduler = NewRequestScheduler(config.Spec.PodRequestConcurrency)
+
+// AcquirePodRequest waits for a slot of the shared scheduler, the returned function releases it.
+func AcquirePodRequest(ctx context.Context, instance, operation string) (func(), error) {
+	return defaultRequestScheduler.Acquire(ctx, instance, operation)
+}
+
+func (s *RequestScheduler) Acquire(ctx context.Context, instance, operation string) (func(), error) {
+	start := time.Now()
+	s.mu.Lock()
+	if s.limit <= 0 || (s.inFlight < s.limit && len(s.order) == 0) {
+		s.inFlight++
+		s.mu.Unlock()
+		s.observe(instance, operation, start)
+		return s.releaseOnce(), nil
+	}
+	waiter := &requestWaiter{ready: make(chan struct{})}
+	if len(s.queues[instance]) == 0 {
+		s.order = append(s.order, instance)
+	}
+	s.queues[instance] = append(s.queues[instance], waiter)
+	queuedRequestsGauge.Inc()
+	s.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		s.observe(instance, operation, start)
+		return s.releaseOnce(), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-waiter.ready:
+			s.mu.Unlock()
+			// Granted meanwhile, hand the slot over to the next one
+			inFlightRequestsGauge.Inc()
+			s.release()
+		default:
+			s.remove(instance, waiter)
+			s.mu.Unlock()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (s *RequestScheduler) releaseOnce() func() {
+	var once sync.Once
+	return func() {
+		once.Do(s.release)
+	}
+}
+
+func (s *RequestScheduler) observe(instance, operation string, start time.Time) {
+	requestQueueWaitHistogramVec.WithLabelValues(instance, operation).Observe(time.Since(start).Seconds())
+	inFlightRequestsGauge.Inc()
+}
+
+func (s *RequestScheduler) release() {
+	inFlightRequestsGauge.Dec()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.order) == 0 {
+		s.inFlight--
+		return
+	}
+	instance := s.order[0]
+	s.order = s.order[1:]
+	waiter := s.queues[instance][0]
+	s.queues[instance] = s.queues[instance][1:]
+	if len(s.queues[instance]) > 0 {
+		s.order = append(s.order, instance)
+	} else {
+		delete(s.queues, instance)
+	}
+	queuedRequestsGauge.Dec()
+	// The slot goes straight to the waiter, inFlight stays the same
+	close(waiter.ready)
+}
+
+func (s *RequestScheduler) remove(instance string, waiter *requestWaiter) {
+	queue := s.queues[instance]
+	for i, w := range queue {
+		if w == waiter {
+			s.queues[instance] = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	queuedRequestsGauge.Dec()
+	if len(s.queues[instance]) > 0 {
+		return
+	}
+	delete(s.queues, instance)
+	for i, name := range s.order {
+		if name == instance {
+			s.order = append(s.order[:i:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
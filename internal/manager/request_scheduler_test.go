@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func acquireAsync(s *RequestScheduler, ctx context.Context, instance string, granted chan<- string) {
+	go func() {
+		release, err := s.Acquire(ctx, instance, RequestOperationRead)
+		if err != nil {
+			return
+		}
+		granted <- instance
+		release()
+	}()
+}
+
+func waitQueued(t *testing.T, s *RequestScheduler, instance string, n int) {
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.queues[instance]) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestRequestSchedulerLimit(t *testing.T) {
+	s := NewRequestScheduler(2)
+	releaseA, err := s.Acquire(context.Background(), "a", RequestOperationRead)
+	require.NoError(t, err)
+	releaseB, err := s.Acquire(context.Background(), "a", RequestOperationRead)
+	require.NoError(t, err)
+
+	granted := make(chan string, 1)
+	acquireAsync(s, context.Background(), "b", granted)
+	waitQueued(t, s, "b", 1)
+	select {
+	case <-granted:
+		t.Fatal("request granted over the limit")
+	default:
+	}
+
+	releaseA()
+	// Releasing twice must not free a second slot
+	releaseA()
+	assert.Equal(t, "b", <-granted)
+	releaseB()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Equal(t, 0, s.inFlight)
+	assert.Empty(t, s.order)
+}
+
+func TestRequestSchedulerUnlimited(t *testing.T) {
+	s := NewRequestScheduler(0)
+	for i := 0; i < 100; i++ {
+		_, err := s.Acquire(context.Background(), "a", RequestOperationRead)
+		require.NoError(t, err)
+	}
+}
+
+func TestRequestSchedulerRoundRobin(t *testing.T) {
+	s := NewRequestScheduler(1)
+	release, err := s.Acquire(context.Background(), "busy", RequestOperationRead)
+	require.NoError(t, err)
+
+	// The busy instance queues three requests before the quiet one asks for a slot
+	granted := make(chan string)
+	for i := 0; i < 3; i++ {
+		acquireAsync(s, context.Background(), "busy", granted)
+	}
+	waitQueued(t, s, "busy", 3)
+	acquireAsync(s, context.Background(), "quiet", granted)
+	waitQueued(t, s, "quiet", 1)
+
+	release()
+	order := []string{}
+	for i := 0; i < 4; i++ {
+		order = append(order, <-granted)
+	}
+	assert.Equal(t, []string{"busy", "quiet", "busy", "busy"}, order)
+}
+
+func TestRequestSchedulerCancel(t *testing.T) {
+	s := NewRequestScheduler(1)
+	release, err := s.Acquire(context.Background(), "a", RequestOperationRead)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		_, err := s.Acquire(ctx, "b", RequestOperationWrite)
+		errs <- err
+	}()
+	waitQueued(t, s, "b", 1)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+
+	s.mu.Lock()
+	assert.Empty(t, s.queues)
+	assert.Empty(t, s.order)
+	s.mu.Unlock()
+
+	release()
+	release, err = s.Acquire(context.Background(), "c", RequestOperationRead)
+	require.NoError(t, err)
+	release()
+}
@@ -1,8 +1,11 @@
 package manager
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
@@ -23,30 +26,28 @@ type RpaasInstanceData struct {
 
 type RpaasInstanceSyncWorker struct {
 	sync.Mutex
+	*lifecycle
 	RpaasInstanceData
-	RpaasInstanceSignals RpaasInstanceSignals
-	PodWorkerManager     *GoroutineManager
-	Ticker               *time.Ticker
-	logger               *slog.Logger
-	zoneDataChan         chan Optional[ratelimit.Zone]
-	notify               chan ratelimit.RpaasZoneData
-	fullZones            map[string]map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry
-	aggregator           ZoneAggregator
+	PodWorkerManager *GoroutineManager
+	Ticker           *time.Ticker
+	interval         time.Duration
+	logger           *slog.Logger
+	zoneDataChan     chan Optional[ratelimit.Zone]
+	notify           chan ratelimit.RpaasZoneData
+	fullZones        map[string]map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry
+	aggregator       ZoneAggregator
+	overrides        *OverrideStore
+	allowlists       *AllowlistStore
+	settings         InstanceSettings
+	settingsChanged  chan struct{}
+	adaptive         adaptiveInterval
+	resetFullZones   bool
+	status           instanceStatus
 }
 
-type RpaasInstanceSignals struct {
-	StartRpaasPodWorker chan [2]string
-	StopRpaasPodWorker  chan string
-	StopChan            chan struct{}
-}
-
-func NewRpaasInstanceSyncWorker(rpaasInstanceData RpaasInstanceData, zones []string, logger *slog.Logger, notify chan ratelimit.RpaasZoneData, aggregator ZoneAggregator) *RpaasInstanceSyncWorker {
-	signals := RpaasInstanceSignals{
-		StartRpaasPodWorker: make(chan [2]string),
-		StopRpaasPodWorker:  make(chan string),
-		StopChan:            make(chan struct{}),
-	}
-	ticker := time.NewTicker(config.Spec.ControllerIntervalDuration)
+func NewRpaasInstanceSyncWorker(rpaasInstanceData RpaasInstanceData, zones []string, logger *slog.Logger, notify chan ratelimit.RpaasZoneData, aggregator ZoneAggregator, overrides *OverrideStore, allowlists *AllowlistStore) *RpaasInstanceSyncWorker {
+	interval := config.Get().ControllerIntervalDuration
+	ticker := time.NewTicker(interval)
 	instanceLogger := logger.With("instanceName", rpaasInstanceData.Instance)
 
 	fullZones := make(map[string]map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry)
@@ -55,137 +56,389 @@ func NewRpaasInstanceSyncWorker(rpaasInstanceData RpaasInstanceData, zones []str
 	}
 
 	worker := &RpaasInstanceSyncWorker{
-		RpaasInstanceData:    rpaasInstanceData,
-		RpaasInstanceSignals: signals,
-		PodWorkerManager:     NewGoroutineManager(),
-		Ticker:               ticker,
-		logger:               instanceLogger,
-		zoneDataChan:         make(chan Optional[ratelimit.Zone]),
-		notify:               notify,
-		fullZones:            fullZones,
-		aggregator:           aggregator,
+		lifecycle:         newLifecycle(),
+		RpaasInstanceData: rpaasInstanceData,
+		PodWorkerManager:  NewGoroutineManager(),
+		Ticker:            ticker,
+		interval:          interval,
+		logger:            instanceLogger,
+		zoneDataChan:      make(chan Optional[ratelimit.Zone]),
+		notify:            notify,
+		fullZones:         fullZones,
+		aggregator:        aggregator,
+		overrides:         overrides,
+		allowlists:        allowlists,
+		settingsChanged:   make(chan struct{}, 1),
 	}
+	worker.status.interval = interval
+	worker.status.setZones(sortedZones(zones), sortedZones(zones))
 
 	// Initialize instance worker metrics
 	activeWorkersGaugeVec.WithLabelValues(rpaasInstanceData.Service, rpaasInstanceData.Instance, "instance").Inc()
+	synchronizedZonesGaugeVec.WithLabelValues(rpaasInstanceData.Service, rpaasInstanceData.Instance).Set(float64(len(fullZones)))
+	syncIntervalGaugeVec.WithLabelValues(rpaasInstanceData.Service, rpaasInstanceData.Instance).Set(interval.Seconds())
 
 	return worker
 }
 
-func (w *RpaasInstanceSyncWorker) Work() {
+// Work synchronizes the zones on every tick until the worker is stopped.
+// A round in progress is finished before stopping. The worker was removed
+// when ctx is done but parent isn't, rather than the controller stopping.
+func (w *RpaasInstanceSyncWorker) Work(ctx, parent context.Context) {
+	configChanged := config.Changed()
+	// The interval may have changed since the ticker was created
+	w.resetInterval()
 	for {
 		select {
 		case <-w.Ticker.C:
-			w.processTick()
-		case <-w.RpaasInstanceSignals.StopChan:
-			// Decrement active worker count
-			activeWorkersGaugeVec.WithLabelValues(w.Service, w.Instance, "instance").Dec()
-			w.cleanup()
+			stats := w.processTick()
+			w.adaptInterval(stats)
+		case <-configChanged:
+			configChanged = config.Changed()
+			w.resetInterval()
+		case <-w.settingsChanged:
+			w.resetInterval()
+		case <-ctx.Done():
+			w.shutdown(parent.Err() == nil)
 			return
 		}
 	}
 }
 
-func (w *RpaasInstanceSyncWorker) processTick() {
-	rpaasZoneData := ratelimit.RpaasZoneData{
-		RpaasName: w.Instance,
-		Data:      []ratelimit.Zone{},
+// resetInterval goes back to the configured interval when it changes or the adaptive interval is turned off.
+func (w *RpaasInstanceSyncWorker) resetInterval() {
+	interval := w.Settings().interval()
+	adapting := w.adaptive.current != 0
+	if interval == w.interval && (!adapting || config.Get().AdaptiveIntervalEnabled) {
+		return
 	}
-	for zone := range w.fullZones {
-		w.Lock()
-		podWorkers := w.PodWorkerManager.ListWorkerIDs()
-		workerCount := len(podWorkers)
+	if interval != w.interval {
+		w.logger.Info("Sync interval changed", "previous", w.interval, "interval", interval)
+	}
+	w.interval = interval
+	w.adaptive = adaptiveInterval{}
+	w.Ticker.Reset(interval)
+	w.reportInterval(interval)
+}
 
-		if workerCount == 0 {
-			w.Unlock()
-			continue
-		}
+func (w *RpaasInstanceSyncWorker) adaptInterval(stats roundStats) {
+	cfg := config.Get()
+	if !cfg.AdaptiveIntervalEnabled {
+		return
+	}
+	interval, wait := w.adaptive.next(w.interval, stats, cfg)
+	w.reportInterval(interval)
+	w.Ticker.Stop()
+	// Drop the tick that fired while the round was running instead of starting another one right away
+	select {
+	case <-w.Ticker.C:
+	default:
+	}
+	w.Ticker.Reset(wait)
+}
 
-		// Process each zone for all pod workers
-		w.PodWorkerManager.ForEachWorker(func(worker Worker) {
-			if podWorker, ok := worker.(*RpaasPodWorker); ok {
-				podWorker.ReadZoneChan <- zone
-			}
-		})
+func (w *RpaasInstanceSyncWorker) reportInterval(interval time.Duration) {
+	w.status.setInterval(interval)
+	syncIntervalGaugeVec.WithLabelValues(w.Service, w.Instance).Set(interval.Seconds())
+}
 
-		// Collect zone data from all pod workers
-		zoneData := []ratelimit.Zone{}
-		operationStart := time.Now()
-		for range workerCount {
-			result := <-w.zoneDataChan
-			if result.Error != nil {
-				w.logger.Error("Error getting zone data", "error", result.Error)
-				aggregationFailuresCounterVec.WithLabelValues(w.Service, w.Instance, zone, "collection_error").Inc()
-				continue
-			}
-			zoneData = append(zoneData, result.Value)
-		}
-		w.logger.Debug("Collected zone data", "zone", zone, "entries", zoneData)
-		operationDuration := time.Since(operationStart)
-		if operationDuration > config.Spec.WarnZoneCollectionTime {
-			w.logger.Warn("Zone data collection took too long", "durationMilliseconds", operationDuration.Milliseconds(), "zone", zone)
-		}
+// Settings returns the overrides of the global configuration for this instance.
+func (w *RpaasInstanceSyncWorker) Settings() InstanceSettings {
+	w.Lock()
+	defer w.Unlock()
+	return w.settings
+}
 
-		if len(zoneData) == 0 {
-			w.Unlock()
-			continue
-		}
+// SetSettings applies new settings from the next tick on. A non nil
+// aggregator replaces the current one, dropping the aggregated data.
+func (w *RpaasInstanceSyncWorker) SetSettings(settings InstanceSettings, aggregator ZoneAggregator) {
+	w.Lock()
+	w.settings = settings
+	w.status.setSettings(settings)
+	if aggregator != nil {
+		w.aggregator = aggregator
+		w.resetFullZones = true
+	}
+	w.Unlock()
+	select {
+	case w.settingsChanged <- struct{}{}:
+	default:
+	}
+}
 
-		// Aggregate zone data
-		operationStart = time.Now()
-		aggregatedZone, newFullZone := w.aggregator.AggregateZones(zoneData, w.fullZones[zone])
-		operationDuration = time.Since(operationStart)
-		aggregateLatencyHistogramVec.WithLabelValues(w.Service, w.Instance, zone).Observe(operationDuration.Seconds())
-		if operationDuration > config.Spec.WarnZoneAggregationTime {
-			w.logger.Warn("Zone data aggregation took too long", "duration", operationDuration, "zone", zone, "entries", len(aggregatedZone.RateLimitEntries))
+// UpdateZones adds the zones discovered on a pod and applies the zone
+// filter. Zones are only dropped when filtered out, as pods being rolled out
+// may report different zones. Zones no longer synchronized lose their
+// aggregated data, new ones start empty.
+func (w *RpaasInstanceSyncWorker) UpdateZones(discovered []string, filter ZoneFilter) {
+	w.Lock()
+	defer w.Unlock()
+	previous, _ := w.status.zones()
+	discovered = sortedZones(append(previous, discovered...))
+	synchronized := filter.Filter(discovered)
+	keep := make(map[string]struct{}, len(synchronized))
+	for _, zone := range synchronized {
+		keep[zone] = struct{}{}
+		if _, exists := w.fullZones[zone]; !exists {
+			w.logger.Info("Zone added", "zone", zone)
+			w.fullZones[zone] = make(map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry)
 		}
-		if config.Spec.FeatureFlagPersistAggregatedData {
-			w.fullZones[zone] = newFullZone
+	}
+	for zone := range w.fullZones {
+		if _, ok := keep[zone]; !ok {
+			w.logger.Info("Zone removed", "zone", zone)
+			delete(w.fullZones, zone)
 		}
-		w.Unlock()
+	}
+	w.status.setZones(discovered, synchronized)
+	synchronizedZonesGaugeVec.WithLabelValues(w.Service, w.Instance).Set(float64(len(w.fullZones)))
+}
 
-		if operationDuration > config.Spec.WarnZoneAggregationTime {
-			w.logger.Warn("Zone data aggregation took too long", "durationMilliseconds", operationDuration.Milliseconds(), "zone", zone, "entries", len(aggregatedZone.RateLimitEntries))
+// Zones returns the zones discovered on the pods and the ones synchronized across them.
+func (w *RpaasInstanceSyncWorker) Zones() ([]string, []string) {
+	return w.status.zones()
+}
+
+func sortedZones(zones []string) []string {
+	sorted := slices.Clone(zones)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+func (w *RpaasInstanceSyncWorker) processTick() roundStats {
+	roundStart := time.Now()
+	var roundExcess int64
+	cfg := config.Get()
+	w.Lock()
+	settings := w.settings
+	if w.resetFullZones {
+		for zone := range w.fullZones {
+			w.fullZones[zone] = make(map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry)
+		}
+		w.resetFullZones = false
+	}
+	zones := make([]string, 0, len(w.fullZones))
+	for zone := range w.fullZones {
+		zones = append(zones, zone)
+	}
+	w.Unlock()
+	w.startRecordingRound()
+	persistAggregatedData := settings.persistAggregatedData(cfg)
+	rpaasZoneData := ratelimit.RpaasZoneData{
+		RpaasName:       w.Instance,
+		Data:            []ratelimit.Zone{},
+		MaxTopOffenders: settings.MaxTopOffenders,
+	}
+	zoneEntries := make(map[string]int, len(zones))
+	for _, zone := range zones {
+		aggregatedZone, overriddenZone, resetPods, ok := w.aggregateZone(zone, cfg, persistAggregatedData)
+		if !ok {
+			continue
 		}
+		zoneEntries[zone] = len(aggregatedZone.RateLimitEntries)
 		w.logger.Debug("Aggregated zone data", "zone", zone, "entries", aggregatedZone.RateLimitEntries)
 
 		rpaasZoneData.Data = append(rpaasZoneData.Data, aggregatedZone)
+		for _, entry := range aggregatedZone.RateLimitEntries {
+			roundExcess += entry.Excess
+		}
 
 		// Record rate limit entries metrics
 		rateLimitEntriesCounterVec.WithLabelValues(w.Service, w.Instance, zone, "aggregated").Add(float64(len(aggregatedZone.RateLimitEntries)))
 
-		if config.Spec.FeatureFlagPersistAggregatedData {
+		if persistAggregatedData {
 			// Write aggregated data back to pod workers
-			w.PodWorkerManager.ForEachWorker(func(worker Worker) {
-				if podWorker, ok := worker.(*RpaasPodWorker); ok {
-					podWorker.WriteZoneChan <- aggregatedZone
-				}
-			})
+			w.writeZone(aggregatedZone)
+		} else {
+			// Reset pods start from the aggregated counters instead of zero
+			if len(resetPods) > 0 {
+				w.writePodsZone(resetPods, aggregatedZone)
+			}
+			// Overrides are enforced even when aggregated data is not persisted
+			if len(overriddenZone.RateLimitEntries) > 0 {
+				w.writeZone(overriddenZone)
+			}
 		}
 	}
 	w.notify <- rpaasZoneData
+	stats := roundStats{duration: time.Since(roundStart), excess: roundExcess}
+	w.status.roundDone(roundStart, stats.duration, zoneEntries)
+	return stats
 }
 
-func (w *RpaasInstanceSyncWorker) cleanup() {
-	// Stop all pod workers
+// aggregateZone reads a zone from every pod worker and aggregates it, also
+// returning the pods left out because they were reset. The lock is released
+// by a defer so a panic doesn't leave it held for the restarted worker.
+// aggregateZone reads a zone from every pod and aggregates it. The worker lock
+// is only taken to get and swap the aggregated state, so the settings and
+// zones of the instance can be read while a slow pod is being waited on.
+func (w *RpaasInstanceSyncWorker) aggregateZone(zone string, cfg config.Specification, persistAggregatedData bool) (ratelimit.Zone, ratelimit.Zone, []string, bool) {
+	if len(w.PodWorkerManager.ListWorkerIDs()) == 0 {
+		return ratelimit.Zone{}, ratelimit.Zone{}, nil, false
+	}
+
+	// Process each zone for all pod workers
+	requested := 0
 	w.PodWorkerManager.ForEachWorker(func(worker Worker) {
-		worker.Stop()
+		if podWorker, ok := worker.(*RpaasPodWorker); ok {
+			select {
+			case podWorker.ReadZoneChan <- zone:
+				requested++
+			case <-podWorker.Done():
+			}
+		}
 	})
 
-	// Close all channels
-	close(w.RpaasInstanceSignals.StopChan)
-	close(w.zoneDataChan)
-	w.Ticker.Stop()
+	// Collect zone data from all pod workers
+	zoneData := []ratelimit.Zone{}
+	var resetPods []string
+	operationStart := time.Now()
+	for range requested {
+		result := <-w.zoneDataChan
+		if errors.Is(result.Error, errPodReset) {
+			w.logger.Info("Skipping zone data of reset pod", "zone", zone, "error", result.Error)
+			var resetErr podResetError
+			if errors.As(result.Error, &resetErr) {
+				resetPods = append(resetPods, resetErr.pod)
+			}
+			continue
+		}
+		if result.Error != nil {
+			w.logger.Error("Error getting zone data", "error", result.Error)
+			aggregationFailuresCounterVec.WithLabelValues(w.Service, w.Instance, zone, "collection_error").Inc()
+			continue
+		}
+		zoneData = append(zoneData, result.Value)
+	}
+	w.logger.Debug("Collected zone data", "zone", zone, "entries", zoneData)
+	operationDuration := time.Since(operationStart)
+	if operationDuration > cfg.WarnZoneCollectionTime {
+		w.logger.Warn("Zone data collection took too long", "durationMilliseconds", operationDuration.Milliseconds(), "zone", zone)
+	}
+
+	if len(zoneData) == 0 {
+		return ratelimit.Zone{}, ratelimit.Zone{}, nil, false
+	}
+
+	allowlist, allowlistMaxExcess := w.allowlist()
+	if !allowlist.Empty() && allowlistMaxExcess <= 0 {
+		skipped := skipAllowlisted(zoneData, allowlist)
+		rateLimitEntriesCounterVec.WithLabelValues(w.Service, w.Instance, zone, "allowlisted").Add(float64(skipped))
+	}
+
+	// Aggregate zone data, the aggregators don't change the full zone they get
+	w.Lock()
+	zoneAggregator := w.aggregator
+	fullZone, synchronized := w.fullZones[zone]
+	w.Unlock()
+	operationStart = time.Now()
+	aggregatedZone, newFullZone := zoneAggregator.AggregateZones(zoneData, fullZone)
+	operationDuration = time.Since(operationStart)
+	w.recordAggregated(aggregatedZone, synchronized && persistAggregatedData)
+	aggregateLatencyHistogramVec.WithLabelValues(w.Service, w.Instance, zone).Observe(operationDuration.Seconds())
+	if operationDuration > cfg.WarnZoneAggregationTime {
+		w.logger.Warn("Zone data aggregation took too long", "durationMilliseconds", operationDuration.Milliseconds(), "zone", zone, "entries", len(aggregatedZone.RateLimitEntries))
+	}
+	if !allowlist.Empty() && allowlistMaxExcess > 0 {
+		capped := capAllowlisted(&aggregatedZone, newFullZone, allowlist, allowlistMaxExcess)
+		rateLimitEntriesCounterVec.WithLabelValues(w.Service, w.Instance, zone, "allowlisted").Add(float64(capped))
+	}
+	overriddenZone := w.applyOverrides(&aggregatedZone, newFullZone)
+	if synchronized && persistAggregatedData {
+		w.Lock()
+		// The zone may have been filtered out or its data dropped meanwhile
+		if _, exists := w.fullZones[zone]; exists && !w.resetFullZones {
+			w.fullZones[zone] = newFullZone
+		}
+		w.Unlock()
+	}
+	return aggregatedZone, overriddenZone, resetPods, true
 }
 
-func (w *RpaasInstanceSyncWorker) Start() {
-	go w.Work()
+func (w *RpaasInstanceSyncWorker) allowlist() (ratelimit.Allowlist, int64) {
+	if w.allowlists == nil {
+		return ratelimit.Allowlist{}, 0
+	}
+	return w.allowlists.Matcher(w.Instance)
 }
 
-func (w *RpaasInstanceSyncWorker) Stop() {
-	if w.RpaasInstanceSignals.StopChan != nil {
-		w.RpaasInstanceSignals.StopChan <- struct{}{}
+func (w *RpaasInstanceSyncWorker) applyOverrides(aggregatedZone *ratelimit.Zone, fullZone map[ratelimit.FullZoneKey]*ratelimit.RateLimitEntry) ratelimit.Zone {
+	if w.overrides == nil {
+		return ratelimit.Zone{}
+	}
+	now := time.Now()
+	overrides := w.overrides.Active(w.Instance, aggregatedZone.Name, now)
+	if len(overrides) == 0 {
+		return ratelimit.Zone{}
 	}
+	overriddenZone, err := applyOverrides(aggregatedZone, fullZone, overrides, now)
+	if err != nil {
+		w.logger.Warn("Error applying overrides", "zone", aggregatedZone.Name, "error", err)
+		aggregationFailuresCounterVec.WithLabelValues(w.Service, w.Instance, aggregatedZone.Name, "override_error").Inc()
+	}
+	rateLimitEntriesCounterVec.WithLabelValues(w.Service, w.Instance, aggregatedZone.Name, "overridden").Add(float64(len(overriddenZone.RateLimitEntries)))
+	return overriddenZone
+}
+
+func (w *RpaasInstanceSyncWorker) writeZone(zone ratelimit.Zone) {
+	w.PodWorkerManager.ForEachWorker(func(worker Worker) {
+		if podWorker, ok := worker.(*RpaasPodWorker); ok {
+			select {
+			case podWorker.WriteZoneChan <- zone:
+			case <-podWorker.Done():
+			}
+		}
+	})
+}
+
+// writePodsZone writes a zone to the given pods only.
+func (w *RpaasInstanceSyncWorker) writePodsZone(pods []string, zone ratelimit.Zone) {
+	w.PodWorkerManager.ForEachWorker(func(worker Worker) {
+		if podWorker, ok := worker.(*RpaasPodWorker); ok && slices.Contains(pods, podWorker.Name) {
+			select {
+			case podWorker.WriteZoneChan <- zone:
+			case <-podWorker.Done():
+			}
+		}
+	})
+}
+
+// shutdown optionally runs a last round, so the pods keep the latest
+// aggregated counters, and then stops the pod workers.
+func (w *RpaasInstanceSyncWorker) shutdown(removed bool) {
+	w.Ticker.Stop()
+	if config.Get().ShutdownWriteBack && w.CountWorkers() > 0 {
+		w.logger.Info("Running final round before stopping")
+		w.processTick()
+		// Stopping the pod workers cancels their requests, the writes of the final round must be done first
+		w.PodWorkerManager.ForEachWorker(func(worker Worker) {
+			if podWorker, ok := worker.(*RpaasPodWorker); ok {
+				podWorker.flushWrites()
+			}
+		})
+	}
+	if err := w.PodWorkerManager.Shutdown(context.Background()); err != nil {
+		w.logger.Error("Error stopping pod workers", "error", err)
+	}
+	activeWorkersGaugeVec.WithLabelValues(w.Service, w.Instance, "instance").Dec()
+	activeWorkersGaugeVec.DeleteLabelValues(w.Service, w.Instance, "pod")
+	synchronizedZonesGaugeVec.DeleteLabelValues(w.Service, w.Instance)
+	syncIntervalGaugeVec.DeleteLabelValues(w.Service, w.Instance)
+	if removed {
+		// Sent after the last round, so its data doesn't outlive the removal
+		w.notify <- ratelimit.RpaasZoneData{RpaasName: w.Instance, Removed: true}
+	}
+}
+
+func (w *RpaasInstanceSyncWorker) Start(ctx context.Context) {
+	parent := ctx
+	ctx = w.start(parent)
+	go func() {
+		defer w.finish()
+		supervise(ctx, w.logger, supervisedWorker{service: w.Service, instance: w.Instance, workerType: "instance"}, func(ctx context.Context) {
+			w.Work(ctx, parent)
+		})
+	}()
 }
 
 func (w *RpaasInstanceSyncWorker) GetID() string {
@@ -199,6 +452,7 @@ func (w *RpaasInstanceSyncWorker) AddPodWorker(podIP, podName string) {
 	}
 	podWorker := NewRpaasPodWorker(rpaasPodData, w.RpaasInstanceData, w.logger, w.zoneDataChan)
 	if !w.PodWorkerManager.AddWorker(podWorker) {
+		podWorker.releaseClient()
 		w.logger.Info("Worker already exists - not adding", "podName", podName, "Service", w.Service, "Instance", w.Instance)
 		return
 	}
@@ -207,6 +461,17 @@ func (w *RpaasInstanceSyncWorker) AddPodWorker(podIP, podName string) {
 	w.logger.Info("Added pod worker", "podName", podName, "Service", w.Service, "Instance", w.Instance)
 }
 
+// ObservePodRestarts passes the container restart count of a pod to its worker.
+func (w *RpaasInstanceSyncWorker) ObservePodRestarts(podName string, restarts int32) {
+	worker, exists := w.PodWorkerManager.GetWorker(podName)
+	if !exists {
+		return
+	}
+	if podWorker, ok := worker.(*RpaasPodWorker); ok {
+		podWorker.ObserveRestarts(restarts)
+	}
+}
+
 func (w *RpaasInstanceSyncWorker) RemovePodWorker(podName string) error {
 	if ok := w.PodWorkerManager.RemoveWorker(podName); !ok {
 		return fmt.Errorf("pod worker not found: %s", podName)
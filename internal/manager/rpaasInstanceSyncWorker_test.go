@@ -0,0 +1,215 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/aggregator"
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+	"github.com/tsuru/rate-limit-control-plane/test"
+)
+
+func TestRpaasInstanceSyncWorkerShutdown(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+	repository := test.NewRepository()
+	go test.NewServerMock(listener, repository)
+	setRepositoryData(repository, "one", []*test.Body{{Key: []byte("192.168.0.1"), Last: time.Now().UTC().UnixMilli(), Excess: 500}})
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	notify := make(chan ratelimit.RpaasZoneData, 10)
+	rpaasInstanceData := RpaasInstanceData{Instance: instanceName, Service: serviceName}
+	worker := NewRpaasInstanceSyncWorker(rpaasInstanceData, []string{"one"}, logger, notify, &aggregator.CompleteAggregator{}, nil, nil)
+	worker.SetSettings(InstanceSettings{Interval: 10 * time.Millisecond}, nil)
+	podWorker := NewRpaasPodWorker(RpaasPodData{Name: "pod", URL: fmt.Sprintf("http://localhost:%s", port)}, rpaasInstanceData, logger, worker.zoneDataChan)
+	require.True(t, worker.PodWorkerManager.AddWorker(podWorker))
+
+	workers := NewGoroutineManager()
+	require.True(t, workers.AddWorker(worker))
+	zoneData := <-notify
+	require.Len(t, zoneData.Data, 1)
+	assert.Len(t, zoneData.Data[0].RateLimitEntries, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, workers.Shutdown(ctx))
+	select {
+	case <-podWorker.Done():
+	default:
+		t.Fatal("pod worker still running after shutdown")
+	}
+	// The instance wasn't removed, the controller is stopping
+	for len(notify) > 0 {
+		assert.False(t, (<-notify).Removed)
+	}
+}
+
+func TestRpaasInstanceSyncWorkerRemoved(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	notify := make(chan ratelimit.RpaasZoneData)
+	worker := NewRpaasInstanceSyncWorker(RpaasInstanceData{Instance: instanceName, Service: serviceName}, []string{"one"}, logger, notify, &aggregator.CompleteAggregator{}, nil, nil)
+	workers := NewGoroutineManager()
+	require.True(t, workers.AddWorker(worker))
+	require.True(t, workers.RemoveWorker(instanceName))
+
+	select {
+	case zoneData := <-notify:
+		assert.Equal(t, ratelimit.RpaasZoneData{RpaasName: instanceName, Removed: true}, zoneData)
+	case <-time.After(5 * time.Second):
+		t.Fatal("removal not notified")
+	}
+	<-worker.Done()
+}
+
+func TestRpaasInstanceSyncWorkerStatus(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+	repository := test.NewRepository()
+	go test.NewServerMock(listener, repository)
+	setRepositoryData(repository, "one", []*test.Body{{Key: []byte("192.168.0.1"), Last: time.Now().UTC().UnixMilli(), Excess: 500}})
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	closed, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	_, closedPort, err := net.SplitHostPort(closed.Addr().String())
+	require.NoError(t, err)
+	closed.Close()
+
+	notify := make(chan ratelimit.RpaasZoneData, 1)
+	rpaasInstanceData := RpaasInstanceData{Instance: instanceName, Service: serviceName}
+	worker := NewRpaasInstanceSyncWorker(rpaasInstanceData, []string{"one", "two"}, logger, notify, &aggregator.CompleteAggregator{}, nil, nil)
+	defer worker.Ticker.Stop()
+	defer worker.PodWorkerManager.Shutdown(context.Background())
+	worker.PodWorkerManager.AddWorker(NewRpaasPodWorker(RpaasPodData{Name: "pod-a", URL: fmt.Sprintf("http://localhost:%s", port)}, rpaasInstanceData, logger, worker.zoneDataChan))
+	worker.PodWorkerManager.AddWorker(NewRpaasPodWorker(RpaasPodData{Name: "pod-b", URL: fmt.Sprintf("http://localhost:%s", closedPort)}, rpaasInstanceData, logger, worker.zoneDataChan))
+
+	status := worker.Status()
+	assert.True(t, status.LastRound.IsZero())
+	assert.Equal(t, PodStateUnknown, status.Pods[0].State)
+
+	worker.processTick()
+	<-notify
+	status = worker.Status()
+	assert.False(t, status.LastRound.IsZero())
+	assert.Equal(t, worker.interval.String(), status.Interval)
+	assert.Equal(t, []ZoneStatus{{Name: "one", Entries: 1}, {Name: "two", Entries: 0}}, status.Zones)
+	require.Len(t, status.Pods, 2)
+	assert.Equal(t, "pod-a", status.Pods[0].Name)
+	assert.Equal(t, PodStateHealthy, status.Pods[0].State)
+	assert.Equal(t, PodStateFailing, status.Pods[1].State)
+	assert.Equal(t, 2, status.Pods[1].ConsecutiveFailures)
+	assert.NotEmpty(t, status.Pods[1].LastError)
+}
+
+func TestRpaasInstanceSyncWorkerSeedsResetPod(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	newServer := func() (*test.Repositories, string) {
+		listener, err := net.Listen("tcp", ":0")
+		require.NoError(t, err)
+		t.Cleanup(func() { listener.Close() })
+		repository := test.NewRepository()
+		go test.NewServerMock(listener, repository)
+		_, port, err := net.SplitHostPort(listener.Addr().String())
+		require.NoError(t, err)
+		return repository, fmt.Sprintf("http://localhost:%s", port)
+	}
+	repositoryA, urlA := newServer()
+	repositoryB, urlB := newServer()
+	now := time.Now().UTC().UnixMilli()
+	setRepositoryData(repositoryA, "one", []*test.Body{{Key: []byte("192.168.0.1"), Last: now, Excess: 500}})
+	// The restarted pod counts from zero again
+	setRepositoryData(repositoryB, "one", []*test.Body{{Key: []byte("192.168.0.1"), Last: now, Excess: 30}})
+
+	notify := make(chan ratelimit.RpaasZoneData, 1)
+	rpaasInstanceData := RpaasInstanceData{Instance: instanceName, Service: serviceName}
+	worker := NewRpaasInstanceSyncWorker(rpaasInstanceData, []string{"one"}, logger, notify, &aggregator.CompleteAggregator{}, nil, nil)
+	defer worker.Ticker.Stop()
+	defer worker.PodWorkerManager.Shutdown(context.Background())
+	worker.PodWorkerManager.AddWorker(NewRpaasPodWorker(RpaasPodData{Name: "pod-a", URL: urlA}, rpaasInstanceData, logger, worker.zoneDataChan))
+	podB := NewRpaasPodWorker(RpaasPodData{Name: "pod-b", URL: urlB}, rpaasInstanceData, logger, worker.zoneDataChan)
+	worker.PodWorkerManager.AddWorker(podB)
+	podB.ObserveRestarts(0)
+	podB.ObserveRestarts(1)
+
+	// Aggregated data isn't persisted, only the reset pod is written to
+	worker.processTick()
+	zoneData := <-notify
+	require.Len(t, zoneData.Data, 1)
+	require.Len(t, zoneData.Data[0].RateLimitEntries, 1)
+	assert.Equal(t, int64(500), zoneData.Data[0].RateLimitEntries[0].Excess)
+	podB.flushWrites()
+
+	repositoryB.Mutex.Lock()
+	require.Len(t, repositoryB.Values["one"].Body, 1)
+	assert.Equal(t, int64(500), repositoryB.Values["one"].Body[0].Excess)
+	repositoryB.Mutex.Unlock()
+	repositoryA.Mutex.Lock()
+	require.Len(t, repositoryA.Values["one"].Body, 1)
+	assert.Equal(t, int64(500), repositoryA.Values["one"].Body[0].Excess)
+	repositoryA.Mutex.Unlock()
+}
+
+func TestRpaasInstanceSyncWorkerSlowPod(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	reading := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			reading <- struct{}{}
+			<-release
+		}
+	}))
+	defer server.Close()
+	releasePod := sync.OnceFunc(func() { close(release) })
+	defer releasePod()
+
+	notify := make(chan ratelimit.RpaasZoneData, 1)
+	rpaasInstanceData := RpaasInstanceData{Instance: instanceName, Service: serviceName}
+	worker := NewRpaasInstanceSyncWorker(rpaasInstanceData, []string{"one"}, logger, notify, &aggregator.CompleteAggregator{}, nil, nil)
+	defer worker.Ticker.Stop()
+	defer worker.PodWorkerManager.Shutdown(context.Background())
+	worker.PodWorkerManager.AddWorker(NewRpaasPodWorker(RpaasPodData{Name: "pod", URL: server.URL}, rpaasInstanceData, logger, worker.zoneDataChan))
+
+	go worker.processTick()
+	<-reading
+	// The settings and zones don't wait for the pod
+	done := make(chan struct{})
+	go func() {
+		worker.Settings()
+		filter, err := NewZoneFilter(nil, nil)
+		assert.NoError(t, err)
+		worker.UpdateZones([]string{"one", "two"}, filter)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("instance worker locked while reading a pod")
+	}
+	_, synchronized := worker.Zones()
+	assert.Equal(t, []string{"one", "two"}, synchronized)
+
+	releasePod()
+	select {
+	case zoneData := <-notify:
+		assert.Len(t, zoneData.Data, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("round not finished")
+	}
+}
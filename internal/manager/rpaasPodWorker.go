@@ -1,11 +1,13 @@
 package manager
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/vmihailenco/msgpack/v5"
@@ -20,107 +22,165 @@ type RpaasPodData struct {
 }
 
 type RpaasPodWorker struct {
+	*lifecycle
 	RpaasPodData
 	RpaasInstanceData
-	logger                   *slog.Logger
-	zoneDataChan             chan Optional[ratelimit.Zone]
-	ReadZoneChan             chan string
-	WriteZoneChan            chan ratelimit.Zone
-	StopChan                 chan struct{}
-	roundSmallestLastPerZone map[string]int64
-	lastHeaderPerZone        map[string]ratelimit.RateLimitHeader
-	client                   *http.Client
+	logger        *slog.Logger
+	zoneDataChan  chan Optional[ratelimit.Zone]
+	ReadZoneChan  chan string
+	WriteZoneChan chan ratelimit.Zone
+	flushChan     chan chan struct{}
+	// stateMu guards the incremental read state, which is dropped when the pod resets
+	stateMu           sync.Mutex
+	readStatePerZone  map[string]zoneReadState
+	lastHeaderPerZone map[string]ratelimit.RateLimitHeader
+	entriesPerZone    map[string]zoneEntries
+	resetZones        map[string]bool
+	restarts          *int32
+	resets            int
+	client            *http.Client
+	releaseClient     func()
+	status            podStatus
+	clock             clockSkew
 }
 
 func NewRpaasPodWorker(rpaasPodData RpaasPodData, rpaasInstanceData RpaasInstanceData, logger *slog.Logger, zoneDataChan chan Optional[ratelimit.Zone]) *RpaasPodWorker {
 	podLogger := logger.With("podName", rpaasPodData.Name, "podURL", rpaasPodData.URL)
-	transport := &http.Transport{
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
-		DialContext: (&net.Dialer{
-			Timeout:   5 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSHandshakeTimeout: 10 * time.Second,
-	}
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   10 * time.Second,
-	}
+	client, releaseClient := AcquirePodClient()
 
 	worker := &RpaasPodWorker{
-		RpaasPodData:             rpaasPodData,
-		RpaasInstanceData:        rpaasInstanceData,
-		zoneDataChan:             zoneDataChan,
-		logger:                   podLogger,
-		ReadZoneChan:             make(chan string),
-		WriteZoneChan:            make(chan ratelimit.Zone),
-		StopChan:                 make(chan struct{}),
-		roundSmallestLastPerZone: make(map[string]int64),
-		lastHeaderPerZone:        make(map[string]ratelimit.RateLimitHeader),
-		client:                   client,
+		lifecycle:         newLifecycle(),
+		RpaasPodData:      rpaasPodData,
+		RpaasInstanceData: rpaasInstanceData,
+		zoneDataChan:      zoneDataChan,
+		logger:            podLogger,
+		ReadZoneChan:      make(chan string),
+		WriteZoneChan:     make(chan ratelimit.Zone),
+		flushChan:         make(chan chan struct{}),
+		readStatePerZone:  make(map[string]zoneReadState),
+		lastHeaderPerZone: make(map[string]ratelimit.RateLimitHeader),
+		entriesPerZone:    make(map[string]zoneEntries),
+		client:            client,
+		releaseClient:     releaseClient,
 	}
 
 	return worker
 }
 
-func (w *RpaasPodWorker) Start() {
-	go w.Work()
-}
-
-func (w *RpaasPodWorker) Stop() {
-	if w.StopChan != nil {
-		w.StopChan <- struct{}{}
-	}
+func (w *RpaasPodWorker) Start(ctx context.Context) {
+	parent := ctx
+	ctx = w.start(parent)
+	go func() {
+		defer w.finish()
+		defer w.releaseClient()
+		// The transport can't close the connections of a single host, so the
+		// other pods dial again once, rather than keeping the ones to a removed
+		// pod until IdleConnTimeout
+		defer w.client.CloseIdleConnections()
+		defer clockSkewGaugeVec.DeleteLabelValues(w.Service, w.Instance, w.Name)
+		supervise(ctx, w.logger, w.supervisedWorker(), func(ctx context.Context) {
+			w.Work(ctx, parent)
+		})
+	}()
 }
 
 func (w *RpaasPodWorker) GetID() string {
 	return w.Name
 }
 
-func (w *RpaasPodWorker) Work() {
+func (w *RpaasPodWorker) supervisedWorker() supervisedWorker {
+	return supervisedWorker{service: w.Service, instance: w.Instance, workerType: "pod", pod: w.Name}
+}
+
+// Work serves reads and writes until ctx is done, which cancels their
+// requests. Reads started before that are still delivered, as the instance
+// worker waits for them, unless parent is done too.
+func (w *RpaasPodWorker) Work(ctx, parent context.Context) {
 	for {
 		select {
 		case zoneName := <-w.ReadZoneChan:
 			go func() {
-				zoneData, err := w.getZoneData(zoneName)
-				if err != nil {
-					w.zoneDataChan <- Optional[ratelimit.Zone]{Value: zoneData, Error: fmt.Errorf("error getting zone data from pod worker %s: %w", w.Name, err)}
-				} else {
-					w.logger.Debug("Zone data retrieved", "zone", zoneName, "pod", w.Name, "entries", zoneData.RateLimitEntries)
-					w.zoneDataChan <- Optional[ratelimit.Zone]{Value: zoneData, Error: nil}
+				result := w.readZone(ctx, zoneName)
+				select {
+				case w.zoneDataChan <- result:
+				case <-parent.Done():
 				}
 			}()
 		case zone := <-w.WriteZoneChan:
-			err := w.sendRequest(zone)
+			err := w.sendRequest(ctx, zone)
+			w.status.record(err)
 			if err != nil {
 				w.logger.Error("Error writing zone data", "zone", zone.Name, "error", err)
 			}
-		case <-w.StopChan:
-			w.cleanup()
+		case done := <-w.flushChan:
+			// Writes are served in order, the previous ones are done
+			close(done)
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (w *RpaasPodWorker) cleanup() {
-	close(w.ReadZoneChan)
-	close(w.WriteZoneChan)
-	close(w.StopChan)
+// flushWrites waits for the writes handed to the worker so far, or for it to stop.
+func (w *RpaasPodWorker) flushWrites() {
+	done := make(chan struct{})
+	select {
+	case w.flushChan <- done:
+	case <-w.Done():
+		return
+	}
+	select {
+	case <-done:
+	case <-w.Done():
+	}
 }
 
-func (w *RpaasPodWorker) getZoneData(zone string) (ratelimit.Zone, error) {
+// readZone turns panics into errors, as the instance worker waits for a result of every read.
+func (w *RpaasPodWorker) readZone(ctx context.Context, zoneName string) (result Optional[ratelimit.Zone]) {
+	panicked := recovered(w.logger, w.supervisedWorker(), func() {
+		zoneData, err := w.getZoneData(ctx, zoneName)
+		if errors.Is(err, errPodReset) {
+			// The request itself went fine
+			w.status.record(nil)
+		} else {
+			w.status.record(err)
+		}
+		if err != nil {
+			result = Optional[ratelimit.Zone]{Value: zoneData, Error: fmt.Errorf("error getting zone data from pod worker %s: %w", w.Name, err)}
+			return
+		}
+		w.logger.Debug("Zone data retrieved", "zone", zoneName, "pod", w.Name, "entries", zoneData.RateLimitEntries)
+		result = Optional[ratelimit.Zone]{Value: zoneData, Error: nil}
+	})
+	if panicked {
+		result = Optional[ratelimit.Zone]{Error: fmt.Errorf("panic getting zone data from pod worker %s", w.Name)}
+		w.status.record(result.Error)
+	}
+	return result
+}
+
+func (w *RpaasPodWorker) getZoneData(ctx context.Context, zone string) (ratelimit.Zone, error) {
 	endpoint := fmt.Sprintf("%s/rate-limit/%s", w.URL, zone)
-	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return ratelimit.Zone{}, err
 	}
-	if w.roundSmallestLastPerZone[zone] != 0 {
+	cfg := config.Get()
+	w.stateMu.Lock()
+	readState := w.readStatePerZone[zone]
+	w.stateMu.Unlock()
+	readType := readState.readType(time.Now(), cfg.PodFullReadInterval)
+	if readType == ReadTypeIncremental {
 		query := req.URL.Query()
-		query.Set("last_greater_equal", fmt.Sprintf("%d", w.roundSmallestLastPerZone[zone]-1))
+		query.Set("last_greater_equal", fmt.Sprintf("%d", readState.watermark))
 		req.URL.RawQuery = query.Encode()
 	}
+	release, err := AcquirePodRequest(ctx, w.Instance, RequestOperationRead)
+	if err != nil {
+		return ratelimit.Zone{}, err
+	}
+	// Held until the response is decoded, as reading the body is most of the work
+	defer release()
 	start := time.Now()
 	response, err := w.client.Do(req)
 	if err != nil {
@@ -131,57 +191,105 @@ func (w *RpaasPodWorker) getZoneData(zone string) (ratelimit.Zone, error) {
 	reqDuration := time.Since(start)
 	readOperationsCounterVec.WithLabelValues(w.Service, w.Instance, zone, "success").Inc()
 	readLatencyHistogramVec.WithLabelValues(w.Service, w.Instance, zone).Observe(reqDuration.Seconds())
-	if reqDuration > config.Spec.WarnZoneReadTime {
+	if reqDuration > cfg.WarnZoneReadTime {
 		w.logger.Warn("Request took too long", "durationMilliseconds", reqDuration.Milliseconds(), "zone", zone, "contentLength", response.ContentLength)
 	}
 
 	defer response.Body.Close()
-	decoder := msgpack.NewDecoder(response.Body)
-	var rateLimitHeader ratelimit.RateLimitHeader
-	rateLimitEntries := []ratelimit.RateLimitEntry{}
-	if err := decoder.Decode(&rateLimitHeader); err != nil {
+	body, raw := w.recordingBody(response.Body)
+	rateLimitHeader, rateLimitEntries, err := decodeZone(body)
+	if err != nil {
 		if err == io.EOF {
+			w.recordRead(zone, readType, raw, 0, nil)
+			w.stateMu.Lock()
 			w.lastHeaderPerZone[zone] = rateLimitHeader
+			// The zone has no entries left
+			delete(w.entriesPerZone, zone)
+			w.stateMu.Unlock()
 			return ratelimit.Zone{
 				Name:             zone,
 				RateLimitHeader:  rateLimitHeader,
 				RateLimitEntries: rateLimitEntries,
 			}, nil
 		}
-		w.logger.Error("Error decoding header", "error", err)
+		w.recordRead(zone, readType, raw, 0, err)
+		w.logger.Error("Error decoding zone", "zone", zone, "error", err)
 		readOperationsCounterVec.WithLabelValues(w.Service, w.Instance, zone, "error").Inc()
 		return ratelimit.Zone{}, err
 	}
-	w.lastHeaderPerZone[zone] = rateLimitHeader
-	for {
-		var message ratelimit.RateLimitEntry
-		if err := decoder.Decode(&message); err != nil {
-			if err == io.EOF {
-				break
-			}
-			w.logger.Error("Error decoding entry", "error", err)
-			readOperationsCounterVec.WithLabelValues(w.Service, w.Instance, zone, "error").Inc()
-			return ratelimit.Zone{}, err
-		}
-		if w.roundSmallestLastPerZone[zone] == 0 {
-			w.roundSmallestLastPerZone[zone] = message.Last
-		} else {
-			w.roundSmallestLastPerZone[zone] = min(w.roundSmallestLastPerZone[zone], message.Last)
-		}
-		message.NonMonotic(rateLimitHeader)
-		rateLimitEntries = append(rateLimitEntries, message)
+	if w.checkReset(zone, rateLimitHeader) {
+		w.recordRead(zone, readType, raw, 0, errPodReset)
+		return ratelimit.Zone{}, podResetError{pod: w.Name}
+	}
+	// Client.Do returns once the headers arrive, so the body transfer doesn't widen the window
+	w.observeClock(rateLimitHeader, start, start.Add(reqDuration), cfg)
+	correction := w.clock.correction(cfg)
+	w.recordRead(zone, readType, raw, correction, nil)
+	for i := range rateLimitEntries {
+		rateLimitEntries[i].NonMonotic(rateLimitHeader)
+		rateLimitEntries[i].Last -= correction
 	}
 
+	readEntriesHistogramVec.WithLabelValues(w.Service, w.Instance, zone, readType).Observe(float64(len(rateLimitEntries)))
+	w.stateMu.Lock()
+	// A reset while reading drops the state, the entries of the pod before it don't count
+	if _, exists := w.lastHeaderPerZone[zone]; exists {
+		w.readStatePerZone[zone] = readState.advance(rateLimitHeader, readType, start)
+		w.entriesPerZone[zone], rateLimitEntries = mergeEntries(w.entriesPerZone[zone], rateLimitEntries, readType, rateLimitHeader.Now-correction)
+	}
+	w.stateMu.Unlock()
+
+	// Aggregators take the header of any pod, so it is moved to the controller timebase too
+	zoneHeader := rateLimitHeader
+	zoneHeader.Now -= correction
 	return ratelimit.Zone{
 		Name:             zone,
-		RateLimitHeader:  rateLimitHeader,
+		RateLimitHeader:  zoneHeader,
 		RateLimitEntries: rateLimitEntries,
 	}, nil
 }
 
-func min(a, b int64) int64 {
-	if a < b {
-		return a
+// decodeZone reads the header of a zone followed by its entries, with Last in
+// the pod monotonic clock. It returns io.EOF for an empty body, which nginx
+// sends for zones without entries, while a body cut in the middle of a value
+// is an io.ErrUnexpectedEOF.
+func decodeZone(body io.Reader) (ratelimit.RateLimitHeader, []ratelimit.RateLimitEntry, error) {
+	decoder := msgpack.NewDecoder(body)
+	var header ratelimit.RateLimitHeader
+	entries := []ratelimit.RateLimitEntry{}
+	if _, err := decoder.PeekCode(); err != nil {
+		if err == io.EOF {
+			return header, entries, err
+		}
+		return header, nil, fmt.Errorf("error decoding header: %w", err)
+	}
+	if err := decoder.Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("error decoding header: %w", unexpectedEOF(err))
+	}
+	for {
+		if _, err := decoder.PeekCode(); err == io.EOF {
+			return header, entries, nil
+		}
+		var entry ratelimit.RateLimitEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return header, nil, fmt.Errorf("error decoding entry %d: %w", len(entries), unexpectedEOF(err))
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// unexpectedEOF tells a value cut by the end of the body, which the decoder reports as io.EOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (w *RpaasPodWorker) observeClock(header ratelimit.RateLimitHeader, start, end time.Time, cfg config.Specification) {
+	offset, warn := w.clock.observe(header.Now, start, end, cfg.ClockSkewWarnThreshold)
+	clockSkewGaugeVec.WithLabelValues(w.Service, w.Instance, w.Name).Set(offset.Seconds())
+	if warn {
+		w.logger.Warn("Pod clock skew above threshold", "offsetMilliseconds", offset.Milliseconds(), "threshold", cfg.ClockSkewWarnThreshold)
 	}
-	return b
 }
@@ -1,14 +1,21 @@
 package manager
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 
 	"github.com/tsuru/rate-limit-control-plane/internal/aggregator"
 	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
@@ -78,9 +85,9 @@ func TestRpaasPodWorkerAggregationWithoutPreviousData(t *testing.T) {
 	}
 	podWorker2 := NewRpaasPodWorker(rpaasPodData2, rpaasInstanceData, slog.New(logHandler), zoneDataChan)
 
-	go podWorker1.Start()
+	podWorker1.Start(context.Background())
 	defer podWorker1.Stop()
-	go podWorker2.Start()
+	podWorker2.Start(context.Background())
 	defer podWorker2.Stop()
 
 	now := time.Now().UTC().UnixMilli()
@@ -209,9 +216,9 @@ func TestRpaasPodWorkerAggregationWithPreviousData(t *testing.T) {
 	}
 	podWorker2 := NewRpaasPodWorker(rpaasPodData2, rpaasInstanceData, slog.New(logHandler), zoneDataChan)
 
-	go podWorker1.Start()
+	podWorker1.Start(context.Background())
 	defer podWorker1.Stop()
-	go podWorker2.Start()
+	podWorker2.Start(context.Background())
 	defer podWorker2.Stop()
 
 	now := time.Now().UTC().UnixMilli()
@@ -333,3 +340,193 @@ func checkZoneDataAgainstRepoData(t *testing.T, zoneData ratelimit.Zone, repoDat
 		require.EqualValues(t, repoDataElement.Excess, zoneDataElement.Excess)
 	}
 }
+
+// encodeZone encodes a zone as nginx does, arrays for the header and each entry.
+func encodeZone(t testing.TB, header ratelimit.RateLimitHeader, entries []ratelimit.RateLimitEntry) []byte {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	require.NoError(t, encoder.Encode(headerToArray(header)))
+	for _, entry := range entries {
+		require.NoError(t, encoder.Encode(entryToArray(entry)))
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeZoneTruncated(t *testing.T) {
+	header := ratelimit.RateLimitHeader{Key: ratelimit.RemoteAddress, Now: 10, NowMonotonic: 5}
+	body := encodeZone(t, header, []ratelimit.RateLimitEntry{{Key: ratelimit.Key("10.0.0.1"), Last: 4, Excess: 10}})
+
+	_, _, err := decodeZone(bytes.NewReader(nil))
+	require.Equal(t, io.EOF, err)
+	_, _, err = decodeZone(bytes.NewReader(body[:2]))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, _, err = decodeZone(bytes.NewReader(body[:len(body)-1]))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, entries, err := decodeZone(bytes.NewReader(body))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func FuzzDecodeZone(f *testing.F) {
+	header := ratelimit.RateLimitHeader{Key: ratelimit.RemoteAddress, Now: 1_700_000_000_000, NowMonotonic: 86_400_000}
+	f.Add([]byte{})
+	f.Add(encodeZone(f, header, nil))
+	f.Add(encodeZone(f, header, []ratelimit.RateLimitEntry{
+		{Key: ratelimit.Key("10.0.0.1"), Last: 86_399_000, Excess: 1500},
+		{Key: ratelimit.Key("2001:db8::1"), Last: 86_000_000, Excess: 0},
+	}))
+	binaryHeader := ratelimit.RateLimitHeader{Key: ratelimit.BinaryRemoteAddress, Now: 1, NowMonotonic: 1}
+	f.Add(encodeZone(f, binaryHeader, []ratelimit.RateLimitEntry{{Key: ratelimit.Key{10, 0, 0, 1}, Last: -5, Excess: -1}}))
+	// The mock server encodes structs as maps
+	mapHeader, err := msgpack.Marshal(test.Header{Key: ratelimit.RemoteAddress, Now: 10, NowMonotonic: 5})
+	require.NoError(f, err)
+	mapEntry, err := msgpack.Marshal(test.Body{Key: []byte("10.0.0.1"), Last: 4, Excess: 10})
+	require.NoError(f, err)
+	f.Add(append(mapHeader, mapEntry...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, entries, err := decodeZone(bytes.NewReader(data))
+		if err == io.EOF {
+			require.Empty(t, data)
+			return
+		}
+		if err != nil {
+			return
+		}
+		// Whatever decodes survives a round trip through the nginx encoding
+		decodedHeader, decodedEntries, err := decodeZone(bytes.NewReader(encodeZone(t, header, entries)))
+		require.NoError(t, err)
+		require.Equal(t, header, decodedHeader)
+		require.Len(t, decodedEntries, len(entries))
+		for i := range entries {
+			require.True(t, bytes.Equal(entries[i].Key, decodedEntries[i].Key))
+			require.Equal(t, entries[i].Last, decodedEntries[i].Last)
+			require.Equal(t, entries[i].Excess, decodedEntries[i].Excess)
+		}
+	})
+}
+
+func TestRpaasPodWorkerCancelsRequests(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(unblock)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	rpaasInstanceData := RpaasInstanceData{Instance: instanceName, Service: serviceName}
+
+	// In flight requests
+	w := NewRpaasPodWorker(RpaasPodData{Name: "pod", URL: server.URL}, rpaasInstanceData, logger, nil)
+	defer w.releaseClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := w.getZoneData(ctx, "one")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	err = w.sendRequest(ctx, ratelimit.Zone{Name: "one"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Requests waiting for the scheduler
+	scheduler := defaultRequestScheduler
+	defaultRequestScheduler = NewRequestScheduler(1)
+	defer func() { defaultRequestScheduler = scheduler }()
+	release, err := AcquirePodRequest(context.Background(), "other", RequestOperationRead)
+	require.NoError(t, err)
+	defer release()
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = w.getZoneData(ctx, "one")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Stopping a worker cancels its reads, whose results are still delivered
+	zoneDataChan := make(chan Optional[ratelimit.Zone], 1)
+	defaultRequestScheduler = scheduler
+	stopped := NewRpaasPodWorker(RpaasPodData{Name: "pod", URL: server.URL}, rpaasInstanceData, logger, zoneDataChan)
+	stopped.Start(context.Background())
+	stopped.ReadZoneChan <- "one"
+	time.Sleep(20 * time.Millisecond)
+	stopped.Stop()
+	select {
+	case result := <-zoneDataChan:
+		require.ErrorIs(t, result.Error, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read not cancelled by stopping the worker")
+	}
+	<-stopped.Done()
+}
+
+func TestRpaasPodWorkerFlushWrites(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+	repository := test.NewRepository()
+	go test.NewServerMock(listener, repository)
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := NewRpaasPodWorker(RpaasPodData{Name: "pod", URL: fmt.Sprintf("http://localhost:%s", port)}, RpaasInstanceData{Instance: instanceName, Service: serviceName}, logger, nil)
+	w.Start(context.Background())
+	defer w.Stop()
+
+	now := time.Now().UnixMilli()
+	w.WriteZoneChan <- ratelimit.Zone{
+		Name:             "one",
+		RateLimitHeader:  ratelimit.RateLimitHeader{Key: ratelimit.RemoteAddress, Now: now, NowMonotonic: now},
+		RateLimitEntries: []ratelimit.RateLimitEntry{{Key: ratelimit.Key("10.0.0.1"), Last: now, Excess: 100}},
+	}
+	// Once flushed the write is done, so stopping the worker doesn't cancel it
+	w.flushWrites()
+	w.Stop()
+	repository.Mutex.Lock()
+	defer repository.Mutex.Unlock()
+	require.Len(t, repository.Values["one"].Body, 1)
+	require.Equal(t, int64(100), repository.Values["one"].Body[0].Excess)
+
+	// Flushing a stopped worker returns at once
+	<-w.Done()
+	w.flushWrites()
+}
+
+func TestRpaasPodWorkerReleasesConnections(t *testing.T) {
+	var mu sync.Mutex
+	states := map[http.ConnState]int{}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "zone not found", http.StatusNotFound)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		states[state]++
+	}
+	server.Start()
+	defer server.Close()
+	connections := func(state http.ConnState) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return states[state]
+	}
+
+	// Another pod keeps the transport in use
+	_, release := AcquirePodClient()
+	defer release()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := NewRpaasPodWorker(RpaasPodData{Name: "pod", URL: server.URL}, RpaasInstanceData{Instance: instanceName, Service: serviceName}, logger, nil)
+	w.Start(context.Background())
+	// Failed writes give their connection back to the shared transport
+	for i := 0; i < 3; i++ {
+		w.WriteZoneChan <- ratelimit.Zone{Name: "one"}
+	}
+	w.flushWrites()
+	require.Equal(t, 1, connections(http.StateNew))
+
+	// Idle connections are closed once the worker stops
+	w.Stop()
+	<-w.Done()
+	require.Eventually(t, func() bool {
+		return connections(http.StateClosed) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
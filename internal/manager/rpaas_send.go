@@ -2,16 +2,21 @@ package manager
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/vmihailenco/msgpack/v5"
 
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
 	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
 )
 
-func (w *RpaasPodWorker) sendRequest(zone ratelimit.Zone) error {
+func (w *RpaasPodWorker) sendRequest(ctx context.Context, zone ratelimit.Zone) error {
+	w.stateMu.Lock()
 	rateLimitHeader, ok := w.lastHeaderPerZone[zone.Name]
+	w.stateMu.Unlock()
 	if !ok {
 		rateLimitHeader = zone.RateLimitHeader
 	}
@@ -23,7 +28,10 @@ func (w *RpaasPodWorker) sendRequest(zone ratelimit.Zone) error {
 		headerToArray(rateLimitHeader),
 	}
 
+	// Entries are in the controller timebase, the pod clock may be off
+	correction := w.clock.correction(config.Get())
 	for _, entry := range zone.RateLimitEntries {
+		entry.Last += correction
 		entry.Monotonic(rateLimitHeader)
 		values = append(values, entryToArray(entry))
 	}
@@ -32,27 +40,41 @@ func (w *RpaasPodWorker) sendRequest(zone ratelimit.Zone) error {
 		return fmt.Errorf("error encoding entries: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, endpoint, &buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &buf)
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-msgpack")
 
+	release, err := AcquirePodRequest(ctx, w.Instance, RequestOperationWrite)
+	if err != nil {
+		return err
+	}
+	defer release()
 	resp, err := w.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("error sending request to %s: %w", endpoint, err)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code %d from [%s] %s", resp.StatusCode, req.Method, endpoint)
-	}
-
 	defer func() {
+		// Drained so the connection goes back to the transport shared by every pod
+		if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+			w.logger.Error("Error draining response body", "error", err)
+		}
 		if err := resp.Body.Close(); err != nil {
 			w.logger.Error("Error closing response body", "error", err)
 		}
 	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from [%s] %s", resp.StatusCode, req.Method, endpoint)
+	}
+	w.stateMu.Lock()
+	// Zones not read since the pod reset are read in full next time
+	if entries, ok := w.entriesPerZone[zone.Name]; ok {
+		entries.update(zone.RateLimitEntries)
+	}
+	w.stateMu.Unlock()
 	return nil
 }
 
@@ -0,0 +1,37 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+)
+
+// InstanceSettings override the global configuration for the sync worker of
+// one instance. Zero values keep the global configuration.
+type InstanceSettings struct {
+	Interval              time.Duration `json:"interval,omitempty"`
+	PersistAggregatedData *bool         `json:"persistAggregatedData,omitempty"`
+	MaxTopOffenders       int           `json:"maxTopOffenders,omitempty"`
+	Aggregator            string        `json:"aggregator,omitempty"`
+	IncludedZones         []string      `json:"includedZones,omitempty"`
+	ExcludedZones         []string      `json:"excludedZones,omitempty"`
+}
+
+func (s InstanceSettings) interval() time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	return config.Get().ControllerIntervalDuration
+}
+
+func (s InstanceSettings) persistAggregatedData(cfg config.Specification) bool {
+	if s.PersistAggregatedData != nil {
+		return *s.PersistAggregatedData
+	}
+	return cfg.FeatureFlagPersistAggregatedData
+}
+
+// ZoneFilter returns the zone filter of the instance, to be merged with the global one.
+func (s InstanceSettings) ZoneFilter() (ZoneFilter, error) {
+	return NewZoneFilter(s.IncludedZones, s.ExcludedZones)
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/aggregator"
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+func TestInstanceSettings(t *testing.T) {
+	cfg := config.Get()
+	persist := !cfg.FeatureFlagPersistAggregatedData
+
+	assert.Equal(t, cfg.ControllerIntervalDuration, InstanceSettings{}.interval())
+	assert.Equal(t, 5*time.Second, InstanceSettings{Interval: 5 * time.Second}.interval())
+	assert.Equal(t, cfg.FeatureFlagPersistAggregatedData, InstanceSettings{}.persistAggregatedData(cfg))
+	assert.Equal(t, persist, InstanceSettings{PersistAggregatedData: &persist}.persistAggregatedData(cfg))
+
+	filter, err := InstanceSettings{IncludedZones: []string{"t*"}, ExcludedZones: []string{"three"}}.ZoneFilter()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"two"}, filter.Filter([]string{"one", "two", "three"}))
+}
+
+func TestRpaasInstanceSyncWorkerSetSettings(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	notify := make(chan ratelimit.RpaasZoneData, 1)
+	worker := NewRpaasInstanceSyncWorker(RpaasInstanceData{Instance: instanceName, Service: serviceName}, []string{"one"}, logger, notify, &aggregator.CompleteAggregator{}, nil, nil)
+	defer worker.Ticker.Stop()
+	worker.fullZones["one"][ratelimit.FullZoneKey{Zone: "one", Key: "key"}] = &ratelimit.RateLimitEntry{Excess: 10}
+
+	settings := InstanceSettings{Interval: time.Hour, MaxTopOffenders: 5}
+	worker.SetSettings(settings, nil)
+	assert.Equal(t, settings, worker.Settings())
+	assert.Len(t, worker.settingsChanged, 1)
+
+	worker.resetInterval()
+	assert.Equal(t, time.Hour, worker.interval)
+
+	// Without pod workers a tick only reports the settings and resets the aggregated data
+	worker.SetSettings(settings, &aggregator.PrefixAggregator{Aggregator: &aggregator.CompleteAggregator{}, IPv4PrefixLength: 24, IPv6PrefixLength: 64})
+	worker.processTick()
+	zoneData := <-notify
+	assert.Equal(t, 5, zoneData.MaxTopOffenders)
+	assert.Empty(t, worker.fullZones["one"])
+	assert.IsType(t, &aggregator.PrefixAggregator{}, worker.aggregator)
+}
+
+func TestRpaasInstanceSyncWorkerSetZones(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	worker := NewRpaasInstanceSyncWorker(RpaasInstanceData{Instance: instanceName, Service: serviceName}, []string{"one", "two"}, logger, nil, &aggregator.CompleteAggregator{}, nil, nil)
+	defer worker.Ticker.Stop()
+	worker.fullZones["one"][ratelimit.FullZoneKey{Zone: "one", Key: "key"}] = &ratelimit.RateLimitEntry{Excess: 10}
+
+	filter, err := NewZoneFilter(nil, []string{"local", "two"})
+	require.NoError(t, err)
+	worker.UpdateZones([]string{"one", "three", "local"}, filter)
+	discovered, synchronized := worker.Zones()
+	assert.Equal(t, []string{"local", "one", "three", "two"}, discovered)
+	assert.Equal(t, []string{"one", "three"}, synchronized)
+	// Zones that are still synchronized keep their aggregated data
+	assert.Len(t, worker.fullZones["one"], 1)
+	assert.NotContains(t, worker.fullZones, "two")
+
+	// Zones missing on a pod are kept
+	worker.UpdateZones([]string{"one"}, ZoneFilter{})
+	_, synchronized = worker.Zones()
+	assert.Equal(t, []string{"local", "one", "three", "two"}, synchronized)
+}
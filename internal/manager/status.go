@@ -0,0 +1,204 @@
+package manager
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	PodStateUnknown = "unknown"
+	PodStateHealthy = "healthy"
+	PodStateFailing = "failing"
+)
+
+// InstanceWorkerStatus is a snapshot of an instance worker for debugging sync issues.
+type InstanceWorkerStatus struct {
+	Instance          string            `json:"instance"`
+	Service           string            `json:"service"`
+	Interval          string            `json:"interval"`
+	LastRound         time.Time         `json:"lastRound"`
+	LastRoundDuration string            `json:"lastRoundDuration"`
+	DiscoveredZones   []string          `json:"discoveredZones"`
+	Zones             []ZoneStatus      `json:"zones"`
+	Settings          InstanceSettings  `json:"settings"`
+	Pods              []PodWorkerStatus `json:"pods"`
+}
+
+// ZoneStatus holds the entries aggregated for a synchronized zone in the last round.
+type ZoneStatus struct {
+	Name    string `json:"name"`
+	Entries int    `json:"entries"`
+}
+
+// PodWorkerStatus tells whether the requests to a pod are failing, a pod is
+// failing since the last request to it failed.
+type PodWorkerStatus struct {
+	Name                string    `json:"name"`
+	URL                 string    `json:"url"`
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastSuccess         time.Time `json:"lastSuccess"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastErrorTime       time.Time `json:"lastErrorTime"`
+	ClockSkew           string    `json:"clockSkew"`
+	Resets              int       `json:"resets"`
+}
+
+// instanceStatus has its own lock so it can be read while a round holds the worker one.
+type instanceStatus struct {
+	mu                sync.Mutex
+	interval          time.Duration
+	lastRound         time.Time
+	lastRoundDuration time.Duration
+	discoveredZones   []string
+	zoneEntries       map[string]int
+	settings          InstanceSettings
+}
+
+func (s *instanceStatus) setInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = interval
+}
+
+func (s *instanceStatus) setSettings(settings InstanceSettings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = settings
+}
+
+// setZones keeps the entries of the zones still synchronized.
+func (s *instanceStatus) setZones(discovered, synchronized []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.discoveredZones = discovered
+	zoneEntries := make(map[string]int, len(synchronized))
+	for _, zone := range synchronized {
+		zoneEntries[zone] = s.zoneEntries[zone]
+	}
+	s.zoneEntries = zoneEntries
+}
+
+// zones returns the discovered zones and the synchronized ones.
+func (s *instanceStatus) zones() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	synchronized := make([]string, 0, len(s.zoneEntries))
+	for zone := range s.zoneEntries {
+		synchronized = append(synchronized, zone)
+	}
+	return slices.Clone(s.discoveredZones), sortedZones(synchronized)
+}
+
+// roundDone records a round, zones removed while it ran are not added back.
+func (s *instanceStatus) roundDone(start time.Time, duration time.Duration, zoneEntries map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRound = start
+	s.lastRoundDuration = duration
+	for zone := range s.zoneEntries {
+		s.zoneEntries[zone] = zoneEntries[zone]
+	}
+}
+
+type podStatus struct {
+	mu                  sync.Mutex
+	lastSuccess         time.Time
+	lastError           string
+	lastErrorTime       time.Time
+	consecutiveFailures int
+}
+
+func (s *podStatus) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.lastSuccess = time.Now()
+		s.consecutiveFailures = 0
+		return
+	}
+	s.lastError = err.Error()
+	s.lastErrorTime = time.Now()
+	s.consecutiveFailures++
+}
+
+// Status returns a snapshot of the worker and its pod workers.
+func (w *RpaasInstanceSyncWorker) Status() InstanceWorkerStatus {
+	w.status.mu.Lock()
+	status := InstanceWorkerStatus{
+		Instance:          w.Instance,
+		Service:           w.Service,
+		Interval:          w.status.interval.String(),
+		LastRound:         w.status.lastRound,
+		LastRoundDuration: w.status.lastRoundDuration.String(),
+		DiscoveredZones:   slices.Clone(w.status.discoveredZones),
+		Zones:             make([]ZoneStatus, 0, len(w.status.zoneEntries)),
+		Settings:          w.status.settings,
+		Pods:              []PodWorkerStatus{},
+	}
+	for zone, entries := range w.status.zoneEntries {
+		status.Zones = append(status.Zones, ZoneStatus{Name: zone, Entries: entries})
+	}
+	w.status.mu.Unlock()
+	slices.SortFunc(status.Zones, func(a, b ZoneStatus) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	w.PodWorkerManager.ForEachWorker(func(worker Worker) {
+		if podWorker, ok := worker.(*RpaasPodWorker); ok {
+			status.Pods = append(status.Pods, podWorker.Status())
+		}
+	})
+	slices.SortFunc(status.Pods, func(a, b PodWorkerStatus) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return status
+}
+
+func (w *RpaasPodWorker) Status() PodWorkerStatus {
+	w.status.mu.Lock()
+	defer w.status.mu.Unlock()
+	state := PodStateUnknown
+	if w.status.consecutiveFailures > 0 {
+		state = PodStateFailing
+	} else if !w.status.lastSuccess.IsZero() {
+		state = PodStateHealthy
+	}
+	return PodWorkerStatus{
+		Name:                w.Name,
+		URL:                 w.URL,
+		State:               state,
+		ConsecutiveFailures: w.status.consecutiveFailures,
+		LastSuccess:         w.status.lastSuccess,
+		LastError:           w.status.lastError,
+		LastErrorTime:       w.status.lastErrorTime,
+		ClockSkew:           w.clock.estimate().String(),
+		Resets:              w.resetCount(),
+	}
+}
+
+func (w *RpaasPodWorker) resetCount() int {
+	w.stateMu.Lock()
+	defer w.stateMu.Unlock()
+	return w.resets
+}
+
+// Statuses returns the status of every instance worker, sorted by instance.
+func (gm *GoroutineManager) Statuses() []InstanceWorkerStatus {
+	instanceWorkers := []*RpaasInstanceSyncWorker{}
+	gm.ForEachWorker(func(worker Worker) {
+		if instanceWorker, ok := worker.(*RpaasInstanceSyncWorker); ok {
+			instanceWorkers = append(instanceWorkers, instanceWorker)
+		}
+	})
+	statuses := make([]InstanceWorkerStatus, 0, len(instanceWorkers))
+	for _, instanceWorker := range instanceWorkers {
+		statuses = append(statuses, instanceWorker.Status())
+	}
+	slices.SortFunc(statuses, func(a, b InstanceWorkerStatus) int {
+		return cmp.Compare(a.Instance, b.Instance)
+	})
+	return statuses
+}
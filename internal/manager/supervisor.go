@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	supervisorInitialBackoff = 100 * time.Millisecond
+	supervisorMaxBackoff     = 30 * time.Second
+	// supervisorStableAfter is how long a worker must run to start over from the initial backoff
+	supervisorStableAfter = time.Minute
+)
+
+// supervisedWorker identifies a worker in the metrics and logs of the supervisor.
+type supervisedWorker struct {
+	service    string
+	instance   string
+	workerType string
+	pod        string
+}
+
+// supervise runs work until it returns, restarting it with exponential
+// backoff when it panics. The worker keeps its state across restarts, so
+// work must not leave locks held or data half updated when panicking.
+func supervise(ctx context.Context, logger *slog.Logger, worker supervisedWorker, work func(ctx context.Context)) {
+	backoff := supervisorInitialBackoff
+	for {
+		started := time.Now()
+		if !recovered(logger, worker, func() { work(ctx) }) || ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > supervisorStableAfter {
+			backoff = supervisorInitialBackoff
+		}
+		logger.Warn("Restarting worker after panic", "workerType", worker.workerType, "pod", worker.pod, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		workerRestartsCounterVec.WithLabelValues(worker.service, worker.instance, worker.workerType, worker.pod).Inc()
+		backoff *= 2
+		if backoff > supervisorMaxBackoff {
+			backoff = supervisorMaxBackoff
+		}
+	}
+}
+
+// recovered runs f, reporting whether it panicked.
+func recovered(logger *slog.Logger, worker supervisedWorker, f func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			workerPanicsCounterVec.WithLabelValues(worker.service, worker.instance, worker.workerType, worker.pod).Inc()
+			logger.Error("Worker panicked", "workerType", worker.workerType, "pod", worker.pod, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+		}
+	}()
+	f()
+	return false
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupervise(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	worker := supervisedWorker{service: serviceName, instance: "supervised", workerType: "pod", pod: "pod-1"}
+
+	t.Run("restarts after panics", func(t *testing.T) {
+		runs := 0
+		supervise(context.Background(), logger, worker, func(ctx context.Context) {
+			runs++
+			if runs < 3 {
+				panic("malformed entry")
+			}
+		})
+		assert.Equal(t, 3, runs)
+		assert.Equal(t, 2.0, testutil.ToFloat64(workerPanicsCounterVec.WithLabelValues(serviceName, "supervised", "pod", "pod-1")))
+		assert.Equal(t, 2.0, testutil.ToFloat64(workerRestartsCounterVec.WithLabelValues(serviceName, "supervised", "pod", "pod-1")))
+	})
+
+	t.Run("doesn't restart once stopped", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		runs := 0
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			supervise(ctx, logger, worker, func(ctx context.Context) {
+				runs++
+				cancel()
+				panic("stopping")
+			})
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("supervisor didn't return")
+		}
+		assert.Equal(t, 1, runs)
+	})
+}
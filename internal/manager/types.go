@@ -1,12 +1,17 @@
 package manager
 
+import "context"
+
 type Optional[T any] struct {
 	Value T
 	Error error
 }
 
 type Worker interface {
-	Start()
+	// Start runs the worker in the background until ctx is done or Stop is called.
+	Start(ctx context.Context)
+	// Stop asks the worker to stop, Done is closed once it has.
 	Stop()
+	Done() <-chan struct{}
 	GetID() string
 }
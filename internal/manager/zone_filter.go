@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// zonePattern is a glob, or a regular expression when written between slashes like /^api-/.
+type zonePattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+func parseZonePattern(pattern string) (zonePattern, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		regex, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return zonePattern{}, fmt.Errorf("invalid zone pattern %q: %w", pattern, err)
+		}
+		return zonePattern{regex: regex}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return zonePattern{}, fmt.Errorf("invalid zone pattern %q: %w", pattern, err)
+	}
+	return zonePattern{glob: pattern}, nil
+}
+
+func (p zonePattern) match(zone string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(zone)
+	}
+	matched, _ := path.Match(p.glob, zone)
+	return matched
+}
+
+// ZoneFilter selects the zones synchronized across pods, the others are kept local to each pod.
+type ZoneFilter struct {
+	include []zonePattern
+	exclude []zonePattern
+}
+
+// NewZoneFilter builds a filter matching zones included by any of the
+// include patterns, or every zone when there are none, and not excluded by
+// any of the exclude patterns.
+func NewZoneFilter(include, exclude []string) (ZoneFilter, error) {
+	var filter ZoneFilter
+	for _, pattern := range include {
+		p, err := parseZonePattern(pattern)
+		if err != nil {
+			return ZoneFilter{}, err
+		}
+		filter.include = append(filter.include, p)
+	}
+	for _, pattern := range exclude {
+		p, err := parseZonePattern(pattern)
+		if err != nil {
+			return ZoneFilter{}, err
+		}
+		filter.exclude = append(filter.exclude, p)
+	}
+	return filter, nil
+}
+
+// Merge returns a filter for an instance: its include patterns replace the
+// global ones when present and exclude patterns of both apply.
+func (f ZoneFilter) Merge(instance ZoneFilter) ZoneFilter {
+	merged := ZoneFilter{include: f.include}
+	if len(instance.include) > 0 {
+		merged.include = instance.include
+	}
+	merged.exclude = append(append(merged.exclude, f.exclude...), instance.exclude...)
+	return merged
+}
+
+func (f ZoneFilter) Match(zone string) bool {
+	included := len(f.include) == 0
+	for _, p := range f.include {
+		if p.match(zone) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, p := range f.exclude {
+		if p.match(zone) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f ZoneFilter) Filter(zones []string) []string {
+	filtered := []string{}
+	for _, zone := range zones {
+		if f.Match(zone) {
+			filtered = append(filtered, zone)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,34 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZoneFilter(t *testing.T) {
+	zones := []string{"api-global", "api-local", "login", "static"}
+
+	filter, err := NewZoneFilter(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, zones, filter.Filter(zones))
+
+	filter, err = NewZoneFilter([]string{"api-*"}, []string{"/-local$/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api-global"}, filter.Filter(zones))
+
+	instanceFilter, err := NewZoneFilter([]string{"/^(login|api-.*)$/"}, []string{"api-global"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"login"}, filter.Merge(instanceFilter).Filter(zones))
+
+	instanceFilter, err = NewZoneFilter(nil, []string{"static"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api-global"}, filter.Merge(instanceFilter).Filter(zones))
+
+	_, err = NewZoneFilter([]string{"/(/"}, nil)
+	assert.Error(t, err)
+
+	_, err = NewZoneFilter(nil, []string{"["})
+	assert.Error(t, err)
+}
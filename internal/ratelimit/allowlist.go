@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Allowlist matches keys, as rendered by Key.String, against exact keys, IP addresses and CIDR ranges.
+type Allowlist struct {
+	keys     map[string]struct{}
+	networks []*net.IPNet
+}
+
+func NewAllowlist(entries []string) (Allowlist, error) {
+	allowlist := Allowlist{keys: make(map[string]struct{})}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		network, err := parseAllowlistNetwork(entry)
+		if err != nil {
+			return Allowlist{}, err
+		}
+		if network != nil {
+			allowlist.networks = append(allowlist.networks, network)
+			continue
+		}
+		allowlist.keys[entry] = struct{}{}
+	}
+	return allowlist, nil
+}
+
+// ValidateAllowlistEntry returns an error for entries that look like, but are not, valid CIDR ranges.
+func ValidateAllowlistEntry(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return fmt.Errorf("empty allowlist entry")
+	}
+	_, err := parseAllowlistNetwork(strings.TrimSpace(entry))
+	return err
+}
+
+func parseAllowlistNetwork(entry string) (*net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		return network, nil
+	}
+	if ip := net.ParseIP(entry); ip != nil {
+		bits := 8 * net.IPv6len
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip, bits = ipv4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	return nil, nil
+}
+
+func (a Allowlist) Empty() bool {
+	return len(a.keys) == 0 && len(a.networks) == 0
+}
+
+func (a Allowlist) Contains(key string) bool {
+	if _, ok := a.keys[key]; ok {
+		return true
+	}
+	if len(a.networks) == 0 {
+		return false
+	}
+	ip := net.ParseIP(key)
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
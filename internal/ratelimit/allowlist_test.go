@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowlist(t *testing.T) {
+	allowlist, err := NewAllowlist([]string{"10.0.0.0/8", "192.168.0.1", "2001:db8::/32", "healthcheck", " "})
+	require.NoError(t, err)
+	assert.False(t, allowlist.Empty())
+
+	assert.True(t, allowlist.Contains("10.1.2.3"))
+	assert.True(t, allowlist.Contains("192.168.0.1"))
+	assert.True(t, allowlist.Contains("2001:db8::1"))
+	assert.True(t, allowlist.Contains("healthcheck"))
+	assert.False(t, allowlist.Contains("192.168.0.2"))
+	assert.False(t, allowlist.Contains("2001:db9::1"))
+	assert.False(t, allowlist.Contains("other"))
+
+	_, err = NewAllowlist([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+	assert.True(t, Allowlist{}.Empty())
+	assert.False(t, Allowlist{}.Contains("10.0.0.1"))
+}
+
+func TestParseKey(t *testing.T) {
+	header := RateLimitHeader{Key: BinaryRemoteAddress}
+	key, err := ParseKey("10.0.0.1", header)
+	require.NoError(t, err)
+	assert.Len(t, key, 4)
+	assert.Equal(t, "10.0.0.1", key.String(header))
+
+	key, err = ParseKey("2001:db8::1", header)
+	require.NoError(t, err)
+	assert.Len(t, key, 16)
+	assert.Equal(t, "2001:db8::1", key.String(header))
+
+	_, err = ParseKey("not-an-ip", header)
+	assert.Error(t, err)
+
+	key, err = ParseKey("10.0.0.1", RateLimitHeader{Key: RemoteAddress})
+	require.NoError(t, err)
+	assert.Equal(t, Key("10.0.0.1"), key)
+}
+
+func TestKeyPrefix(t *testing.T) {
+	prefix, ok := KeyPrefix("10.1.2.3", 24, 64)
+	assert.True(t, ok)
+	assert.Equal(t, "10.1.2.0/24", prefix)
+
+	prefix, ok = KeyPrefix("2001:db8:1:2:3:4:5:6", 24, 64)
+	assert.True(t, ok)
+	assert.Equal(t, "2001:db8:1:2::/64", prefix)
+
+	prefix, ok = KeyPrefix("::ffff:10.1.2.3", 16, 64)
+	assert.True(t, ok)
+	assert.Equal(t, "10.1.0.0/16", prefix)
+
+	_, ok = KeyPrefix("not-an-ip", 24, 64)
+	assert.False(t, ok)
+}
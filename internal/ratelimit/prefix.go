@@ -0,0 +1,19 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+)
+
+// KeyPrefix returns the network, in CIDR notation, containing the IP address
+// key. It returns false for keys that are not IP addresses.
+func KeyPrefix(key string, ipv4PrefixLength, ipv6PrefixLength int) (string, bool) {
+	ip := net.ParseIP(key)
+	if ip == nil {
+		return "", false
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return fmt.Sprintf("%s/%d", ipv4.Mask(net.CIDRMask(ipv4PrefixLength, 8*net.IPv4len)), ipv4PrefixLength), true
+	}
+	return fmt.Sprintf("%s/%d", ip.Mask(net.CIDRMask(ipv6PrefixLength, 8*net.IPv6len)), ipv6PrefixLength), true
+}
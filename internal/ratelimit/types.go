@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"fmt"
 	"net"
 )
 
@@ -48,6 +49,25 @@ func (r Key) String(header RateLimitHeader) string {
 	}
 }
 
+// ParseKey is the inverse of Key.String.
+func ParseKey(s string, header RateLimitHeader) (Key, error) {
+	switch header.Key {
+	case BinaryRemoteAddress:
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("%q is not an IP address", s)
+		}
+		if ipv4 := ip.To4(); ipv4 != nil {
+			return Key(ipv4), nil
+		}
+		return Key(ip), nil
+	case RemoteAddress:
+		fallthrough
+	default:
+		return Key(s), nil
+	}
+}
+
 type FullZoneKey struct {
 	Zone string
 	Key  string
@@ -56,4 +76,8 @@ type FullZoneKey struct {
 type RpaasZoneData struct {
 	RpaasName string
 	Data      []Zone
+	// MaxTopOffenders overrides how many offenders of the instance are reported, when positive
+	MaxTopOffenders int
+	// Removed tells the instance worker was removed, its data must be dropped
+	Removed bool
 }
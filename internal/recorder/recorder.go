@@ -0,0 +1,221 @@
+// Package recorder keeps the raw zone data read from the pods, so an
+// aggregation can be replayed offline after the round is gone.
+package recorder
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// RecordTypeRead is the response of a pod to a zone read.
+	RecordTypeRead = "read"
+	// RecordTypeAggregated is the result of the zone aggregator, before allowlists and overrides.
+	RecordTypeAggregated = "aggregated"
+)
+
+type Record struct {
+	Type     string    `msgpack:"type"`
+	Time     time.Time `msgpack:"time"`
+	Instance string    `msgpack:"instance"`
+	Pod      string    `msgpack:"pod,omitempty"`
+	Zone     string    `msgpack:"zone"`
+	Round    uint64    `msgpack:"round"`
+	// ReadType is full or incremental, reads recorded without it are full.
+	ReadType string `msgpack:"read_type,omitempty"`
+	// Correction is the clock skew correction of the pod in milliseconds, subtracted from the Last of the entries read.
+	Correction int64 `msgpack:"correction,omitempty"`
+	// Persisted tells the aggregated zone was kept as the state of the next round.
+	Persisted bool `msgpack:"persisted,omitempty"`
+	// Body is the raw response of a read, or the aggregated zone encoded as msgpack.
+	Body  []byte `msgpack:"body"`
+	Error string `msgpack:"error,omitempty"`
+}
+
+// Recorder appends records to a file, rotating it to path.1, path.2 and so on
+// once it is over maxSize and keeping maxFiles files in total.
+type Recorder struct {
+	mu        sync.Mutex
+	path      string
+	maxSize   int64
+	maxFiles  int
+	instances []string
+	file      *os.File
+	writer    *bufio.Writer
+	size      int64
+	rounds    map[string]uint64
+	now       func() time.Time
+}
+
+// New opens the recorder file, appending to it. Only the given instances are recorded, every one when empty.
+func New(path string, maxSize int64, maxFiles int, instances []string) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		maxSize:   maxSize,
+		maxFiles:  maxFiles,
+		instances: instances,
+		rounds:    make(map[string]uint64),
+		now:       time.Now,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening recorder file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error opening recorder file: %w", err)
+	}
+	r.file = file
+	r.writer = bufio.NewWriter(file)
+	r.size = info.Size()
+	return nil
+}
+
+// Enabled tells whether an instance is recorded, a nil recorder records nothing.
+func (r *Recorder) Enabled(instance string) bool {
+	if r == nil {
+		return false
+	}
+	return len(r.instances) == 0 || slices.Contains(r.instances, instance)
+}
+
+// StartRound numbers the next round of an instance, the records of the instance belong to it until the next call.
+func (r *Recorder) StartRound(instance string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rounds[instance]++
+	return r.rounds[instance]
+}
+
+// Record writes a record in the current round of its instance.
+func (r *Recorder) Record(record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return errors.New("recorder is closed")
+	}
+	record.Round = r.rounds[record.Instance]
+	if record.Time.IsZero() {
+		record.Time = r.now()
+	}
+	encoded, err := msgpack.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error encoding record: %w", err)
+	}
+	if r.size > 0 && r.size+int64(len(encoded)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := r.writer.Write(encoded); err != nil {
+		return fmt.Errorf("error writing record: %w", err)
+	}
+	r.size += int64(len(encoded))
+	// Records must survive a crash, which is when they are needed the most
+	if err := r.writer.Flush(); err != nil {
+		return fmt.Errorf("error writing record: %w", err)
+	}
+	return nil
+}
+
+func (r *Recorder) rotate() error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	for i := r.maxFiles - 1; i > 0; i-- {
+		from := r.path
+		if i > 1 {
+			from = fmt.Sprintf("%s.%d", r.path, i-1)
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", r.path, i)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error rotating recorder file: %w", err)
+		}
+	}
+	if r.maxFiles <= 1 {
+		if err := os.Remove(r.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error rotating recorder file: %w", err)
+		}
+	}
+	return r.open()
+}
+
+func (r *Recorder) closeFile() error {
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return fmt.Errorf("error closing recorder file: %w", err)
+	}
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("error closing recorder file: %w", err)
+	}
+	r.file = nil
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	return r.closeFile()
+}
+
+// Files returns the files of a recorder path from the oldest to the newest.
+func Files(path string) []string {
+	var files []string
+	for i := 1; ; i++ {
+		rotated := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(rotated); err != nil {
+			break
+		}
+		files = append(files, rotated)
+	}
+	slices.Reverse(files)
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// ReadFiles reads the records of every file in order. A record cut by a
+// crash at the end of a file is ignored.
+func ReadFiles(paths ...string) ([]Record, error) {
+	var records []Record
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder := msgpack.NewDecoder(bufio.NewReader(file))
+		for {
+			var record Record
+			err := decoder.Decode(&record)
+			if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("error reading record %d of %s: %w", len(records), path, err)
+			}
+			records = append(records, record)
+		}
+		file.Close()
+	}
+	return records, nil
+}
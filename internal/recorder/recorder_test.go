@@ -0,0 +1,97 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderRounds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.rec")
+	r, err := New(path, 1<<20, 2, []string{"one", "two"})
+	require.NoError(t, err)
+	assert.True(t, r.Enabled("one"))
+	assert.False(t, r.Enabled("three"))
+
+	require.NoError(t, r.Record(Record{Type: RecordTypeRead, Instance: "one", Zone: "z"}))
+	assert.Equal(t, uint64(1), r.StartRound("one"))
+	require.NoError(t, r.Record(Record{Type: RecordTypeRead, Instance: "one", Pod: "pod-a", Zone: "z", Body: []byte{1, 2}}))
+	assert.Equal(t, uint64(1), r.StartRound("two"))
+	assert.Equal(t, uint64(2), r.StartRound("one"))
+	require.NoError(t, r.Record(Record{Type: RecordTypeAggregated, Instance: "one", Zone: "z", Persisted: true}))
+	require.NoError(t, r.Close())
+	assert.Error(t, r.Record(Record{Type: RecordTypeRead, Instance: "one"}))
+
+	records, err := ReadFiles(Files(path)...)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, uint64(0), records[0].Round)
+	assert.Equal(t, uint64(1), records[1].Round)
+	assert.Equal(t, "pod-a", records[1].Pod)
+	assert.Equal(t, []byte{1, 2}, records[1].Body)
+	assert.False(t, records[1].Time.IsZero())
+	assert.Equal(t, uint64(2), records[2].Round)
+	assert.True(t, records[2].Persisted)
+}
+
+func TestRecorderNil(t *testing.T) {
+	var r *Recorder
+	assert.False(t, r.Enabled("one"))
+}
+
+func TestRecorderRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.rec")
+	r, err := New(path, 200, 3, nil)
+	require.NoError(t, err)
+	assert.True(t, r.Enabled("any"))
+	for i := 0; i < 20; i++ {
+		r.StartRound("one")
+		require.NoError(t, r.Record(Record{Type: RecordTypeRead, Instance: "one", Zone: "z", Body: make([]byte, 100)}))
+	}
+	require.NoError(t, r.Close())
+
+	files := Files(path)
+	assert.Equal(t, []string{path + ".2", path + ".1", path}, files)
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+	records, err := ReadFiles(files...)
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+	assert.Less(t, len(records), 20)
+	// Only the oldest records are dropped, the ones kept are still in order
+	for i, record := range records {
+		assert.Equal(t, uint64(20-len(records)+i+1), record.Round)
+	}
+
+	// Reopening appends to the current file
+	r, err = New(path, 1<<20, 3, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.Record(Record{Type: RecordTypeRead, Instance: "one", Zone: "z"}))
+	require.NoError(t, r.Close())
+	reopened, err := ReadFiles(Files(path)...)
+	require.NoError(t, err)
+	assert.Len(t, reopened, len(records)+1)
+}
+
+func TestReadFilesTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.rec")
+	r, err := New(path, 1<<20, 1, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.Record(Record{Type: RecordTypeRead, Instance: "one", Zone: "z", Body: []byte("first")}))
+	require.NoError(t, r.Record(Record{Type: RecordTypeRead, Instance: "one", Zone: "z", Body: []byte("second")}))
+	require.NoError(t, r.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+	records, err := ReadFiles(path)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, []byte("first"), records[0].Body)
+
+	_, err = ReadFiles(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
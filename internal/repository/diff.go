@@ -0,0 +1,42 @@
+package repository
+
+type DataID struct {
+	Key  string `json:"key"`
+	Zone string `json:"zone"`
+}
+
+type DataDiff struct {
+	Upserted []Data   `json:"upserted"`
+	Removed  []DataID `json:"removed"`
+}
+
+func (d DataDiff) Empty() bool {
+	return len(d.Upserted) == 0 && len(d.Removed) == 0
+}
+
+// Diff returns the entries added or changed in current and the entries of previous that are gone.
+func Diff(previous, current []Data) DataDiff {
+	diff := DataDiff{
+		Upserted: []Data{},
+		Removed:  []DataID{},
+	}
+	previousByID := make(map[DataID]Data, len(previous))
+	for _, data := range previous {
+		previousByID[DataID{Key: data.Key, Zone: data.Zone}] = data
+	}
+	for _, data := range current {
+		id := DataID{Key: data.Key, Zone: data.Zone}
+		old, exists := previousByID[id]
+		if !exists || old != data {
+			diff.Upserted = append(diff.Upserted, data)
+		}
+		delete(previousByID, id)
+	}
+	for _, data := range previous {
+		id := DataID{Key: data.Key, Zone: data.Zone}
+		if _, stillMissing := previousByID[id]; stillMissing {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	t.Run("should return everything as upserted without previous data", func(t *testing.T) {
+		assert := assert.New(t)
+		current := []Data{
+			{Key: "1", Zone: "one", Last: 10, Excess: 10},
+			{Key: "2", Zone: "one", Last: 10, Excess: 20},
+		}
+		diff := Diff(nil, current)
+		assert.ElementsMatch(current, diff.Upserted)
+		assert.Empty(diff.Removed)
+	})
+
+	t.Run("should return changed and removed entries", func(t *testing.T) {
+		assert := assert.New(t)
+		previous := []Data{
+			{Key: "1", Zone: "one", Last: 10, Excess: 10},
+			{Key: "2", Zone: "one", Last: 10, Excess: 20},
+			{Key: "2", Zone: "two", Last: 10, Excess: 20},
+		}
+		current := []Data{
+			{Key: "1", Zone: "one", Last: 10, Excess: 10},
+			{Key: "2", Zone: "one", Last: 11, Excess: 25},
+			{Key: "3", Zone: "one", Last: 11, Excess: 5},
+		}
+		diff := Diff(previous, current)
+		assert.ElementsMatch([]Data{
+			{Key: "2", Zone: "one", Last: 11, Excess: 25},
+			{Key: "3", Zone: "one", Last: 11, Excess: 5},
+		}, diff.Upserted)
+		assert.Equal([]DataID{{Key: "2", Zone: "two"}}, diff.Removed)
+	})
+
+	t.Run("should be empty when nothing changed", func(t *testing.T) {
+		data := []Data{{Key: "1", Zone: "one", Last: 10, Excess: 10}}
+		assert.True(t, Diff(data, data).Empty())
+	})
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"sync"
+)
+
+// Update is published to subscribers whenever the stored data of an instance changes.
+type Update struct {
+	Instance string
+	Data     []Data
+	Raw      []byte
+}
+
+// Subscription receives the updates of a single instance. Only the latest
+// update is kept, so slow consumers skip intermediate states instead of
+// blocking the repository.
+type Subscription struct {
+	Instance string
+	C        <-chan Update
+	ch       chan Update
+}
+
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (h *Hub) Subscribe(instance string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan Update, 1)
+	sub := &Subscription{Instance: instance, C: ch, ch: ch}
+	if _, ok := h.subscribers[instance]; !ok {
+		h.subscribers[instance] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[instance][sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs, ok := h.subscribers[sub.Instance]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.Instance)
+	}
+	close(sub.ch)
+}
+
+func (h *Hub) Publish(update Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers[update.Instance] {
+		for {
+			select {
+			case sub.ch <- update:
+			default:
+				// Drop the pending update, the new one supersedes it
+				select {
+				case <-sub.ch:
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+func (h *Hub) CountSubscribers(instance string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers[instance])
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub(t *testing.T) {
+	t.Run("should deliver updates only to subscribers of the instance", func(t *testing.T) {
+		assert := assert.New(t)
+		hub := NewHub()
+		sub1 := hub.Subscribe("instance1")
+		sub2 := hub.Subscribe("instance2")
+		hub.Publish(Update{Instance: "instance1", Raw: []byte("one")})
+		update := <-sub1.C
+		assert.Equal("instance1", update.Instance)
+		assert.Equal([]byte("one"), update.Raw)
+		assert.Len(sub2.C, 0)
+	})
+
+	t.Run("should keep only the latest update for slow subscribers", func(t *testing.T) {
+		assert := assert.New(t)
+		hub := NewHub()
+		sub := hub.Subscribe("instance1")
+		hub.Publish(Update{Instance: "instance1", Raw: []byte("one")})
+		hub.Publish(Update{Instance: "instance1", Raw: []byte("two")})
+		hub.Publish(Update{Instance: "instance1", Raw: []byte("three")})
+		update := <-sub.C
+		assert.Equal([]byte("three"), update.Raw)
+		assert.Len(sub.C, 0)
+	})
+
+	t.Run("should close the channel on unsubscribe", func(t *testing.T) {
+		assert := assert.New(t)
+		hub := NewHub()
+		sub := hub.Subscribe("instance1")
+		assert.Equal(1, hub.CountSubscribers("instance1"))
+		hub.Unsubscribe(sub)
+		hub.Unsubscribe(sub)
+		assert.Equal(0, hub.CountSubscribers("instance1"))
+		_, ok := <-sub.C
+		assert.False(ok)
+		hub.Publish(Update{Instance: "instance1"})
+	})
+}
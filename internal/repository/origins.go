@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/geoip"
+)
+
+const (
+	OriginByCountry = "country"
+	OriginByASN     = "asn"
+)
+
+var OriginDimensions = []string{OriginByCountry, OriginByASN}
+
+// KeyEnricher tells where a key comes from.
+type KeyEnricher interface {
+	LookupKey(key string) (geoip.Info, bool)
+}
+
+type OriginData struct {
+	Origin string `json:"origin"`
+	Name   string `json:"name,omitempty"`
+	Zone   string `json:"zone"`
+	Keys   int    `json:"keys"`
+	Last   int64  `json:"last"`
+	Excess int64  `json:"excess"`
+}
+
+// Enrich fills the origin of the keys the enricher knows about.
+func Enrich(data []Data, enricher KeyEnricher) {
+	cache := make(map[string]geoip.Info)
+	for i := range data {
+		info, cached := cache[data[i].Key]
+		if !cached {
+			info, _ = enricher.LookupKey(data[i].Key)
+			cache[data[i].Key] = info
+		}
+		data[i].Country = info.Country
+		data[i].ASN = info.ASN
+		data[i].ASOrganization = info.ASOrganization
+	}
+}
+
+// GroupByOrigin sums the excess of the enriched keys per country or ASN, keys of unknown origin are left out.
+func GroupByOrigin(data []Data, dimension string) []OriginData {
+	type originID struct {
+		origin string
+		zone   string
+	}
+	origins := make(map[originID]*OriginData)
+	for _, d := range data {
+		var origin, name string
+		switch dimension {
+		case OriginByCountry:
+			origin = d.Country
+		case OriginByASN:
+			if d.ASN != 0 {
+				origin = fmt.Sprintf("AS%d", d.ASN)
+				name = d.ASOrganization
+			}
+		}
+		if origin == "" {
+			continue
+		}
+		id := originID{origin: origin, zone: d.Zone}
+		o, exists := origins[id]
+		if !exists {
+			o = &OriginData{Origin: origin, Name: name, Zone: d.Zone}
+			origins[id] = o
+		}
+		o.Keys++
+		o.Last = max(o.Last, d.Last)
+		o.Excess += d.Excess
+	}
+	result := make([]OriginData, 0, len(origins))
+	for _, o := range origins {
+		result = append(result, *o)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Excess != result[j].Excess {
+			return result[i].Excess > result[j].Excess
+		}
+		return result[i].Origin < result[j].Origin
+	})
+	return result
+}
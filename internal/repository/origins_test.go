@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/geoip"
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+type fakeEnricher map[string]geoip.Info
+
+func (f fakeEnricher) LookupKey(key string) (geoip.Info, bool) {
+	info, ok := f[key]
+	return info, ok
+}
+
+var testEnricher = fakeEnricher{
+	"10.0.0.1":     {Country: "BR", ASN: 64500, ASOrganization: "Example Hosting"},
+	"10.0.0.2":     {Country: "BR", ASN: 64500, ASOrganization: "Example Hosting"},
+	"192.0.2.1":    {Country: "US", ASN: 64501, ASOrganization: "Example Cloud"},
+	"198.51.100.1": {Country: "US"},
+}
+
+func TestGroupByOrigin(t *testing.T) {
+	data := []Data{
+		{Key: "10.0.0.1", Zone: "zone1", Last: 10, Excess: 5},
+		{Key: "10.0.0.2", Zone: "zone1", Last: 20, Excess: 7},
+		{Key: "192.0.2.1", Zone: "zone1", Last: 30, Excess: 3},
+		{Key: "198.51.100.1", Zone: "zone1", Last: 40, Excess: 20},
+		{Key: "203.0.113.1", Zone: "zone1", Last: 50, Excess: 100},
+	}
+	Enrich(data, testEnricher)
+
+	assert.Equal(t, Data{Key: "10.0.0.1", Zone: "zone1", Last: 10, Excess: 5, Country: "BR", ASN: 64500, ASOrganization: "Example Hosting"}, data[0])
+	assert.Equal(t, Data{Key: "203.0.113.1", Zone: "zone1", Last: 50, Excess: 100}, data[4])
+
+	assert.Equal(t, []OriginData{
+		{Origin: "US", Zone: "zone1", Keys: 2, Last: 40, Excess: 23},
+		{Origin: "BR", Zone: "zone1", Keys: 2, Last: 20, Excess: 12},
+	}, GroupByOrigin(data, OriginByCountry))
+
+	assert.Equal(t, []OriginData{
+		{Origin: "AS64500", Name: "Example Hosting", Zone: "zone1", Keys: 2, Last: 20, Excess: 12},
+		{Origin: "AS64501", Name: "Example Cloud", Zone: "zone1", Keys: 1, Last: 30, Excess: 3},
+	}, GroupByOrigin(data, OriginByASN))
+}
+
+func TestZoneDataRepositoryEnrichment(t *testing.T) {
+	r, _ := NewRpaasZoneDataRepository()
+	r.SetEnricher(testEnricher)
+	r.insert(ratelimit.RpaasZoneData{
+		RpaasName: "test-rpaas",
+		Data: []ratelimit.Zone{{
+			Name: "test-zone",
+			RateLimitEntries: []ratelimit.RateLimitEntry{
+				{Key: []byte("10.0.0.1"), Last: 10, Excess: 5},
+				{Key: []byte("192.0.2.1"), Last: 20, Excess: 3},
+			},
+		}},
+	})
+
+	data, _, ok := r.GetRpaasZoneSnapshot("test-rpaas")
+	assert.True(t, ok)
+	assert.ElementsMatch(t, []Data{
+		{Key: "10.0.0.1", Zone: "test-zone", Last: 10, Excess: 5, Country: "BR", ASN: 64500, ASOrganization: "Example Hosting"},
+		{Key: "192.0.2.1", Zone: "test-zone", Last: 20, Excess: 3, Country: "US", ASN: 64501, ASOrganization: "Example Cloud"},
+	}, data)
+
+	origins, ok := r.GetRpaasOriginData("test-rpaas", OriginByCountry)
+	assert.True(t, ok)
+	assert.Equal(t, []OriginData{
+		{Origin: "BR", Zone: "test-zone", Keys: 1, Last: 10, Excess: 5},
+		{Origin: "US", Zone: "test-zone", Keys: 1, Last: 20, Excess: 3},
+	}, origins)
+
+	_, ok = r.GetRpaasOriginData("other-rpaas", OriginByCountry)
+	assert.False(t, ok)
+}
+
+// lockCheckingEnricher records the keys looked up and whether the repository was locked meanwhile.
+type lockCheckingEnricher struct {
+	repo   *ZoneDataRepository
+	keys   []string
+	locked bool
+}
+
+func (e *lockCheckingEnricher) LookupKey(key string) (geoip.Info, bool) {
+	e.keys = append(e.keys, key)
+	if e.repo.TryLock() {
+		e.repo.Unlock()
+	} else {
+		e.locked = true
+	}
+	return testEnricher.LookupKey(key)
+}
+
+func TestZoneDataRepositoryEnrichesReportedKeys(t *testing.T) {
+	r, _ := NewRpaasZoneDataRepository()
+	enricher := &lockCheckingEnricher{repo: r}
+	r.SetEnricher(enricher)
+	r.insert(ratelimit.RpaasZoneData{
+		RpaasName:       "test-rpaas",
+		MaxTopOffenders: 2,
+		Data: []ratelimit.Zone{{
+			Name: "test-zone",
+			RateLimitEntries: []ratelimit.RateLimitEntry{
+				{Key: []byte("10.0.0.1"), Last: 10, Excess: 5},
+				{Key: []byte("10.0.0.2"), Last: 10, Excess: 1},
+				{Key: []byte("192.0.2.1"), Last: 20, Excess: 3},
+			},
+		}},
+	})
+
+	assert.ElementsMatch(t, []string{"10.0.0.1", "192.0.2.1"}, enricher.keys)
+	assert.False(t, enricher.locked)
+	origins, ok := r.GetRpaasOriginData("test-rpaas", OriginByASN)
+	assert.True(t, ok)
+	assert.Equal(t, []OriginData{
+		{Origin: "AS64500", Name: "Example Hosting", Zone: "test-zone", Keys: 1, Last: 10, Excess: 5},
+		{Origin: "AS64501", Name: "Example Cloud", Zone: "test-zone", Keys: 1, Last: 20, Excess: 3},
+	}, origins)
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+type SubnetData struct {
+	Subnet string `json:"subnet"`
+	Zone   string `json:"zone"`
+	Keys   int    `json:"keys"`
+	Last   int64  `json:"last"`
+	Excess int64  `json:"excess"`
+}
+
+// GroupByPrefix sums the excess of the IP address keys of each network, keys
+// that aren't addresses are left out. When the prefixes are enforced every
+// address already carries the excess of its network, so it isn't summed again.
+func GroupByPrefix(data []Data, ipv4PrefixLength, ipv6PrefixLength int, enforced bool) []SubnetData {
+	type subnetID struct {
+		subnet string
+		zone   string
+	}
+	subnets := make(map[subnetID]*SubnetData)
+	for _, d := range data {
+		prefix, ok := ratelimit.KeyPrefix(d.Key, ipv4PrefixLength, ipv6PrefixLength)
+		if !ok {
+			continue
+		}
+		id := subnetID{subnet: prefix, zone: d.Zone}
+		subnet, exists := subnets[id]
+		if !exists {
+			subnet = &SubnetData{Subnet: prefix, Zone: d.Zone}
+			subnets[id] = subnet
+		}
+		subnet.Keys++
+		subnet.Last = max(subnet.Last, d.Last)
+		if enforced {
+			subnet.Excess = max(subnet.Excess, d.Excess)
+		} else {
+			subnet.Excess += d.Excess
+		}
+	}
+	result := make([]SubnetData, 0, len(subnets))
+	for _, subnet := range subnets {
+		result = append(result, *subnet)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Excess != result[j].Excess {
+			return result[i].Excess > result[j].Excess
+		}
+		return result[i].Subnet < result[j].Subnet
+	})
+	return result
+}
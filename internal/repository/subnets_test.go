@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupByPrefix(t *testing.T) {
+	data := []Data{
+		{Key: "10.0.0.1", Zone: "zone1", Last: 10, Excess: 5},
+		{Key: "10.0.0.2", Zone: "zone1", Last: 20, Excess: 7},
+		{Key: "10.0.0.3", Zone: "zone2", Last: 30, Excess: 1},
+		{Key: "2001:db8::1", Zone: "zone1", Last: 15, Excess: 4},
+		{Key: "2001:db8::ffff", Zone: "zone1", Last: 25, Excess: 9},
+		{Key: "api-key", Zone: "zone1", Last: 40, Excess: 100},
+	}
+
+	assert.Equal(t, []SubnetData{
+		{Subnet: "2001:db8::/64", Zone: "zone1", Keys: 2, Last: 25, Excess: 13},
+		{Subnet: "10.0.0.0/24", Zone: "zone1", Keys: 2, Last: 20, Excess: 12},
+		{Subnet: "10.0.0.0/24", Zone: "zone2", Keys: 1, Last: 30, Excess: 1},
+	}, GroupByPrefix(data, 24, 64, false))
+
+	assert.Equal(t, []SubnetData{
+		{Subnet: "2001:db8::/64", Zone: "zone1", Keys: 2, Last: 25, Excess: 9},
+		{Subnet: "10.0.0.0/24", Zone: "zone1", Keys: 2, Last: 20, Excess: 7},
+		{Subnet: "10.0.0.0/24", Zone: "zone2", Keys: 1, Last: 30, Excess: 1},
+	}, GroupByPrefix(data, 24, 64, true))
+}
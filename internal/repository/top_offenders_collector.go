@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	KeyModeRaw      = "raw"
+	KeyModeHash     = "hash"
+	KeyModeTruncate = "truncate"
+
+	hashedKeyLength = 12
+)
+
+var (
+	topOffenderExcessDesc = prometheus.NewDesc(
+		"rate_limit_control_plane_rpaas_top_offender_excess",
+		"Aggregated excess of the top offending keys per RPaaS instance and zone",
+		[]string{"rpaas_instance", "zone", "key"}, nil,
+	)
+	topOffenderLastDesc = prometheus.NewDesc(
+		"rate_limit_control_plane_rpaas_top_offender_last_seen_timestamp_seconds",
+		"Last time the top offending keys per RPaaS instance and zone were seen",
+		[]string{"rpaas_instance", "zone", "key"}, nil,
+	)
+)
+
+// TopOffendersCollector exposes the top keys by excess per instance and zone.
+// Cardinality is bounded by the limit per zone, keys may be hashed or truncated.
+type TopOffendersCollector struct {
+	repo      *ZoneDataRepository
+	keyMode   string
+	keyLength int
+}
+
+func NewTopOffendersCollector(repo *ZoneDataRepository, limit int, keyMode string, keyLength int) (*TopOffendersCollector, error) {
+	switch keyMode {
+	case KeyModeRaw, KeyModeHash:
+	case KeyModeTruncate:
+		if keyLength <= 0 {
+			return nil, fmt.Errorf("key length must be positive to truncate keys")
+		}
+	default:
+		return nil, fmt.Errorf("unknown key mode %q", keyMode)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("top offenders limit must be positive")
+	}
+	repo.Lock()
+	repo.topPerZoneLimit = limit
+	repo.Unlock()
+	return &TopOffendersCollector{
+		repo:      repo,
+		keyMode:   keyMode,
+		keyLength: keyLength,
+	}, nil
+}
+
+func (c *TopOffendersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- topOffenderExcessDesc
+	ch <- topOffenderLastDesc
+}
+
+func (c *TopOffendersCollector) Collect(ch chan<- prometheus.Metric) {
+	c.repo.Lock()
+	defer c.repo.Unlock()
+	for instance, zones := range c.repo.topPerZone {
+		for zone, data := range zones {
+			// Hashing and truncating may map different keys to the same label, keep the worst one
+			byKey := make(map[string]Data, len(data))
+			for _, d := range data {
+				key := c.formatKey(d.Key)
+				if current, exists := byKey[key]; !exists || d.Excess > current.Excess {
+					byKey[key] = d
+				}
+			}
+			for key, d := range byKey {
+				ch <- prometheus.MustNewConstMetric(topOffenderExcessDesc, prometheus.GaugeValue, float64(d.Excess), instance, zone, key)
+				ch <- prometheus.MustNewConstMetric(topOffenderLastDesc, prometheus.GaugeValue, float64(d.Last)/1000, instance, zone, key)
+			}
+		}
+	}
+}
+
+func (c *TopOffendersCollector) formatKey(key string) string {
+	switch c.keyMode {
+	case KeyModeHash:
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:])[:hashedKeyLength]
+	case KeyModeTruncate:
+		if len(key) > c.keyLength {
+			return key[:c.keyLength]
+		}
+		return key
+	default:
+		return key
+	}
+}
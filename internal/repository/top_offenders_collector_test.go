@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+func insertTopOffendersData(r *ZoneDataRepository) {
+	r.insert(ratelimit.RpaasZoneData{
+		RpaasName: "test-rpaas",
+		Data: []ratelimit.Zone{
+			{
+				Name: "zone-one",
+				RateLimitEntries: []ratelimit.RateLimitEntry{
+					{Key: []byte("key-aaa-1"), Last: 1622547800000, Excess: 30},
+					{Key: []byte("key-aaa-2"), Last: 1622547800000, Excess: 20},
+					{Key: []byte("key-bbb-1"), Last: 1622547800000, Excess: 10},
+				},
+			},
+			{
+				Name: "zone-two",
+				RateLimitEntries: []ratelimit.RateLimitEntry{
+					{Key: []byte("key-ccc-1"), Last: 1622547800000, Excess: 5},
+				},
+			},
+		},
+	})
+}
+
+func TestTopOffendersCollector(t *testing.T) {
+	t.Run("should reject invalid settings", func(t *testing.T) {
+		r, _ := NewRpaasZoneDataRepository()
+		_, err := NewTopOffendersCollector(r, 0, KeyModeRaw, 0)
+		assert.Error(t, err)
+		_, err = NewTopOffendersCollector(r, 10, "unknown", 0)
+		assert.Error(t, err)
+		_, err = NewTopOffendersCollector(r, 10, KeyModeTruncate, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("should expose top keys per zone", func(t *testing.T) {
+		r, _ := NewRpaasZoneDataRepository()
+		collector, err := NewTopOffendersCollector(r, 2, KeyModeRaw, 0)
+		require.NoError(t, err)
+		insertTopOffendersData(r)
+
+		expected := `
+# HELP rate_limit_control_plane_rpaas_top_offender_excess Aggregated excess of the top offending keys per RPaaS instance and zone
+# TYPE rate_limit_control_plane_rpaas_top_offender_excess gauge
+rate_limit_control_plane_rpaas_top_offender_excess{key="key-aaa-1",rpaas_instance="test-rpaas",zone="zone-one"} 30
+rate_limit_control_plane_rpaas_top_offender_excess{key="key-aaa-2",rpaas_instance="test-rpaas",zone="zone-one"} 20
+rate_limit_control_plane_rpaas_top_offender_excess{key="key-ccc-1",rpaas_instance="test-rpaas",zone="zone-two"} 5
+`
+		err = testutil.CollectAndCompare(collector, strings.NewReader(expected), "rate_limit_control_plane_rpaas_top_offender_excess")
+		assert.NoError(t, err)
+		assert.Equal(t, 6, testutil.CollectAndCount(collector))
+	})
+
+	t.Run("should merge truncated keys keeping the highest excess", func(t *testing.T) {
+		r, _ := NewRpaasZoneDataRepository()
+		collector, err := NewTopOffendersCollector(r, 10, KeyModeTruncate, 7)
+		require.NoError(t, err)
+		insertTopOffendersData(r)
+
+		expected := `
+# HELP rate_limit_control_plane_rpaas_top_offender_excess Aggregated excess of the top offending keys per RPaaS instance and zone
+# TYPE rate_limit_control_plane_rpaas_top_offender_excess gauge
+rate_limit_control_plane_rpaas_top_offender_excess{key="key-aaa",rpaas_instance="test-rpaas",zone="zone-one"} 30
+rate_limit_control_plane_rpaas_top_offender_excess{key="key-bbb",rpaas_instance="test-rpaas",zone="zone-one"} 10
+rate_limit_control_plane_rpaas_top_offender_excess{key="key-ccc",rpaas_instance="test-rpaas",zone="zone-two"} 5
+`
+		err = testutil.CollectAndCompare(collector, strings.NewReader(expected), "rate_limit_control_plane_rpaas_top_offender_excess")
+		assert.NoError(t, err)
+	})
+
+	t.Run("should drop the series of removed zones and instances", func(t *testing.T) {
+		r, ch := NewRpaasZoneDataRepository()
+		collector, err := NewTopOffendersCollector(r, 10, KeyModeRaw, 0)
+		require.NoError(t, err)
+		observer := &observerSpy{calls: make(chan []Data, 1), forgotten: make(chan string, 1)}
+		r.AddObserver(observer)
+		insertTopOffendersData(r)
+		assert.Equal(t, 8, testutil.CollectAndCount(collector))
+
+		ch <- ratelimit.RpaasZoneData{
+			RpaasName: "test-rpaas",
+			Data: []ratelimit.Zone{{
+				Name:             "zone-two",
+				RateLimitEntries: []ratelimit.RateLimitEntry{{Key: []byte("key-ccc-1"), Last: 1622547800000, Excess: 5}},
+			}},
+		}
+		<-observer.calls
+		assert.Equal(t, 2, testutil.CollectAndCount(collector))
+
+		ch <- ratelimit.RpaasZoneData{RpaasName: "test-rpaas", Removed: true}
+		assert.Equal(t, "test-rpaas", <-observer.forgotten)
+		assert.Equal(t, 0, testutil.CollectAndCount(collector))
+		_, exists := r.GetRpaasZoneData("test-rpaas")
+		assert.False(t, exists)
+		assert.Empty(t, r.ListInstances())
+	})
+
+	t.Run("should hash keys", func(t *testing.T) {
+		r, _ := NewRpaasZoneDataRepository()
+		collector, err := NewTopOffendersCollector(r, 10, KeyModeHash, 0)
+		require.NoError(t, err)
+		assert.Len(t, collector.formatKey("10.0.0.1"), hashedKeyLength)
+		assert.Equal(t, collector.formatKey("10.0.0.1"), collector.formatKey("10.0.0.1"))
+		assert.NotEqual(t, collector.formatKey("10.0.0.1"), collector.formatKey("10.0.0.2"))
+	})
+}
@@ -1,8 +1,11 @@
 package repository
 
 type Data struct {
-	Key    string `json:"key"`
-	Zone   string `json:"zone"`
-	Last   int64  `json:"last"`
-	Excess int64  `json:"excess"`
+	Key            string `json:"key"`
+	Zone           string `json:"zone"`
+	Last           int64  `json:"last"`
+	Excess         int64  `json:"excess"`
+	Country        string `json:"country,omitempty"`
+	ASN            uint   `json:"asn,omitempty"`
+	ASOrganization string `json:"asOrganization,omitempty"`
 }
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"bytes"
 	"encoding/json"
 	"log/slog"
 	"os"
@@ -13,10 +14,25 @@ import (
 	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
 )
 
+// Observer is notified with every key of an instance, not only the top
+// offenders, on each insert, and told to forget an instance once removed.
+type Observer interface {
+	Observe(instance string, data []Data)
+	Forget(instance string)
+}
+
 type ZoneDataRepository struct {
 	sync.Mutex
 	logger                *slog.Logger
 	Data                  map[string][]byte
+	snapshots             map[string][]Data
+	subnets               map[string][]SubnetData
+	origins               map[string]map[string][]OriginData
+	enricher              KeyEnricher
+	topPerZone            map[string]map[string][]Data
+	topPerZoneLimit       int
+	observers             []Observer
+	hub                   *Hub
 	readRpaasZoneDataChan chan ratelimit.RpaasZoneData
 }
 
@@ -26,6 +42,11 @@ func NewRpaasZoneDataRepository() (*ZoneDataRepository, chan ratelimit.RpaasZone
 	zoneRepository := &ZoneDataRepository{
 		logger:                repositoryLogger,
 		Data:                  make(map[string][]byte),
+		snapshots:             make(map[string][]Data),
+		subnets:               make(map[string][]SubnetData),
+		origins:               make(map[string]map[string][]OriginData),
+		topPerZone:            make(map[string]map[string][]Data),
+		hub:                   NewHub(),
 		readRpaasZoneDataChan: readRpaasZoneDataChan,
 	}
 	go zoneRepository.startReader()
@@ -33,18 +54,73 @@ func NewRpaasZoneDataRepository() (*ZoneDataRepository, chan ratelimit.RpaasZone
 	return zoneRepository, readRpaasZoneDataChan
 }
 
+func (z *ZoneDataRepository) AddObserver(observer Observer) {
+	z.Lock()
+	defer z.Unlock()
+	z.observers = append(z.observers, observer)
+}
+
+// SetEnricher enables looking up the origin of the keys on every insert.
+func (z *ZoneDataRepository) SetEnricher(enricher KeyEnricher) {
+	z.Lock()
+	defer z.Unlock()
+	z.enricher = enricher
+}
+
 func (z *ZoneDataRepository) startReader() {
 	for rpaasZoneData := range z.readRpaasZoneDataChan {
-		z.insert(rpaasZoneData)
+		if rpaasZoneData.Removed {
+			z.remove(rpaasZoneData.RpaasName)
+		} else {
+			allData := z.insert(rpaasZoneData)
+			z.notifyObservers(func(observer Observer) {
+				observer.Observe(rpaasZoneData.RpaasName, allData)
+			})
+		}
 	}
 }
 
-func (z *ZoneDataRepository) insert(rpaasZoneData ratelimit.RpaasZoneData) {
+func (z *ZoneDataRepository) notifyObservers(f func(Observer)) {
 	z.Lock()
-	defer z.Unlock()
+	observers := z.observers
+	z.Unlock()
+	for _, observer := range observers {
+		f(observer)
+	}
+}
+
+// remove drops the data of an instance whose worker was removed, so its
+// top offenders aren't exported with their last values forever.
+func (z *ZoneDataRepository) remove(rpaasName string) {
+	z.Lock()
+	delete(z.Data, rpaasName)
+	delete(z.snapshots, rpaasName)
+	delete(z.subnets, rpaasName)
+	delete(z.origins, rpaasName)
+	delete(z.topPerZone, rpaasName)
+	z.Unlock()
+	z.notifyObservers(func(observer Observer) {
+		observer.Forget(rpaasName)
+	})
+}
+
+// insert only holds the lock to store the results, the repository has a single
+// writer and the readers shouldn't wait for the grouping and the GeoIP lookups.
+func (z *ZoneDataRepository) insert(rpaasZoneData ratelimit.RpaasZoneData) []Data {
+	z.Lock()
+	enricher := z.enricher
+	topPerZoneLimit := z.topPerZoneLimit
+	z.Unlock()
 
+	cfg := config.Get()
+	maxTopOffenders := cfg.MaxTopOffendersReport
+	if rpaasZoneData.MaxTopOffenders > 0 {
+		maxTopOffenders = rpaasZoneData.MaxTopOffenders
+	}
 	serverData := []Data{}
+	topPerZone := make(map[string][]Data)
 	for _, zone := range rpaasZoneData.Data {
+		zoneStart := len(serverData)
 		for _, entry := range zone.RateLimitEntries {
 			serverData = append(serverData, Data{
 				Key:    entry.Key.String(zone.RateLimitHeader),
@@ -53,19 +129,74 @@ func (z *ZoneDataRepository) insert(rpaasZoneData ratelimit.RpaasZoneData) {
 				Excess: entry.Excess,
 			})
 		}
+		if topPerZoneLimit > 0 {
+			zoneData := make([]Data, len(serverData)-zoneStart)
+			copy(zoneData, serverData[zoneStart:])
+			topPerZone[zone.Name] = TopKByExcess(zoneData, topPerZoneLimit)
+		}
+	}
+	allData := serverData
+	var subnets []SubnetData
+	if cfg.PrefixGroupingEnabled {
+		subnets = GroupByPrefix(allData, cfg.PrefixGroupingIPv4Length, cfg.PrefixGroupingIPv6Length, cfg.PrefixGroupingEnforce)
+		if len(subnets) > maxTopOffenders {
+			subnets = subnets[:maxTopOffenders]
+		}
+	}
+	serverData = TopKByExcess(serverData, maxTopOffenders)
+	var origins map[string][]OriginData
+	if enricher != nil {
+		// Only the reported offenders are looked up, so origins group them and not every key
+		Enrich(serverData, enricher)
+		origins = make(map[string][]OriginData, len(OriginDimensions))
+		for _, dimension := range OriginDimensions {
+			grouped := GroupByOrigin(serverData, dimension)
+			if len(grouped) > maxTopOffenders {
+				grouped = grouped[:maxTopOffenders]
+			}
+			origins[dimension] = grouped
+		}
 	}
-	serverData = TopKByExcess(serverData, config.Spec.MaxTopOffendersReport)
 	dataBytes, err := json.MarshalIndent(serverData, "  ", "  ")
 	if err != nil {
 		z.logger.Error("Error marshaling JSON", "error", err)
-		return
+		return allData
+	}
+
+	// The data of the instance is replaced as a whole, dropping zones no longer reported
+	z.Lock()
+	if topPerZoneLimit > 0 {
+		z.topPerZone[rpaasZoneData.RpaasName] = topPerZone
+	} else {
+		delete(z.topPerZone, rpaasZoneData.RpaasName)
 	}
+	if origins != nil {
+		z.origins[rpaasZoneData.RpaasName] = origins
+	} else {
+		delete(z.origins, rpaasZoneData.RpaasName)
+	}
+	if cfg.PrefixGroupingEnabled {
+		z.subnets[rpaasZoneData.RpaasName] = subnets
+	} else {
+		delete(z.subnets, rpaasZoneData.RpaasName)
+	}
+	previous, exists := z.Data[rpaasZoneData.RpaasName]
 	z.Data[rpaasZoneData.RpaasName] = dataBytes
+	z.snapshots[rpaasZoneData.RpaasName] = serverData
+	z.Unlock()
+	if !exists || !bytes.Equal(previous, dataBytes) {
+		z.hub.Publish(Update{
+			Instance: rpaasZoneData.RpaasName,
+			Data:     serverData,
+			Raw:      dataBytes,
+		})
+	}
 
 	// Update repository memory usage metric
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 	manager.GetZoneDataRepositoryMemoryGauge().Set(float64(memStats.HeapInuse))
+	return allData
 }
 
 func (z *ZoneDataRepository) GetRpaasZoneData(rpaasName string) ([]byte, bool) {
@@ -75,6 +206,44 @@ func (z *ZoneDataRepository) GetRpaasZoneData(rpaasName string) ([]byte, bool) {
 	return dataBytes, exists
 }
 
+// GetRpaasZoneSnapshot returns the current top offenders of an instance.
+func (z *ZoneDataRepository) GetRpaasZoneSnapshot(rpaasName string) ([]Data, []byte, bool) {
+	z.Lock()
+	defer z.Unlock()
+	dataBytes, exists := z.Data[rpaasName]
+	return z.snapshots[rpaasName], dataBytes, exists
+}
+
+// GetRpaasSubnetData returns the top offending networks of an instance, it is
+// only filled when prefix grouping is enabled.
+func (z *ZoneDataRepository) GetRpaasSubnetData(rpaasName string) ([]SubnetData, bool) {
+	z.Lock()
+	defer z.Unlock()
+	subnets, exists := z.subnets[rpaasName]
+	return subnets, exists
+}
+
+// GetRpaasOriginData returns the top offending countries or ASNs of an
+// instance, it is only filled when an enricher is set.
+func (z *ZoneDataRepository) GetRpaasOriginData(rpaasName, dimension string) ([]OriginData, bool) {
+	z.Lock()
+	defer z.Unlock()
+	origins, exists := z.origins[rpaasName]
+	if !exists {
+		return nil, false
+	}
+	return origins[dimension], true
+}
+
+// Subscribe registers for updates of an instance, they are only published when its data changes.
+func (z *ZoneDataRepository) Subscribe(rpaasName string) *Subscription {
+	return z.hub.Subscribe(rpaasName)
+}
+
+func (z *ZoneDataRepository) Unsubscribe(sub *Subscription) {
+	z.hub.Unsubscribe(sub)
+}
+
 func (z *ZoneDataRepository) ListInstances() []string {
 	z.Lock()
 	defer z.Unlock()
@@ -345,4 +345,73 @@ func TestNewRpaasZoneDataRepository(t *testing.T) {
 		assert.Contains(rpaasZoneData, Data{Key: "test-key-three", Zone: "test-zone-two", Last: 1622547800, Excess: 10})
 		assert.Contains(rpaasZoneData, Data{Key: "test-key-two", Zone: "test-zone-one", Last: 1622547803, Excess: 5})
 	})
+
+	t.Run("should publish updates only when data changes", func(t *testing.T) {
+		assert := assert.New(t)
+		r, _ := NewRpaasZoneDataRepository()
+		sub := r.Subscribe("test-rpaas")
+		defer r.Unsubscribe(sub)
+		zoneData := ratelimit.RpaasZoneData{
+			RpaasName: "test-rpaas",
+			Data: []ratelimit.Zone{{
+				Name: "test-zone",
+				RateLimitEntries: []ratelimit.RateLimitEntry{
+					{
+						Key:    []byte("test-key"),
+						Last:   1622547800,
+						Excess: 10,
+					},
+				},
+			}},
+		}
+		r.insert(zoneData)
+		update := <-sub.C
+		assert.Equal("test-rpaas", update.Instance)
+		assert.Equal([]Data{{Key: "test-key", Zone: "test-zone", Last: 1622547800, Excess: 10}}, update.Data)
+
+		r.insert(zoneData)
+		assert.Len(sub.C, 0)
+
+		zoneData.Data[0].RateLimitEntries[0].Excess = 20
+		r.insert(zoneData)
+		update = <-sub.C
+		assert.Equal(int64(20), update.Data[0].Excess)
+		data, raw, ok := r.GetRpaasZoneSnapshot("test-rpaas")
+		assert.True(ok)
+		assert.Equal(update.Data, data)
+		assert.Equal(update.Raw, raw)
+	})
+
+	t.Run("should notify observers with every key", func(t *testing.T) {
+		assert := assert.New(t)
+		r, ch := NewRpaasZoneDataRepository()
+		observer := &observerSpy{calls: make(chan []Data, 1)}
+		r.AddObserver(observer)
+		entries := []ratelimit.RateLimitEntry{}
+		for i := 0; i < 3; i++ {
+			entries = append(entries, ratelimit.RateLimitEntry{Key: []byte{'k', byte('0' + i)}, Last: 1622547800, Excess: int64(i)})
+		}
+		ch <- ratelimit.RpaasZoneData{
+			RpaasName: "test-rpaas",
+			Data:      []ratelimit.Zone{{Name: "test-zone", RateLimitEntries: entries}},
+		}
+		data := <-observer.calls
+		assert.Len(data, 3)
+		assert.Equal("test-rpaas", observer.instance)
+	})
+}
+
+type observerSpy struct {
+	instance  string
+	calls     chan []Data
+	forgotten chan string
+}
+
+func (o *observerSpy) Observe(instance string, data []Data) {
+	o.instance = instance
+	o.calls <- data
+}
+
+func (o *observerSpy) Forget(instance string) {
+	o.forgotten <- instance
 }
@@ -0,0 +1,290 @@
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+// idleEntryTTL is how long nginx keeps an entry without requests before evicting it.
+const idleEntryTTL = time.Minute
+
+type ZoneSettings struct {
+	Name string
+	// Key is the variable of the limit_req_zone, $remote_addr or $binary_remote_addr.
+	Key string
+	// Rate is the allowed requests per second of each key.
+	Rate float64
+	// Burst is how many requests above the rate are delayed instead of rejected.
+	Burst int64
+}
+
+type Faults struct {
+	// Latency is added to every admin request.
+	Latency time.Duration
+	// ErrorRate is the fraction of admin requests answered with an internal server error.
+	ErrorRate float64
+	// RestartInterval is the mean time between restarts, which drop every zone. Zero disables them.
+	RestartInterval time.Duration
+	// ClockSkew moves the wall clock of the pods.
+	ClockSkew time.Duration
+}
+
+type zone struct {
+	settings ZoneSettings
+	entries  map[string]*ratelimit.RateLimitEntry
+}
+
+// Pod is a fake nginx pod, serving the rate limit zones on its admin endpoint.
+type Pod struct {
+	Name string
+	URL  string
+
+	mu sync.Mutex
+	// boot is the start of the monotonic clock, nginx counts it from an arbitrary point
+	boot     time.Time
+	zones    map[string]*zone
+	restarts int
+	faults   Faults
+	rand     *rand.Rand
+	listener net.Listener
+	app      *fiber.App
+	now      func() time.Time
+}
+
+func newPod(name string, listener net.Listener, zones []ZoneSettings, faults Faults, seed int64) *Pod {
+	p := &Pod{
+		Name:     name,
+		URL:      fmt.Sprintf("http://%s", listener.Addr().String()),
+		faults:   faults,
+		rand:     rand.New(rand.NewSource(seed)),
+		listener: listener,
+		now:      time.Now,
+	}
+	p.boot = p.now().Add(-time.Duration(p.rand.Int63n(int64(24 * time.Hour))))
+	p.zones = make(map[string]*zone, len(zones))
+	for _, settings := range zones {
+		p.zones[settings.Name] = &zone{settings: settings, entries: make(map[string]*ratelimit.RateLimitEntry)}
+	}
+
+	p.app = fiber.New(fiber.Config{DisableStartupMessage: true})
+	p.app.Use(p.injectFaults)
+	p.app.Get("/rate-limit", p.listZones)
+	p.app.Get("/rate-limit/:zone", p.readZone)
+	p.app.Post("/rate-limit/:zone", p.writeZone)
+	return p
+}
+
+func (p *Pod) serve() error {
+	return p.app.Listener(p.listener)
+}
+
+func (p *Pod) shutdown() error {
+	return p.app.Shutdown()
+}
+
+// header must be called with mu held.
+func (p *Pod) header(settings ZoneSettings) ratelimit.RateLimitHeader {
+	now := p.now()
+	return ratelimit.RateLimitHeader{
+		Key:          settings.Key,
+		Now:          now.Add(p.faults.ClockSkew).UnixMilli(),
+		NowMonotonic: now.Sub(p.boot).Milliseconds(),
+	}
+}
+
+// Request applies a request of key to a zone with the leaky bucket of
+// limit_req, returning whether it was allowed. Excess is kept in thousandths
+// of a request as nginx does.
+func (p *Pod) Request(zoneName, key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	z, ok := p.zones[zoneName]
+	if !ok {
+		return false
+	}
+	now := p.header(z.settings).NowMonotonic
+	entry, exists := z.entries[key]
+	if !exists {
+		parsed, err := ratelimit.ParseKey(key, ratelimit.RateLimitHeader{Key: z.settings.Key})
+		if err != nil {
+			return false
+		}
+		z.entries[key] = &ratelimit.RateLimitEntry{Key: parsed, Last: now}
+		return true
+	}
+	excess := leak(entry.Excess, now-entry.Last, z.settings.Rate) + 1000
+	if excess > z.settings.Burst*1000 {
+		return false
+	}
+	entry.Excess = excess
+	entry.Last = now
+	return true
+}
+
+// leak drains the excess for elapsed milliseconds at rate requests per second.
+func leak(excess, elapsed int64, rate float64) int64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	excess -= int64(rate * float64(elapsed))
+	if excess < 0 {
+		return 0
+	}
+	return excess
+}
+
+// Restart drops every zone and starts the monotonic clock over, as a new nginx process would.
+func (p *Pod) Restart() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.boot = p.now()
+	for _, z := range p.zones {
+		z.entries = make(map[string]*ratelimit.RateLimitEntry)
+	}
+	p.restarts++
+}
+
+// Restarts returns how many times the pod was restarted.
+func (p *Pod) Restarts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.restarts
+}
+
+// Entries returns the entries of a zone with Last in the pod monotonic clock.
+func (p *Pod) Entries(zoneName string) []ratelimit.RateLimitEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	z, ok := p.zones[zoneName]
+	if !ok {
+		return nil
+	}
+	entries := make([]ratelimit.RateLimitEntry, 0, len(z.entries))
+	for _, entry := range z.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Key, entries[j].Key) < 0
+	})
+	return entries
+}
+
+func (p *Pod) evictIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, z := range p.zones {
+		now := p.header(z.settings).NowMonotonic
+		for key, entry := range z.entries {
+			if now-entry.Last > idleEntryTTL.Milliseconds() {
+				delete(z.entries, key)
+			}
+		}
+	}
+}
+
+func (p *Pod) injectFaults(c *fiber.Ctx) error {
+	p.mu.Lock()
+	latency := p.faults.Latency
+	fail := p.faults.ErrorRate > 0 && p.rand.Float64() < p.faults.ErrorRate
+	p.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if fail {
+		return c.Status(fiber.StatusInternalServerError).SendString("Injected error")
+	}
+	return c.Next()
+}
+
+func (p *Pod) listZones(c *fiber.Ctx) error {
+	p.mu.Lock()
+	names := make([]string, 0, len(p.zones))
+	for name := range p.zones {
+		names = append(names, name)
+	}
+	p.mu.Unlock()
+	sort.Strings(names)
+	encoded, err := msgpack.Marshal(names)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to encode zones")
+	}
+	c.Set(fiber.HeaderContentType, "application/x-msgpack")
+	return c.Send(encoded)
+}
+
+// readZone streams the header followed by one array per entry, the ones
+// with Last before last_greater_equal left out.
+func (p *Pod) readZone(c *fiber.Ctx) error {
+	lastGreaterEqual := int64(c.QueryInt("last_greater_equal", 0))
+	p.mu.Lock()
+	z, ok := p.zones[c.Params("zone")]
+	if !ok {
+		p.mu.Unlock()
+		return c.Status(fiber.StatusNotFound).SendString("Zone not found")
+	}
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	header := p.header(z.settings)
+	err := encoder.Encode([]any{header.Key, header.Now, header.NowMonotonic})
+	for _, entry := range z.entries {
+		if err != nil {
+			break
+		}
+		if entry.Last < lastGreaterEqual {
+			continue
+		}
+		err = encoder.Encode([]any{[]byte(entry.Key), entry.Last, entry.Excess})
+	}
+	p.mu.Unlock()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to encode zone")
+	}
+	c.Set(fiber.HeaderContentType, "application/x-msgpack")
+	return c.Send(buf.Bytes())
+}
+
+// writeZone takes a single array, the header and then the entries, and
+// replaces the entries it has. Their Last is in the monotonic clock of the header.
+func (p *Pod) writeZone(c *fiber.Ctx) error {
+	var values []msgpack.RawMessage
+	if err := msgpack.Unmarshal(c.Body(), &values); err != nil || len(values) == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid body")
+	}
+	var header ratelimit.RateLimitHeader
+	if err := msgpack.Unmarshal(values[0], &header); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid header")
+	}
+	entries := make([]ratelimit.RateLimitEntry, 0, len(values)-1)
+	for _, value := range values[1:] {
+		var entry ratelimit.RateLimitEntry
+		if err := msgpack.Unmarshal(value, &entry); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid entry")
+		}
+		entries = append(entries, entry)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	z, ok := p.zones[c.Params("zone")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).SendString("Zone not found")
+	}
+	if header.Key != z.settings.Key {
+		return c.Status(fiber.StatusBadRequest).SendString("Header key doesn't match the zone key")
+	}
+	for i := range entries {
+		entry := entries[i]
+		z.entries[entry.Key.String(header)] = &entry
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,148 @@
+// Package simulator runs fake nginx pods implementing the rate limit admin
+// protocol, with traffic flowing through them, for local development and tests.
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const tickInterval = 10 * time.Millisecond
+
+type Settings struct {
+	Pods int
+	// Address is the host the pods listen on.
+	Address string
+	// BasePort is the port of the first pod, the next ones listen on the following ports. Zero picks random ports.
+	BasePort int
+	Zones    []ZoneSettings
+	Traffic  TrafficSettings
+	Faults   Faults
+	// Seed makes the traffic and faults reproducible.
+	Seed int64
+}
+
+func (s Settings) Validate() error {
+	var errs []error
+	if s.Pods <= 0 {
+		errs = append(errs, errors.New("pods must be positive"))
+	}
+	if len(s.Zones) == 0 {
+		errs = append(errs, errors.New("at least one zone is required"))
+	}
+	for _, zone := range s.Zones {
+		if zone.Name == "" || zone.Rate <= 0 || zone.Burst < 0 {
+			errs = append(errs, fmt.Errorf("zone %q must have a name, a positive rate and a burst not negative", zone.Name))
+		}
+	}
+	if err := s.Traffic.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Faults.ErrorRate < 0 || s.Faults.ErrorRate > 1 {
+		errs = append(errs, errors.New("error rate must be between 0 and 1"))
+	}
+	return errors.Join(errs...)
+}
+
+// Fleet is a set of fake nginx pods behind a load balancer spreading the traffic between them.
+type Fleet struct {
+	Pods []*Pod
+
+	settings Settings
+	logger   *slog.Logger
+	rand     *rand.Rand
+	wg       sync.WaitGroup
+}
+
+// NewFleet listens on the ports of the pods, they only serve once the fleet is started.
+func NewFleet(settings Settings, logger *slog.Logger) (*Fleet, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	if settings.Address == "" {
+		settings.Address = "127.0.0.1"
+	}
+	f := &Fleet{
+		settings: settings,
+		logger:   logger,
+		rand:     rand.New(rand.NewSource(settings.Seed)),
+	}
+	for i := range settings.Pods {
+		port := 0
+		if settings.BasePort != 0 {
+			port = settings.BasePort + i
+		}
+		listener, err := net.Listen("tcp", net.JoinHostPort(settings.Address, fmt.Sprint(port)))
+		if err != nil {
+			for _, pod := range f.Pods {
+				pod.listener.Close()
+			}
+			return nil, fmt.Errorf("error listening for pod %d: %w", i, err)
+		}
+		f.Pods = append(f.Pods, newPod(fmt.Sprintf("nginx-%d", i), listener, settings.Zones, settings.Faults, f.rand.Int63()))
+	}
+	return f, nil
+}
+
+// Run serves the pods and sends traffic to them until ctx is done.
+func (f *Fleet) Run(ctx context.Context) error {
+	errs := make(chan error, len(f.Pods))
+	for _, pod := range f.Pods {
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			if err := pod.serve(); err != nil {
+				errs <- fmt.Errorf("error serving pod %s: %w", pod.Name, err)
+			}
+		}()
+	}
+
+	traffic := newTraffic(f.settings.Traffic, f.rand.Int63(), time.Now())
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	var err error
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case err = <-errs:
+			break loop
+		case now := <-ticker.C:
+			f.tick(traffic, now, now.Sub(last))
+			last = now
+		}
+	}
+	for _, pod := range f.Pods {
+		if shutdownErr := pod.shutdown(); shutdownErr != nil {
+			f.logger.Error("Error shutting down pod", "pod", pod.Name, "error", shutdownErr)
+		}
+	}
+	f.wg.Wait()
+	return err
+}
+
+func (f *Fleet) tick(traffic *traffic, now time.Time, elapsed time.Duration) {
+	for _, key := range traffic.requests(now, elapsed) {
+		pod := f.Pods[f.rand.Intn(len(f.Pods))]
+		for _, zone := range f.settings.Zones {
+			pod.Request(zone.Name, key)
+		}
+	}
+	restartInterval := f.settings.Faults.RestartInterval
+	for _, pod := range f.Pods {
+		pod.evictIdle()
+		// Restarts are a Poisson process with the configured mean interval
+		if restartInterval > 0 && f.rand.Float64() < float64(elapsed)/float64(restartInterval) {
+			f.logger.Info("Restarting pod", "pod", pod.Name)
+			pod.Restart()
+		}
+	}
+}
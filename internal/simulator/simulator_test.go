@@ -0,0 +1,146 @@
+package simulator
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/manager"
+	"github.com/tsuru/rate-limit-control-plane/internal/ratelimit"
+)
+
+var testZone = ZoneSettings{Name: "one", Key: ratelimit.RemoteAddress, Rate: 10, Burst: 2}
+
+func newTestPod(t *testing.T, now *time.Time) *Pod {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	pod := newPod("nginx-0", listener, []ZoneSettings{testZone}, Faults{}, 1)
+	pod.now = func() time.Time { return *now }
+	return pod
+}
+
+func TestPodLeakyBucket(t *testing.T) {
+	now := time.Now()
+	pod := newTestPod(t, &now)
+
+	// The first request leaves no excess, the next ones up to the burst are allowed
+	assert.True(t, pod.Request("one", "10.0.0.1"))
+	assert.True(t, pod.Request("one", "10.0.0.1"))
+	assert.True(t, pod.Request("one", "10.0.0.1"))
+	assert.False(t, pod.Request("one", "10.0.0.1"))
+	assert.Equal(t, int64(2000), pod.Entries("one")[0].Excess)
+
+	// 10r/s drains a request every 100ms
+	now = now.Add(150 * time.Millisecond)
+	assert.True(t, pod.Request("one", "10.0.0.1"))
+	assert.Equal(t, int64(1500), pod.Entries("one")[0].Excess)
+
+	assert.False(t, pod.Request("unknown", "10.0.0.1"))
+}
+
+func TestPodRestart(t *testing.T) {
+	now := time.Now()
+	pod := newTestPod(t, &now)
+	pod.Request("one", "10.0.0.1")
+	before := pod.header(testZone)
+
+	now = now.Add(time.Second)
+	pod.Restart()
+	after := pod.header(testZone)
+	assert.Less(t, after.NowMonotonic, before.NowMonotonic)
+	assert.Empty(t, pod.Entries("one"))
+	assert.Equal(t, 1, pod.Restarts())
+
+	// Idle entries are evicted
+	pod.Request("one", "10.0.0.1")
+	now = now.Add(idleEntryTTL + time.Second)
+	pod.evictIdle()
+	assert.Empty(t, pod.Entries("one"))
+}
+
+func TestTrafficPatterns(t *testing.T) {
+	started := time.Now()
+	settings := TrafficSettings{Pattern: PatternAbuser, Clients: 2, ClientRate: 10, BurstFactor: 10}
+	keys := newTraffic(settings, 1, started).requests(started, time.Second)
+	counts := map[string]int{}
+	for _, key := range keys {
+		counts[key]++
+	}
+	assert.Equal(t, map[string]int{"10.0.0.0": 10, "10.0.0.1": 10, AbuserKey: 100}, counts)
+
+	settings = TrafficSettings{Pattern: PatternSpray, SprayRate: 50}
+	spray := newTraffic(settings, 1, started)
+	seen := map[string]bool{}
+	for range 2 {
+		for _, key := range spray.requests(started, time.Second) {
+			assert.False(t, seen[key], key)
+			seen[key] = true
+		}
+	}
+	assert.Len(t, seen, 100)
+
+	settings = TrafficSettings{Pattern: PatternBurst, Clients: 1, ClientRate: 10, BurstFactor: 5, BurstPeriod: time.Minute}
+	burst := newTraffic(settings, 1, started)
+	assert.Len(t, burst.requests(started, time.Second), 50)
+	assert.Len(t, burst.requests(started.Add(30*time.Second), time.Second), 10)
+
+	assert.Error(t, TrafficSettings{Pattern: "unknown"}.Validate())
+}
+
+func TestFleetWithPodWorker(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	fleet, err := NewFleet(Settings{
+		Pods:    1,
+		Zones:   []ZoneSettings{testZone},
+		Traffic: TrafficSettings{Pattern: PatternSteady},
+	}, logger)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- fleet.Run(ctx) }()
+	defer func() {
+		cancel()
+		require.NoError(t, <-done)
+	}()
+
+	pod := fleet.Pods[0]
+	pod.Request("one", "10.0.0.1")
+	pod.Request("one", "10.0.0.1")
+
+	zoneDataChan := make(chan manager.Optional[ratelimit.Zone])
+	worker := manager.NewRpaasPodWorker(manager.RpaasPodData{Name: pod.Name, URL: pod.URL}, manager.RpaasInstanceData{Instance: "instance", Service: "service"}, logger, zoneDataChan)
+	worker.Start(context.Background())
+	defer worker.Stop()
+
+	// Reads go through the real client, retried until the pod serves
+	var zone ratelimit.Zone
+	require.Eventually(t, func() bool {
+		worker.ReadZoneChan <- "one"
+		result := <-zoneDataChan
+		zone = result.Value
+		return result.Error == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Len(t, zone.RateLimitEntries, 1)
+	assert.Equal(t, "10.0.0.1", string(zone.RateLimitEntries[0].Key))
+	assert.Equal(t, int64(1000), zone.RateLimitEntries[0].Excess)
+
+	// Writes replace the entries of the pod
+	zone.RateLimitEntries = append(zone.RateLimitEntries, ratelimit.RateLimitEntry{Key: ratelimit.Key("10.0.0.2"), Last: zone.RateLimitHeader.Now, Excess: 1500})
+	zone.RateLimitEntries[0].Excess = 2000
+	worker.WriteZoneChan <- zone
+	require.Eventually(t, func() bool {
+		return len(pod.Entries("one")) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	entries := pod.Entries("one")
+	assert.Equal(t, int64(2000), entries[0].Excess)
+	assert.Equal(t, int64(1500), entries[1].Excess)
+	assert.InDelta(t, pod.header(testZone).NowMonotonic, entries[1].Last, 1000, "Last goes back to the pod monotonic clock")
+}
@@ -0,0 +1,118 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+)
+
+const (
+	PatternSteady = "steady"
+	PatternBurst  = "burst"
+	PatternSpray  = "spray"
+	PatternAbuser = "abuser"
+)
+
+var Patterns = []string{PatternSteady, PatternBurst, PatternSpray, PatternAbuser}
+
+type TrafficSettings struct {
+	Pattern string
+	// Clients is how many keys make requests in the background of every pattern.
+	Clients int
+	// ClientRate is the requests per second of each client.
+	ClientRate float64
+	// BurstFactor multiplies the client rate during the bursts of the burst
+	// pattern and gives the rate of the abuser relative to a client.
+	BurstFactor float64
+	// BurstPeriod is how often the burst pattern bursts, for a tenth of the period.
+	BurstPeriod time.Duration
+	// SprayRate is how many new keys per second the spray pattern makes requests from.
+	SprayRate float64
+}
+
+func (s TrafficSettings) Validate() error {
+	switch s.Pattern {
+	case PatternSteady, PatternBurst, PatternSpray, PatternAbuser:
+	default:
+		return fmt.Errorf("unknown traffic pattern %q", s.Pattern)
+	}
+	if s.Clients < 0 || s.ClientRate < 0 || s.SprayRate < 0 {
+		return fmt.Errorf("clients, client rate and spray rate must not be negative")
+	}
+	if s.Pattern == PatternBurst && s.BurstPeriod <= 0 {
+		return fmt.Errorf("burst period must be positive")
+	}
+	return nil
+}
+
+// traffic turns the settings into the keys making requests on each tick.
+type traffic struct {
+	settings TrafficSettings
+	rand     *rand.Rand
+	started  time.Time
+	sprayed  uint32
+}
+
+func newTraffic(settings TrafficSettings, seed int64, started time.Time) *traffic {
+	return &traffic{settings: settings, rand: rand.New(rand.NewSource(seed)), started: started}
+}
+
+// requests returns the keys making a request during elapsed, the time since the previous tick.
+func (t *traffic) requests(now time.Time, elapsed time.Duration) []string {
+	rate := t.settings.ClientRate
+	if t.settings.Pattern == PatternBurst {
+		period := t.settings.BurstPeriod
+		if now.Sub(t.started)%period < period/10 {
+			rate *= t.settings.BurstFactor
+		}
+	}
+
+	var keys []string
+	for i := 0; i < t.settings.Clients; i++ {
+		for range t.count(rate, elapsed) {
+			keys = append(keys, clientKey(uint32(i)))
+		}
+	}
+	switch t.settings.Pattern {
+	case PatternSpray:
+		// Every request comes from a key never seen before, as a botnet rotating addresses
+		for range t.count(t.settings.SprayRate, elapsed) {
+			t.sprayed++
+			keys = append(keys, sprayKey(t.sprayed))
+		}
+	case PatternAbuser:
+		for range t.count(t.settings.ClientRate*t.settings.BurstFactor, elapsed) {
+			keys = append(keys, AbuserKey)
+		}
+	}
+	t.rand.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	return keys
+}
+
+// count draws how many requests happen in elapsed at rate per second, keeping the mean for small values.
+func (t *traffic) count(rate float64, elapsed time.Duration) int {
+	expected := rate * elapsed.Seconds()
+	n := int(expected)
+	if t.rand.Float64() < expected-float64(n) {
+		n++
+	}
+	return n
+}
+
+// AbuserKey is the key of the single abuser pattern.
+const AbuserKey = "203.0.113.66"
+
+func clientKey(i uint32) string {
+	return uint32IP(10<<24 | i).String()
+}
+
+func sprayKey(i uint32) string {
+	return uint32IP(100<<24 | i).String()
+}
+
+func uint32IP(v uint32) net.IP {
+	return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -7,7 +7,9 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
+	"time"
 
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
 	nginxOperatorv1alpha1 "github.com/tsuru/nginx-operator/api/v1alpha1"
@@ -15,18 +17,29 @@ import (
 	"go.uber.org/zap/zapcore"
 	"k8s.io/api/node/v1alpha1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/tsuru/rate-limit-control-plane/controllers"
+	"github.com/tsuru/rate-limit-control-plane/internal/alerting"
+	"github.com/tsuru/rate-limit-control-plane/internal/anomaly"
+	"github.com/tsuru/rate-limit-control-plane/internal/auth"
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+	"github.com/tsuru/rate-limit-control-plane/internal/geoip"
+	"github.com/tsuru/rate-limit-control-plane/internal/logger"
 	"github.com/tsuru/rate-limit-control-plane/internal/manager"
+	"github.com/tsuru/rate-limit-control-plane/internal/recorder"
 	"github.com/tsuru/rate-limit-control-plane/internal/repository"
 	"github.com/tsuru/rate-limit-control-plane/server"
 )
 
+const alertWebhookQueueSize = 1000
+
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -52,14 +65,14 @@ func (o *configOpts) bindFlags(fs *flag.FlagSet) {
 }
 
 type InternalAPIServer struct {
-	repo         *repository.ZoneDataRepository
+	deps         server.Dependencies
 	internalAddr string
 }
 
 func (s *InternalAPIServer) Start(ctx context.Context) error {
 	setupLog.Info("leadership acquired, starting internalapi", "addr", s.internalAddr)
 	go func() {
-		server.Notification(s.repo, s.internalAddr)
+		server.Notification(s.deps, s.internalAddr)
 	}()
 	<-ctx.Done()
 	return nil
@@ -84,7 +97,61 @@ func main() {
 	zapOpts.Level = zapcore.WarnLevel
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
 
+	if err := config.Spec.Validate(); err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+	ctx := ctrl.SetupSignalHandler()
+	go func() {
+		configLogger := logger.NewLogger(map[string]string{"emitter": "rate-limit-control-plane-config"}, os.Stdout)
+		if err := config.Watch(ctx, configLogger); err != nil {
+			configLogger.Error("Error watching config file - reloading is disabled", "error", err)
+		}
+	}()
+
 	repo, ch := repository.NewRpaasZoneDataRepository()
+	if len(config.Spec.GeoIPDatabaseFiles) > 0 {
+		geoipDatabase, err := geoip.NewDatabase(
+			logger.NewLogger(map[string]string{"emitter": "rate-limit-control-plane-geoip"}, os.Stdout),
+			config.Spec.GeoIPDatabaseFiles,
+			config.Spec.GeoIPReloadInterval,
+		)
+		if err != nil {
+			setupLog.Error(err, "unable to load geoip databases")
+			os.Exit(1)
+		}
+		repo.SetEnricher(geoipDatabase)
+	}
+	if config.Spec.TopOffendersMetricsEnabled {
+		collector, err := repository.NewTopOffendersCollector(repo, config.Spec.TopOffendersMetricsLimit, config.Spec.TopOffendersMetricsKeyMode, config.Spec.TopOffendersMetricsKeyLength)
+		if err != nil {
+			setupLog.Error(err, "unable to set up top offenders metrics")
+			os.Exit(1)
+		}
+		metrics.Registry.MustRegister(collector)
+	}
+	if config.Spec.AlertRulesFile != "" {
+		rules, err := alerting.LoadRules(config.Spec.AlertRulesFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load alerting rules")
+			os.Exit(1)
+		}
+		notifier := alerting.NewWebhookNotifier(
+			logger.NewLogger(map[string]string{"emitter": "rate-limit-control-plane-alerting"}, os.Stdout),
+			config.Spec.AlertWebhookTimeout,
+			alertWebhookQueueSize,
+		)
+		repo.AddObserver(alerting.NewEvaluator(rules, notifier))
+	}
+	if config.Spec.RecorderEnabled {
+		zoneRecorder, err := recorder.New(config.Spec.RecorderPath, config.Spec.RecorderMaxFileSize, config.Spec.RecorderMaxFiles, config.Spec.RecorderInstances)
+		if err != nil {
+			setupLog.Error(err, "unable to open zone recorder")
+			os.Exit(1)
+		}
+		defer zoneRecorder.Close()
+		manager.SetRecorder(zoneRecorder)
+	}
 
 	namespace := os.Getenv("NAMESPACE")
 	if namespace == "" {
@@ -93,6 +160,8 @@ func main() {
 		setupLog.Info("Running in namespace", "namespace", namespace)
 	}
 
+	// Leaves some time for the other runnables after the workers are done
+	gracefulShutdownTimeout := config.Spec.ShutdownTimeout + 5*time.Second
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                     scheme,
 		Namespace:                  namespace,
@@ -102,14 +171,58 @@ func main() {
 		LeaderElectionID:           opts.leaderElectionResourceName,
 		LeaderElectionNamespace:    namespace,
 		HealthProbeBindAddress:     opts.healthAddr,
+		GracefulShutdownTimeout:    &gracefulShutdownTimeout,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	authenticator, authorizer, writeAuthorizer, err := setupAuth(mgr, namespace)
+	if err != nil {
+		setupLog.Error(err, "unable to set up internal API authentication")
+		os.Exit(1)
+	}
+
+	overrides := manager.NewOverrideStore(
+		logger.NewLogger(map[string]string{"emitter": "rate-limit-control-plane-overrides"}, os.Stdout),
+		config.Spec.OverrideAuditSize,
+	)
+
+	allowlists := manager.NewAllowlistStore(config.Spec.AllowlistMaxExcess)
+
+	var detector *anomaly.Detector
+	if config.Spec.AnomalyDetectionEnabled {
+		detector = anomaly.NewDetector(anomaly.Settings{
+			Alpha:        config.Spec.AnomalyEWMAAlpha,
+			Threshold:    config.Spec.AnomalyZScoreThreshold,
+			WarmupRounds: config.Spec.AnomalyWarmupRounds,
+			MaxAnomalies: config.Spec.AnomalyMaxReported,
+		}, &anomaly.KubernetesEventSink{
+			Recorder: mgr.GetEventRecorderFor("rate-limit-control-plane"),
+			Resolver: &auth.KubernetesInstanceResolver{Client: mgr.GetClient(), Namespace: namespace},
+			Logger:   logger.NewLogger(map[string]string{"emitter": "rate-limit-control-plane-anomaly"}, os.Stdout),
+		})
+		repo.AddObserver(detector)
+	}
+
+	workers := manager.NewGoroutineManager()
+	if err := mgr.Add(workers); err != nil {
+		setupLog.Error(err, "unable to add sync workers")
+		os.Exit(1)
+	}
+
 	internalAPIServer := &InternalAPIServer{
-		repo:         repo,
+		deps: server.Dependencies{
+			Repo:            repo,
+			Authenticator:   authenticator,
+			Authorizer:      authorizer,
+			WriteAuthorizer: writeAuthorizer,
+			Overrides:       overrides,
+			Allowlists:      allowlists,
+			Anomalies:       detector,
+			Workers:         workers,
+		},
 		internalAddr: opts.internalAPIAddr,
 	}
 
@@ -124,19 +237,87 @@ func main() {
 		os.Exit(1)
 	}
 
-	manager := manager.NewGoroutineManager()
+	zoneFilter, err := manager.NewZoneFilter(config.Spec.ZoneInclude, config.Spec.ZoneExclude)
+	if err != nil {
+		setupLog.Error(err, "invalid zone filter")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.RateLimitControllerReconcile{
 		Client:           mgr.GetClient(),
 		Log:              mgr.GetLogger().WithName("controllers").WithName("RateLimitControllerReconcile"),
-		ManagerGoroutine: manager,
+		ManagerGoroutine: workers,
 		Notify:           ch,
+		Overrides:        overrides,
+		Allowlists:       allowlists,
+		Recorder:         mgr.GetEventRecorderFor("rate-limit-control-plane"),
+		ZoneFilter:       zoneFilter,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "RateLimitControllerReconcile")
 		os.Exit(1)
 	}
 
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// setupAuth returns the authenticator and the authorizers of reads and writes of the internal API.
+func setupAuth(mgr ctrl.Manager, namespace string) (auth.Authenticator, auth.Authorizer, auth.Authorizer, error) {
+	var authenticator auth.Authenticator
+	switch config.Spec.AuthMode {
+	case "none":
+		authenticator = auth.AllowAll{}
+	case "static":
+		staticAuthenticator, err := auth.NewStaticTokenAuthenticatorFromFile(config.Spec.AuthTokenFile)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		authenticator = staticAuthenticator
+	case "kubernetes":
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		authenticator = &auth.KubernetesAuthenticator{
+			Reviewer:  clientset.AuthenticationV1().TokenReviews(),
+			Audiences: config.Spec.AuthAudiences,
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown auth mode %q", config.Spec.AuthMode)
+	}
+
+	resolver := &auth.KubernetesInstanceResolver{Client: mgr.GetClient(), Namespace: namespace}
+	var authorizer, writeAuthorizer auth.Authorizer
+	switch config.Spec.AuthorizationMode {
+	case "none":
+		authorizer = auth.AllowAll{}
+		writeAuthorizer = auth.AllowAll{}
+	case "team":
+		// The team owning an instance manages it
+		authorizer = &auth.TeamAuthorizer{Resolver: resolver}
+		writeAuthorizer = authorizer
+	case "kubernetes":
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		authorizer = &auth.KubernetesAuthorizer{
+			Reviewer: clientset.AuthorizationV1().SubjectAccessReviews(),
+			Resolver: resolver,
+		}
+		writeAuthorizer = &auth.KubernetesAuthorizer{
+			Reviewer: clientset.AuthorizationV1().SubjectAccessReviews(),
+			Resolver: resolver,
+			Verb:     "update",
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown authorization mode %q", config.Spec.AuthorizationMode)
+	}
+	if len(config.Spec.AuthAdminGroups) > 0 {
+		authorizer = &auth.AdminGroupsAuthorizer{AdminGroups: config.Spec.AuthAdminGroups, Authorizer: authorizer}
+		writeAuthorizer = &auth.AdminGroupsAuthorizer{AdminGroups: config.Spec.AuthAdminGroups, Authorizer: writeAuthorizer}
+	}
+	return authenticator, authorizer, writeAuthorizer, nil
+}
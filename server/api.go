@@ -0,0 +1,248 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/anomaly"
+	"github.com/tsuru/rate-limit-control-plane/internal/config"
+	"github.com/tsuru/rate-limit-control-plane/internal/manager"
+	"github.com/tsuru/rate-limit-control-plane/internal/repository"
+)
+
+const (
+	overrideActionBlock = "block"
+	overrideActionReset = "reset"
+)
+
+type overrideRequest struct {
+	Zone   string `json:"zone"`
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	Excess int64  `json:"excess"`
+	TTL    string `json:"ttl"`
+	Reason string `json:"reason"`
+}
+
+type allowlistRequest struct {
+	Entry string `json:"entry"`
+}
+
+func registerAPIRoutes(app *fiber.App, deps Dependencies) {
+	api := app.Group("/api/v1")
+	instanceAPI := api.Group("/instances/:instance", authorizeInstance(deps.Authorizer, "instance"))
+	authorizeWrite := authorizeInstanceWrite(deps.WriteAuthorizer, "instance")
+
+	instanceAPI.Get("/overrides", func(c *fiber.Ctx) error {
+		return c.JSON(deps.Overrides.List(c.Params("instance")))
+	})
+
+	instanceAPI.Post("/overrides", authorizeWrite, func(c *fiber.Ctx) error {
+		var req overrideRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+		}
+		override, err := newOverride(c.Params("instance"), currentUser(c).Name, req, time.Now())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := deps.Overrides.Set(override); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusCreated).JSON(override)
+	})
+
+	instanceAPI.Delete("/overrides", authorizeWrite, func(c *fiber.Ctx) error {
+		if !deps.Overrides.Delete(c.Params("instance"), c.Query("zone"), c.Query("key"), currentUser(c).Name) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Override not found"})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	instanceAPI.Get("/allowlist", func(c *fiber.Ctx) error {
+		return c.JSON(deps.Allowlists.Get(c.Params("instance")))
+	})
+
+	instanceAPI.Post("/allowlist", authorizeWrite, func(c *fiber.Ctx) error {
+		var req allowlistRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+		}
+		instance := c.Params("instance")
+		if err := deps.Allowlists.Add(instance, req.Entry); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		serverLogger.Info("Allowlist entry added", "instance", instance, "entry", req.Entry, "user", currentUser(c).Name)
+		return c.Status(fiber.StatusCreated).JSON(deps.Allowlists.Get(instance))
+	})
+
+	instanceAPI.Delete("/allowlist", authorizeWrite, func(c *fiber.Ctx) error {
+		instance := c.Params("instance")
+		entry := c.Query("entry")
+		if !deps.Allowlists.Remove(instance, entry) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Allowlist entry not found"})
+		}
+		serverLogger.Info("Allowlist entry removed", "instance", instance, "entry", entry, "user", currentUser(c).Name)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	instanceAPI.Get("/subnets", func(c *fiber.Ctx) error {
+		if !config.Spec.PrefixGroupingEnabled {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Prefix grouping is disabled"})
+		}
+		subnets, exists := deps.Repo.GetRpaasSubnetData(c.Params("instance"))
+		if !exists {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Instance not found"})
+		}
+		return c.JSON(subnets)
+	})
+
+	instanceAPI.Get("/origins", func(c *fiber.Ctx) error {
+		if len(config.Spec.GeoIPDatabaseFiles) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "GeoIP enrichment is disabled"})
+		}
+		dimension := c.Query("by", repository.OriginByASN)
+		if !slices.Contains(repository.OriginDimensions, dimension) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid grouping %q", dimension)})
+		}
+		origins, exists := deps.Repo.GetRpaasOriginData(c.Params("instance"), dimension)
+		if !exists {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Instance not found"})
+		}
+		return c.JSON(origins)
+	})
+
+	instanceAPI.Get("/zones", func(c *fiber.Ctx) error {
+		worker, exists := deps.Workers.GetWorker(c.Params("instance"))
+		if !exists {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Instance worker not found"})
+		}
+		instanceWorker, ok := worker.(*manager.RpaasInstanceSyncWorker)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Instance worker not found"})
+		}
+		discovered, synchronized := instanceWorker.Zones()
+		return c.JSON(fiber.Map{
+			"discovered":   discovered,
+			"synchronized": synchronized,
+			"settings":     instanceWorker.Settings(),
+		})
+	})
+
+	instanceAPI.Get("/worker", func(c *fiber.Ctx) error {
+		worker, exists := deps.Workers.GetWorker(c.Params("instance"))
+		if !exists {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Instance worker not found"})
+		}
+		instanceWorker, ok := worker.(*manager.RpaasInstanceSyncWorker)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Instance worker not found"})
+		}
+		return c.JSON(instanceWorker.Status())
+	})
+
+	instanceAPI.Get("/anomalies", func(c *fiber.Ctx) error {
+		if deps.Anomalies == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Anomaly detection is disabled"})
+		}
+		return c.JSON(deps.Anomalies.Anomalies(c.Params("instance")))
+	})
+
+	api.Get("/anomalies", func(c *fiber.Ctx) error {
+		if deps.Anomalies == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Anomaly detection is disabled"})
+		}
+		anomalies := []anomaly.Anomaly{}
+		authorize := cachedAuthorizer(c, deps.Authorizer)
+		for _, a := range deps.Anomalies.Anomalies("") {
+			allowed, err := authorize(a.Instance)
+			if err != nil {
+				serverLogger.Error("Error authorizing request", "instance", a.Instance, "error", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error authorizing request"})
+			}
+			if allowed {
+				anomalies = append(anomalies, a)
+			}
+		}
+		return c.JSON(anomalies)
+	})
+
+	api.Get("/workers", func(c *fiber.Ctx) error {
+		statuses := []manager.InstanceWorkerStatus{}
+		authorize := cachedAuthorizer(c, deps.Authorizer)
+		for _, status := range deps.Workers.Statuses() {
+			allowed, err := authorize(status.Instance)
+			if err != nil {
+				serverLogger.Error("Error authorizing request", "instance", status.Instance, "error", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error authorizing request"})
+			}
+			if allowed {
+				statuses = append(statuses, status)
+			}
+		}
+		return c.JSON(statuses)
+	})
+
+	api.Get("/config", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"file":       config.Spec.ConfigFile,
+			"values":     config.Get().Values(),
+			"reloadable": config.Reloadable(),
+		})
+	})
+
+	api.Get("/overrides/audit", func(c *fiber.Ctx) error {
+		audit := []manager.AuditEntry{}
+		authorize := cachedAuthorizer(c, deps.Authorizer)
+		for _, entry := range deps.Overrides.Audit() {
+			allowed, err := authorize(entry.Override.Instance)
+			if err != nil {
+				serverLogger.Error("Error authorizing request", "instance", entry.Override.Instance, "error", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error authorizing request"})
+			}
+			if allowed {
+				audit = append(audit, entry)
+			}
+		}
+		return c.JSON(audit)
+	})
+}
+
+func newOverride(instance, user string, req overrideRequest, now time.Time) (manager.Override, error) {
+	ttl := config.Get().OverrideDefaultTTL
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil {
+			return manager.Override{}, fmt.Errorf("invalid ttl: %w", err)
+		}
+	}
+	if maxTTL := config.Get().OverrideMaxTTL; ttl <= 0 || ttl > maxTTL {
+		return manager.Override{}, fmt.Errorf("ttl must be positive and at most %s", maxTTL)
+	}
+	excess := req.Excess
+	switch req.Action {
+	case overrideActionBlock:
+		if excess <= 0 {
+			return manager.Override{}, errors.New("block requires a positive excess, usually the zone burst ceiling")
+		}
+	case overrideActionReset:
+		excess = 0
+	default:
+		return manager.Override{}, errors.New("action must be block or reset")
+	}
+	return manager.Override{
+		Instance:  instance,
+		Zone:      req.Zone,
+		Key:       req.Key,
+		Excess:    excess,
+		Reason:    req.Reason,
+		CreatedBy: user,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/auth"
+	"github.com/tsuru/rate-limit-control-plane/internal/manager"
+)
+
+// usersAuthorizer authorizes the listed users for every instance.
+type usersAuthorizer []string
+
+func (a usersAuthorizer) Authorize(ctx context.Context, user *auth.UserInfo, instance string) (bool, error) {
+	return slices.Contains(a, user.Name), nil
+}
+
+func newTestAPI(deps Dependencies) *fiber.App {
+	deps.Authenticator = auth.NewStaticTokenAuthenticator(map[string]auth.UserInfo{
+		"viewer-token": {Name: "viewer"},
+		"editor-token": {Name: "editor"},
+	})
+	deps.Authorizer = usersAuthorizer{"viewer", "editor"}
+	deps.WriteAuthorizer = usersAuthorizer{"editor"}
+	app := fiber.New()
+	app.Use(authenticate(deps.Authenticator))
+	registerAPIRoutes(app, deps)
+	return app
+}
+
+func apiRequest(t *testing.T, app *fiber.App, method, path, token, body string) (int, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderAuthorization, bearerAuthScheme+token)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestOverridesWriteAuthorization(t *testing.T) {
+	app := newTestAPI(Dependencies{Overrides: manager.NewOverrideStore(slog.New(slog.NewTextHandler(io.Discard, nil)), 10)})
+	override := `{"zone": "one", "key": "10.0.0.1", "action": "block", "excess": 20000}`
+
+	status, _ := apiRequest(t, app, http.MethodPost, "/api/v1/instances/my-instance/overrides", "viewer-token", override)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, body := apiRequest(t, app, http.MethodGet, "/api/v1/instances/my-instance/overrides", "viewer-token", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, "[]", body)
+
+	status, _ = apiRequest(t, app, http.MethodPost, "/api/v1/instances/my-instance/overrides", "editor-token", override)
+	assert.Equal(t, http.StatusCreated, status)
+	status, _ = apiRequest(t, app, http.MethodDelete, "/api/v1/instances/my-instance/overrides?zone=one&key=10.0.0.1", "viewer-token", "")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = apiRequest(t, app, http.MethodDelete, "/api/v1/instances/my-instance/overrides?zone=one&key=10.0.0.1", "editor-token", "")
+	assert.Equal(t, http.StatusNoContent, status)
+}
+
+func TestAllowlistWriteAuthorization(t *testing.T) {
+	app := newTestAPI(Dependencies{Allowlists: manager.NewAllowlistStore(0)})
+
+	status, _ := apiRequest(t, app, http.MethodPost, "/api/v1/instances/my-instance/allowlist", "viewer-token", `{"entry": "10.0.0.0/8"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, body := apiRequest(t, app, http.MethodGet, "/api/v1/instances/my-instance/allowlist", "viewer-token", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, body, "10.0.0.0/8")
+
+	status, _ = apiRequest(t, app, http.MethodPost, "/api/v1/instances/my-instance/allowlist", "editor-token", `{"entry": "10.0.0.0/8"}`)
+	assert.Equal(t, http.StatusCreated, status)
+	status, _ = apiRequest(t, app, http.MethodDelete, "/api/v1/instances/my-instance/allowlist?entry=10.0.0.0/8", "viewer-token", "")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = apiRequest(t, app, http.MethodDelete, "/api/v1/instances/my-instance/allowlist?entry=10.0.0.0/8", "editor-token", "")
+	assert.Equal(t, http.StatusNoContent, status)
+}
+
+func TestWriteAuthorizationDeniedWithoutAuthorizer(t *testing.T) {
+	deps := Dependencies{
+		Authenticator: auth.AllowAll{},
+		Authorizer:    auth.AllowAll{},
+		Overrides:     manager.NewOverrideStore(slog.New(slog.NewTextHandler(io.Discard, nil)), 10),
+	}
+	app := fiber.New()
+	app.Use(authenticate(deps.Authenticator))
+	registerAPIRoutes(app, deps)
+
+	status, _ := apiRequest(t, app, http.MethodPost, "/api/v1/instances/my-instance/overrides", "", `{"zone": "one", "key": "10.0.0.1", "action": "reset"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+func TestAuthenticateQueryToken(t *testing.T) {
+	app := newTestAPI(Dependencies{Overrides: manager.NewOverrideStore(slog.New(slog.NewTextHandler(io.Discard, nil)), 10)})
+	cookie := func(forwardedProto string) (int, string, *http.Cookie) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/instances/my-instance/overrides?zone=one&access_token=viewer-token", nil)
+		if forwardedProto != "" {
+			req.Header.Set(fiber.HeaderXForwardedProto, forwardedProto)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		cookies := resp.Cookies()
+		require.Len(t, cookies, 1)
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderLocation), cookies[0]
+	}
+
+	// The token is moved to a cookie and dropped from the URL
+	status, location, tokenCookie := cookie("")
+	assert.Equal(t, http.StatusFound, status)
+	assert.Equal(t, "/api/v1/instances/my-instance/overrides?zone=one", location)
+	assert.Equal(t, "viewer-token", tokenCookie.Value)
+	assert.True(t, tokenCookie.HttpOnly)
+	assert.False(t, tokenCookie.Secure)
+
+	_, _, tokenCookie = cookie("https")
+	assert.True(t, tokenCookie.Secure)
+
+	req := httptest.NewRequest(http.MethodGet, location, nil)
+	req.AddCookie(&http.Cookie{Name: tokenCookie.Name, Value: tokenCookie.Value})
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Invalid tokens aren't kept
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/instances/my-instance/overrides?access_token=bogus", nil)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Empty(t, resp.Cookies())
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/auth"
+)
+
+const (
+	tokenCookieName  = "rlcp_token"
+	tokenQueryParam  = "access_token"
+	userLocalsKey    = "user"
+	bearerAuthScheme = "Bearer "
+)
+
+// requestToken extracts the bearer token from the Authorization header, the
+// access_token query parameter or the session cookie. The last two exist
+// because browsers can't set headers on websocket and EventSource requests.
+func requestToken(c *fiber.Ctx) string {
+	if header := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(header, bearerAuthScheme) {
+		return strings.TrimSpace(strings.TrimPrefix(header, bearerAuthScheme))
+	}
+	if token := c.Query(tokenQueryParam); token != "" {
+		return token
+	}
+	return c.Cookies(tokenCookieName)
+}
+
+func authenticate(authenticator auth.Authenticator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := requestToken(c)
+		user, err := authenticator.Authenticate(c.UserContext(), token)
+		if err != nil {
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				return c.Status(fiber.StatusUnauthorized).SendString("Unauthorized")
+			}
+			serverLogger.Error("Error authenticating request", "error", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error authenticating request")
+		}
+		// Keep the token for the requests the dashboard makes from the browser
+		if token != "" && c.Query(tokenQueryParam) != "" {
+			c.Cookie(&fiber.Cookie{
+				Name:     tokenCookieName,
+				Value:    token,
+				HTTPOnly: true,
+				// Also true behind a proxy terminating TLS, from X-Forwarded-Proto
+				Secure:   c.Secure(),
+				SameSite: fiber.CookieSameSiteStrictMode,
+			})
+			// Pages are loaded again without the token, so it doesn't stay in
+			// the browser history; websockets can't follow redirects
+			if c.Method() == fiber.MethodGet && !websocket.IsWebSocketUpgrade(c) {
+				return c.Redirect(withoutTokenQuery(c), fiber.StatusFound)
+			}
+		}
+		c.Locals(userLocalsKey, user)
+		return c.Next()
+	}
+}
+
+// withoutTokenQuery returns the path of the request with its query, but the access_token.
+func withoutTokenQuery(c *fiber.Ctx) string {
+	query, err := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err != nil {
+		return c.Path()
+	}
+	query.Del(tokenQueryParam)
+	if len(query) == 0 {
+		return c.Path()
+	}
+	return c.Path() + "?" + query.Encode()
+}
+
+func authorizeInstance(authorizer auth.Authorizer, param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		instance := c.Params(param)
+		allowed, err := authorizer.Authorize(c.UserContext(), currentUser(c), instance)
+		if err != nil {
+			serverLogger.Error("Error authorizing request", "instance", instance, "error", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error authorizing request")
+		}
+		if !allowed {
+			// Not telling forbidden from missing instances avoids leaking instance names
+			return c.Status(fiber.StatusNotFound).SendString("Instance not found")
+		}
+		return c.Next()
+	}
+}
+
+// authorizeInstanceWrite checks the user may change the instance, which
+// authorizeInstance already showed to exist and to be visible to the user.
+func authorizeInstanceWrite(authorizer auth.Authorizer, param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		instance := c.Params(param)
+		if authorizer == nil {
+			return c.Status(fiber.StatusForbidden).SendString("Forbidden")
+		}
+		allowed, err := authorizer.Authorize(c.UserContext(), currentUser(c), instance)
+		if err != nil {
+			serverLogger.Error("Error authorizing request", "instance", instance, "error", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error authorizing request")
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).SendString("Forbidden")
+		}
+		return c.Next()
+	}
+}
+
+func currentUser(c *fiber.Ctx) *auth.UserInfo {
+	user, ok := c.Locals(userLocalsKey).(*auth.UserInfo)
+	if !ok {
+		return &auth.UserInfo{}
+	}
+	return user
+}
+
+// cachedAuthorizer authorizes each instance once for requests listing data of many instances.
+func cachedAuthorizer(c *fiber.Ctx, authorizer auth.Authorizer) func(instance string) (bool, error) {
+	allowedByInstance := map[string]bool{}
+	return func(instance string) (bool, error) {
+		if allowed, checked := allowedByInstance[instance]; checked {
+			return allowed, nil
+		}
+		allowed, err := authorizer.Authorize(c.UserContext(), currentUser(c), instance)
+		if err != nil {
+			return false, err
+		}
+		allowedByInstance[instance] = allowed
+		return allowed, nil
+	}
+}
+
+func filterAuthorizedInstances(c *fiber.Ctx, authorizer auth.Authorizer, instances []string) ([]string, error) {
+	allowedInstances := make([]string, 0, len(instances))
+	authorize := cachedAuthorizer(c, authorizer)
+	for _, instance := range instances {
+		allowed, err := authorize(instance)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			allowedInstances = append(allowedInstances, instance)
+		}
+	}
+	return allowedInstances, nil
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -12,13 +14,35 @@ import (
 	fiberLogger "github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/template/html/v2"
 
-	"github.com/tsuru/rate-limit-control-plane/internal/config"
+	"github.com/tsuru/rate-limit-control-plane/internal/anomaly"
+	"github.com/tsuru/rate-limit-control-plane/internal/auth"
 	"github.com/tsuru/rate-limit-control-plane/internal/logger"
+	"github.com/tsuru/rate-limit-control-plane/internal/manager"
 	"github.com/tsuru/rate-limit-control-plane/internal/repository"
 )
 
-func Notification(repo *repository.ZoneDataRepository, listenAddr string) {
-	serverLogger := logger.NewLogger(map[string]string{"emitter": "rate-limit-control-plane-notification-server"}, os.Stdout)
+const sseHeartbeatInterval = 15 * time.Second
+
+var serverLogger = logger.NewLogger(map[string]string{"emitter": "rate-limit-control-plane-notification-server"}, os.Stdout)
+
+// Dependencies are the stores and policies served by the internal API.
+type Dependencies struct {
+	Repo          *repository.ZoneDataRepository
+	Authenticator auth.Authenticator
+	Authorizer    auth.Authorizer
+	// WriteAuthorizer checks the requests changing the data of an instance, nil denies them
+	WriteAuthorizer auth.Authorizer
+	Overrides       *manager.OverrideStore
+	Allowlists      *manager.AllowlistStore
+	// Anomalies is nil when anomaly detection is disabled
+	Anomalies *anomaly.Detector
+	Workers   *manager.GoroutineManager
+}
+
+func Notification(deps Dependencies, listenAddr string) {
+	repo := deps.Repo
+	authenticator := deps.Authenticator
+	authorizer := deps.Authorizer
 	// Initialize template engine
 	engine := html.New("./views", ".html")
 
@@ -28,6 +52,7 @@ func Notification(repo *repository.ZoneDataRepository, listenAddr string) {
 
 	app.Server().LogAllErrors = true
 	app.Use(fiberLogger.New(fiberLogger.Config{
+		// The path only, the query may hold the access_token of the dashboard
 		Format:     "[${time}] ${status} - ${latency} ${method} ${path}\n",
 		TimeFormat: time.RFC3339,
 		TimeZone:   "Local",
@@ -36,7 +61,9 @@ func Notification(repo *repository.ZoneDataRepository, listenAddr string) {
 	// Setup static files
 	app.Static("/static", "./static")
 
-	app.Get("/rpaas/:rpaasName", func(c *fiber.Ctx) error {
+	app.Use(authenticate(authenticator))
+
+	app.Get("/rpaas/:rpaasName", authorizeInstance(authorizer, "rpaasName"), func(c *fiber.Ctx) error {
 		rpaasName := c.Params("rpaasName")
 		instances := repo.ListInstances()
 		var instanceName string
@@ -60,7 +87,11 @@ func Notification(repo *repository.ZoneDataRepository, listenAddr string) {
 	})
 
 	app.Get("/", func(c *fiber.Ctx) error {
-		instances := repo.ListInstances()
+		instances, err := filterAuthorizedInstances(c, authorizer, repo.ListInstances())
+		if err != nil {
+			serverLogger.Error("Error authorizing instances", "error", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error authorizing instances")
+		}
 		instancesJSON, err := json.Marshal(instances)
 		if err != nil {
 			serverLogger.Error("Error marshaling JSON", "error", err)
@@ -72,7 +103,7 @@ func Notification(repo *repository.ZoneDataRepository, listenAddr string) {
 		})
 	})
 
-	app.Use("/ws/:rpaasName", func(c *fiber.Ctx) error {
+	app.Use("/ws/:rpaasName", authorizeInstance(authorizer, "rpaasName"), func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
 			serverLogger.Debug("WS upgrade requested for rpaasName", "rpaasName", c.Params("rpaasName"))
 			c.Locals("allowed", true)
@@ -83,29 +114,115 @@ func Notification(repo *repository.ZoneDataRepository, listenAddr string) {
 
 	app.Get("/ws/:rpaasName", websocket.New(func(c *websocket.Conn) {
 		rpaasName := c.Params("rpaasName")
+		stream := newZoneDataStream(c.Query("mode"))
+		sub := repo.Subscribe(rpaasName)
+		defer repo.Unsubscribe(sub)
+
+		data, raw, ok := repo.GetRpaasZoneSnapshot(rpaasName)
+		if !ok {
+			if err := c.WriteMessage(websocket.TextMessage, []byte("Not Found")); err != nil {
+				serverLogger.Error("Error sending message", "error", err)
+			}
+			return
+		}
+
+		// Nothing is written until data changes, so a reader is needed to notice closed connections
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
 		for {
-			data, ok := repo.GetRpaasZoneData(rpaasName)
-			if !ok {
-				if err := c.WriteMessage(websocket.TextMessage, []byte("Not Found")); err != nil {
+			message, send, err := stream.next(data, raw)
+			if err != nil {
+				serverLogger.Error("Error marshaling JSON", "error", err)
+				return
+			}
+			if send {
+				if err := c.WriteMessage(websocket.TextMessage, message); err != nil {
 					serverLogger.Error("Error sending message", "error", err)
 					return
 				}
 			}
-			if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
-				serverLogger.Error("Error sending message", "error", err)
+			select {
+			case update, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				data, raw = update.Data, update.Raw
+			case <-closed:
+				serverLogger.Debug("WS connection closed", "rpaasName", rpaasName)
 				return
 			}
-			time.Sleep(config.Spec.ControllerIntervalDuration)
 		}
 	}))
 
-	app.Get("/instances/:instance", func(c *fiber.Ctx) error {
+	app.Get("/sse/:rpaasName", authorizeInstance(authorizer, "rpaasName"), func(c *fiber.Ctx) error {
+		rpaasName := c.Params("rpaasName")
+		data, raw, ok := repo.GetRpaasZoneSnapshot(rpaasName)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).SendString("Instance not found")
+		}
+		stream := newZoneDataStream(c.Query("mode"))
+		sub := repo.Subscribe(rpaasName)
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer repo.Unsubscribe(sub)
+			heartbeat := time.NewTicker(sseHeartbeatInterval)
+			defer heartbeat.Stop()
+			for {
+				message, send, err := stream.next(data, raw)
+				if err != nil {
+					serverLogger.Error("Error marshaling JSON", "error", err)
+					return
+				}
+				if send {
+					fmt.Fprintf(w, "data: %s\n\n", bytes.ReplaceAll(message, []byte("\n"), []byte("\ndata: ")))
+					if err := w.Flush(); err != nil {
+						serverLogger.Debug("SSE connection closed", "rpaasName", rpaasName, "error", err)
+						return
+					}
+				}
+				select {
+				case update, ok := <-sub.C:
+					if !ok {
+						return
+					}
+					data, raw = update.Data, update.Raw
+				case <-heartbeat.C:
+					// Comments keep proxies from timing out and detect closed connections
+					fmt.Fprint(w, ": heartbeat\n\n")
+					if err := w.Flush(); err != nil {
+						serverLogger.Debug("SSE connection closed", "rpaasName", rpaasName, "error", err)
+						return
+					}
+				}
+			}
+		})
+		return nil
+	})
+
+	app.Get("/instances/:instance", authorizeInstance(authorizer, "instance"), func(c *fiber.Ctx) error {
 		instance := c.Params("instance")
 		return c.Render("instance", fiber.Map{
 			"Instance": instance,
 		})
 	})
 
+	app.Get("/workers", func(c *fiber.Ctx) error {
+		return c.Render("workers", fiber.Map{})
+	})
+
+	registerAPIRoutes(app, deps)
+
 	// Create necessary directories and files
 	setupStaticFiles()
 
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/tsuru/rate-limit-control-plane/internal/repository"
+)
+
+const (
+	streamModeFull = "full"
+	streamModeDiff = "diff"
+)
+
+type snapshotMessage struct {
+	Type string            `json:"type"`
+	Data []repository.Data `json:"data"`
+}
+
+type diffMessage struct {
+	Type string `json:"type"`
+	repository.DataDiff
+}
+
+// zoneDataStream turns repository updates into the messages sent to a single client,
+// skipping updates the client has already seen.
+type zoneDataStream struct {
+	mode     string
+	started  bool
+	lastRaw  []byte
+	lastData []repository.Data
+}
+
+func newZoneDataStream(mode string) *zoneDataStream {
+	if mode != streamModeDiff {
+		mode = streamModeFull
+	}
+	return &zoneDataStream{mode: mode}
+}
+
+func (s *zoneDataStream) next(data []repository.Data, raw []byte) ([]byte, bool, error) {
+	if s.started && bytes.Equal(s.lastRaw, raw) {
+		return nil, false, nil
+	}
+	var message []byte
+	if s.mode == streamModeFull {
+		message = raw
+	} else {
+		var msg any = snapshotMessage{Type: "snapshot", Data: data}
+		if s.started {
+			diff := repository.Diff(s.lastData, data)
+			if diff.Empty() {
+				s.lastRaw = raw
+				return nil, false, nil
+			}
+			msg = diffMessage{Type: "diff", DataDiff: diff}
+		}
+		var err error
+		message, err = json.Marshal(msg)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	s.started = true
+	s.lastRaw = raw
+	s.lastData = data
+	return message, true, nil
+}
@@ -0,0 +1,37 @@
+// static/js/allowlist.js
+document.addEventListener('DOMContentLoaded', function () {
+  const tableBody = document.getElementById("allowlist-body");
+  const mode = document.getElementById("allowlist-mode");
+
+  fetch(`/api/v1/instances/${instanceName}/allowlist`)
+    .then(response => response.json())
+    .then(allowlist => {
+      tableBody.innerHTML = "";
+
+      const rows = [
+        ...allowlist.annotation.map(entry => ({ entry, source: "annotation" })),
+        ...allowlist.api.map(entry => ({ entry, source: "api" })),
+      ];
+
+      rows.forEach(item => {
+        const row = document.createElement("tr");
+
+        const entryCell = document.createElement("td");
+        entryCell.textContent = item.entry;
+        entryCell.className = "px-6 py-4";
+
+        const sourceCell = document.createElement("td");
+        sourceCell.textContent = item.source;
+        sourceCell.className = "px-6 py-4";
+
+        row.appendChild(entryCell);
+        row.appendChild(sourceCell);
+        tableBody.appendChild(row);
+      });
+
+      mode.textContent = allowlist.maxExcess > 0
+        ? `Allowlisted keys have their aggregated excess capped at ${allowlist.maxExcess}`
+        : "Allowlisted keys are not aggregated across pods";
+    })
+    .catch(error => console.error("Error loading allowlist", error));
+});
@@ -0,0 +1,43 @@
+// static/js/origins.js
+document.addEventListener('DOMContentLoaded', function () {
+  const section = document.getElementById("origins-section");
+  const tableBody = document.getElementById("origins-body");
+  const groupBy = document.getElementById("origins-by");
+
+  function load() {
+    fetch(`/api/v1/instances/${instanceName}/origins?by=${groupBy.value}`)
+      .then(response => {
+        // GeoIP enrichment is disabled or there is no data yet
+        if (!response.ok) {
+          return null;
+        }
+        return response.json();
+      })
+      .then(origins => {
+        if (!origins) {
+          return;
+        }
+        tableBody.innerHTML = "";
+
+        origins.forEach(item => {
+          const row = document.createElement("tr");
+          const origin = item.name ? `${item.origin} ${item.name}` : item.origin;
+
+          [origin, item.zone, item.keys, item.excess].forEach(value => {
+            const cell = document.createElement("td");
+            cell.textContent = value;
+            cell.className = "px-6 py-4";
+            row.appendChild(cell);
+          });
+
+          tableBody.appendChild(row);
+        });
+
+        section.classList.remove("hidden");
+      })
+      .catch(error => console.error("Error loading origins", error));
+  }
+
+  groupBy.addEventListener("change", load);
+  load();
+});
@@ -0,0 +1,36 @@
+// static/js/subnets.js
+document.addEventListener('DOMContentLoaded', function () {
+  const section = document.getElementById("subnets-section");
+  const tableBody = document.getElementById("subnets-body");
+
+  fetch(`/api/v1/instances/${instanceName}/subnets`)
+    .then(response => {
+      // Prefix grouping is disabled or there is no data yet
+      if (!response.ok) {
+        return null;
+      }
+      return response.json();
+    })
+    .then(subnets => {
+      if (!subnets) {
+        return;
+      }
+      tableBody.innerHTML = "";
+
+      subnets.forEach(item => {
+        const row = document.createElement("tr");
+
+        [item.subnet, item.zone, item.keys, item.excess].forEach(value => {
+          const cell = document.createElement("td");
+          cell.textContent = value;
+          cell.className = "px-6 py-4";
+          row.appendChild(cell);
+        });
+
+        tableBody.appendChild(row);
+      });
+
+      section.classList.remove("hidden");
+    })
+    .catch(error => console.error("Error loading subnets", error));
+});
@@ -0,0 +1,102 @@
+// static/js/workers.js
+document.addEventListener('DOMContentLoaded', function () {
+  const container = document.getElementById("workers");
+  const empty = document.getElementById("workers-empty");
+  const refreshInterval = 5000;
+
+  function formatTime(value) {
+    if (!value || value.startsWith("0001-")) {
+      return "never";
+    }
+    return new Date(value).toLocaleString();
+  }
+
+  function table(headers, rows) {
+    const wrapper = document.createElement("div");
+    wrapper.className = "border border-gray-700 rounded-lg mb-4";
+    const tableElement = document.createElement("table");
+    tableElement.className = "min-w-full text-sm text-left";
+
+    const head = document.createElement("thead");
+    head.className = "bg-gray-800 text-gray-300";
+    const headRow = document.createElement("tr");
+    headers.forEach(header => {
+      const cell = document.createElement("th");
+      cell.textContent = header;
+      cell.className = "px-6 py-3";
+      headRow.appendChild(cell);
+    });
+    head.appendChild(headRow);
+
+    const body = document.createElement("tbody");
+    body.className = "bg-gray-900 divide-y divide-gray-700";
+    rows.forEach(values => {
+      const row = document.createElement("tr");
+      values.forEach(value => {
+        const cell = document.createElement("td");
+        cell.textContent = value;
+        cell.className = "px-6 py-4";
+        row.appendChild(cell);
+      });
+      body.appendChild(row);
+    });
+
+    tableElement.appendChild(head);
+    tableElement.appendChild(body);
+    wrapper.appendChild(tableElement);
+    return wrapper;
+  }
+
+  function render(workers) {
+    container.innerHTML = "";
+    empty.classList.toggle("hidden", workers.length > 0);
+
+    workers.forEach(worker => {
+      const section = document.createElement("section");
+      section.className = "mb-10";
+
+      const title = document.createElement("h2");
+      title.className = "text-2xl font-bold mb-2";
+      const link = document.createElement("a");
+      link.href = `/instances/${encodeURIComponent(worker.instance)}`;
+      link.textContent = worker.instance;
+      title.appendChild(link);
+      section.appendChild(title);
+
+      const summary = document.createElement("p");
+      summary.className = "text-sm text-gray-400 mb-4";
+      summary.textContent = `Service ${worker.service} - interval ${worker.interval} - last round ${formatTime(worker.lastRound)} took ${worker.lastRoundDuration} - discovered zones: ${worker.discoveredZones.join(", ") || "none"}`;
+      section.appendChild(summary);
+
+      section.appendChild(table(
+        ["Zone", "Entries"],
+        worker.zones.map(zone => [zone.name, zone.entries]),
+      ));
+      section.appendChild(table(
+        ["Pod", "URL", "State", "Failures", "Clock Skew", "Resets", "Last Success", "Last Error"],
+        worker.pods.map(pod => [
+          pod.name,
+          pod.url,
+          pod.state,
+          pod.consecutiveFailures,
+          pod.clockSkew,
+          pod.resets,
+          formatTime(pod.lastSuccess),
+          pod.lastError ? `${formatTime(pod.lastErrorTime)}: ${pod.lastError}` : "",
+        ]),
+      ));
+
+      container.appendChild(section);
+    });
+  }
+
+  function load() {
+    fetch("/api/v1/workers")
+      .then(response => response.json())
+      .then(render)
+      .catch(error => console.error("Error loading workers", error));
+  }
+
+  load();
+  setInterval(load, refreshInterval);
+});
@@ -37,10 +37,20 @@ document.addEventListener('DOMContentLoaded', function () {
       excessCell.textContent = item.excess;
       excessCell.className = "px-6 py-4";
 
+      const countryCell = document.createElement("td");
+      countryCell.textContent = item.country || "";
+      countryCell.className = "px-6 py-4";
+
+      const asnCell = document.createElement("td");
+      asnCell.textContent = item.asn ? `AS${item.asn} ${item.asOrganization || ""}` : "";
+      asnCell.className = "px-6 py-4";
+
       row.appendChild(keyCell);
       row.appendChild(zoneCell);
       row.appendChild(lastCell);
       row.appendChild(excessCell);
+      row.appendChild(countryCell);
+      row.appendChild(asnCell);
 
       tableBody.appendChild(row);
     });
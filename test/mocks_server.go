@@ -35,7 +35,11 @@ func NewServerMock(listener net.Listener, repository *Repositories) {
 			return c.Status(fiber.StatusInternalServerError).SendString("Failed to encode header")
 		}
 		encodedData = append(encodedData, encondedHeader...)
+		lastGreaterEqual := int64(c.QueryInt("last_greater_equal", 0))
 		for _, body := range data.Body {
+			if body.Last < lastGreaterEqual {
+				continue
+			}
 			encodedBody, err := msgpack.Marshal(body)
 			if err != nil {
 				return c.Status(fiber.StatusInternalServerError).SendString("Failed to encode body")
@@ -56,6 +60,24 @@ func NewServerMock(listener net.Listener, repository *Repositories) {
 		return c.SendStatus(fiber.StatusNoContent)
 	})
 
+	// The real protocol, a single array with the header followed by the entries as arrays
+	app.Post("/rate-limit/:zone", func(c *fiber.Ctx) error {
+		var values []msgpack.RawMessage
+		if err := msgpack.Unmarshal(c.Body(), &values); err != nil || len(values) == 0 {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid body")
+		}
+		body := make([]*Body, 0, len(values)-1)
+		for _, value := range values[1:] {
+			var entry Body
+			if err := msgpack.Unmarshal(value, &entry); err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString("Invalid entry")
+			}
+			body = append(body, &entry)
+		}
+		repository.SetRateLimit(c.Params("zone"), body)
+		return c.SendStatus(fiber.StatusOK)
+	})
+
 	if err := app.Listener(listener); err != nil {
 		panic(err)
 	}
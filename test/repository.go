@@ -68,3 +68,11 @@ func (r *Repositories) SetRateLimit(zone string, values []*Body) {
 		return
 	}
 }
+
+func (r *Repositories) SetHeader(zone string, header Header) {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	if zone, ok := r.Values[zone]; ok {
+		zone.Header = header
+	}
+}